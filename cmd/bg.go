@@ -57,7 +57,7 @@ func RunBgCmd(cmd *cobra.Command, args []string) {
 	// Force png as the output for transparency for now
 	//TODO : figure out why jpeg,webp are not preserving transparency
 	shared.Format = "png"
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	method, err := cmd.Flags().GetString("method")
@@ -91,17 +91,16 @@ func RunBgCmd(cmd *cobra.Command, args []string) {
 
 	processor := image.NewBackgroundProcessor(strategy, clr)
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
 	if err != nil {
 		logger.Error(err, "The following images had errors while processing")
 	}
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseBgCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -13,7 +13,6 @@ import (
 	"github.com/Achno/gowall/config"
 	cpkg "github.com/Achno/gowall/internal/backends/color"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -74,6 +73,15 @@ func RunClrConvertCmd(cmd *cobra.Command, args []string) {
 	t, err := cpkg.NewTransformation([]string{inputColor}, []string{outputStr})
 	utils.HandleError(err, "Error creating transformation")
 
+	printTransformation(t)
+}
+
+// printTransformation prints the colors with their preview boxes, or as a json document with --json
+func printTransformation(t *cpkg.Transformation) {
+	if logger.JSON() {
+		logger.Document(t)
+		return
+	}
 	t.Print()
 }
 
@@ -123,7 +131,7 @@ func RunLightCmd(cmd *cobra.Command, args []string) {
 
 	t, err := cpkg.NewTransformation([]string{inputColor}, []string{lightenedColor})
 	utils.HandleError(err, "Error creating transformation")
-	t.Print()
+	printTransformation(t)
 }
 
 func ValidateParseLightCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -170,7 +178,7 @@ func RunDarkCmd(cmd *cobra.Command, args []string) {
 
 	t, err := cpkg.NewTransformation([]string{inputColor}, []string{lightenedColor})
 	utils.HandleError(err, "Error creating transformation")
-	t.Print()
+	printTransformation(t)
 }
 
 func ValidateParseDarkCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -218,7 +226,7 @@ func RunBlendCmd(cmd *cobra.Command, args []string) {
 
 	t, err := cpkg.NewTransformation([]string{inputColor1, inputColor2}, blendedColors)
 	utils.HandleError(err, "Error creating transformation")
-	t.Print()
+	printTransformation(t)
 }
 
 func ValidateParseBlendCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -276,7 +284,7 @@ func RunVariantsCmd(cmd *cobra.Command, args []string) {
 	t, err := cpkg.NewTransformation([]string{inputColor}, variants)
 	utils.HandleError(err, "Error creating transformation")
 
-	t.Print()
+	printTransformation(t)
 }
 
 func ValidateParseVariantsCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -354,7 +362,7 @@ func RunWheelCmd(cmd *cobra.Command, args []string) {
 	t, err := cpkg.NewTransformation([]string{inputColor}, wheelColors)
 	utils.HandleError(err, "Error creating transformation")
 
-	t.Print()
+	printTransformation(t)
 }
 
 func ValidateParseWheelCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -440,7 +448,7 @@ func BuildGradientCmd() *cobra.Command {
 }
 
 func RunGradientCmd(cmd *cobra.Command, args []string) {
-	ops, err := imageio.DetermineImageOperations(shared, args, cmd)
+	ops, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	width, err := cmd.Flags().GetInt("width")
@@ -477,13 +485,12 @@ func RunGradientCmd(cmd *cobra.Command, args []string) {
 	)
 
 	logger.Print("Generating gradient...")
-	gradientImages, err := image.ProcessImgs(processor, ops, image.ProcessOptions{
+	gradientImages := processImgs(cmd, processor, ops, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, gradientImages[0])
+	openImageInViewer(cmd, shared, args, gradientImages)
 }
 
 func ValidateParseGradientCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
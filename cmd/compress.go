@@ -9,7 +9,6 @@ import (
 
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -47,7 +46,7 @@ func BuildCompressCmd() *cobra.Command {
 
 func RunCompressCmd(cmd *cobra.Command, args []string) {
 
-	ops, err := imageio.DetermineImageOperations(shared, args, cmd)
+	ops, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	method, err := cmd.Flags().GetString("method")
@@ -68,16 +67,16 @@ func RunCompressCmd(cmd *cobra.Command, args []string) {
 		image.WithStrategy(method),
 		image.WithQuality(quality),
 		image.WithSpeed(speed),
+		image.WithBinaryDir(pngquantDir()),
 	)
 
 	logger.Print("Compressing images...")
-	compressedImages, err := image.ProcessImgs(processor, ops, image.ProcessOptions{
+	compressedImages := processImgs(cmd, processor, ops, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, compressedImages[0])
+	openImageInViewer(cmd, shared, args, compressedImages)
 }
 
 func ValidateParseCompressCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -6,8 +6,11 @@ package cmd
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/Achno/gowall/config"
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
 	"github.com/Achno/gowall/internal/image"
 	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
@@ -30,36 +33,63 @@ func BuildConvertCmd() *cobra.Command {
 	var (
 		theme     string
 		colorPair []string
+		clutLevel int
+		mapper    string
+		lut       string
+		distance  string
 	)
 
 	flags.StringVarP(&theme, "theme", "t", "", "Usage : --theme [ThemeName] or [PATH to Json file containing theme]")
-	flags.StringVarP(&shared.Format, "format", "f", "", "Usage : --format [image format] png,webp,jpg,jpeg")
+	flags.StringVarP(&shared.Format, "format", "f", "", "Usage : --format [image format] "+strings.Join(imageio.SupportedFormats(), ",")+", svg keeps the vectors of SVG inputs (the default for them)")
+	cmd.RegisterFlagCompletionFunc("format", formatCompletion)
 	flags.StringSliceVarP(&colorPair, "replace", "r", nil, "Usage: --replace #FromColor,#ToColor")
+	flags.StringVar(&lut, "lut", "", "Usage: --lut film.cube Grade the image with an external 3D LUT, a .cube file or a Hald PNG")
+	flags.StringVar(&mapper, "mapper", "", "Usage: --mapper [nn,"+strings.Join(haldclut.MapperNames, ",")+"] How --theme maps colors to the palette, with options e.g. rbf:sigma=30, idw:power=3 or oklab:sigma=0.15 (overrides ColorCorrectionBackend of the config, defaults to rbf)")
+	cmd.RegisterFlagCompletionFunc("mapper", mapperCompletion)
+	flags.StringVar(&distance, "distance", "", "Usage: --distance ["+strings.Join(cpkg.DistanceNames, ",")+"] How --mapper nn and --replace compare colors, perceptual metrics like ciede2000 or oklab match what the eye sees (overrides ColorDistance of the config, defaults to rgb)")
+	cmd.RegisterFlagCompletionFunc("distance", distanceCompletion)
+	flags.IntVar(&clutLevel, "clut-level", 0, "Usage: --clut-level [8,12,16] Level of the HaldCLUT used by --theme, higher levels follow the palette more closely but take longer to build (overrides config, defaults to 8)")
 
 	cmd.RegisterFlagCompletionFunc("theme", themeCompletion)
 
 	addGlobalFlags(cmd)
+	addFlags(cmd).WithRasterSize().WithDither()
 
 	return cmd
 }
 
 func RunConvertCmd(cmd *cobra.Command, args []string) {
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	theme, err := cmd.Flags().GetString("theme")
 	utils.HandleError(err, "Error")
 	colorPair, err := cmd.Flags().GetStringSlice("replace")
 	utils.HandleError(err, "Error")
+	lut, err := cmd.Flags().GetString("lut")
+	utils.HandleError(err, "Error")
+	distanceName, err := cmd.Flags().GetString("distance")
+	utils.HandleError(err, "Error")
+	distance, err := colorDistance(distanceName)
+	utils.HandleError(err, "Error")
 
 	var processor image.ImageProcessor
 
 	// Determine which processor to use
-	if len(theme) > 0 {
-		processor = &image.ThemeConverter{}
+	if len(lut) > 0 {
+		processor, err = image.NewLUTProcessor(config.ExpandTilde([]string{lut})[0])
+		utils.HandleError(err, "Error")
+	} else if len(theme) > 0 {
+		mapper, err := cmd.Flags().GetString("mapper")
+		utils.HandleError(err, "Error")
+		clutLevel, err := cmd.Flags().GetInt("clut-level")
+		utils.HandleError(err, "Error")
+		themeConverter := newThemeConverter(mapper, clutLevel, distance)
+		themeConverter.Dither = ditherOptions()
+		processor = themeConverter
 	} else if len(colorPair) > 0 {
 		logger.Print("Replacing color...")
-		processor = &image.ReplaceProcessor{}
+		processor = &image.ReplaceProcessor{Distance: distance}
 
 		// Configure ReplaceProcessor if color pairs are provided
 		if len(colorPair) < 2 {
@@ -82,13 +112,12 @@ func RunConvertCmd(cmd *cobra.Command, args []string) {
 	}
 
 	logger.Print("Processing images...")
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      theme,
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseConvertCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -99,9 +128,54 @@ func ValidateParseConvertCmd(cmd *cobra.Command, flags config.GlobalSubCommandFl
 	theme, _ := cmd.Flags().GetString("theme")
 	colorPair, _ := cmd.Flags().GetStringSlice("replace")
 
+	lut, _ := cmd.Flags().GetString("lut")
+
 	if len(theme) > 0 && len(colorPair) > 0 {
 		return fmt.Errorf("cannot use both the --theme and --replace flags together")
 	}
+	if len(lut) > 0 && (len(theme) > 0 || len(colorPair) > 0) {
+		return fmt.Errorf("cannot use --lut with --theme or --replace, use one of them")
+	}
+	if cmd.Flags().Changed("mapper") {
+		mapper, _ := cmd.Flags().GetString("mapper")
+		if err := validateMapper(mapper, true); err != nil {
+			return err
+		}
+	} else if len(theme) > 0 {
+		if err := validateConfigMapper(); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("clut-level") {
+		clutLevel, _ := cmd.Flags().GetInt("clut-level")
+		if err := haldclut.ValidateLevel(clutLevel); err != nil {
+			return err
+		}
+	}
+	if ditherOptions().Enabled() {
+		mapper, _ := cmd.Flags().GetString("mapper")
+		if mapper == "" {
+			mapper = configMapper()
+		}
+		if len(theme) == 0 || mapper != image.BackendNearestNeighbour {
+			return fmt.Errorf("--dither only works with --theme and --mapper nn, the HaldCLUT mappers blend colors instead of restricting them to the palette")
+		}
+	}
+	if cmd.Flags().Changed("distance") {
+		distance, _ := cmd.Flags().GetString("distance")
+		if _, err := cpkg.ParseMetric(distance); err != nil {
+			return err
+		}
+		mapper, _ := cmd.Flags().GetString("mapper")
+		if mapper == "" {
+			mapper = configMapper()
+		}
+		if len(colorPair) == 0 && (len(theme) == 0 || mapper != image.BackendNearestNeighbour) {
+			return fmt.Errorf("--distance only works with --replace or with --theme and --mapper nn, the HaldCLUT mappers do not pick the nearest color")
+		}
+	} else if _, err := colorDistance(""); err != nil {
+		return err
+	}
 
 	return nil
 }
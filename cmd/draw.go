@@ -9,7 +9,6 @@ import (
 	"github.com/Achno/gowall/config"
 	cpkg "github.com/Achno/gowall/internal/backends/color"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -32,6 +31,7 @@ func BuildDrawCmd() *cobra.Command {
 	cmd.AddCommand(BuildRoundCmd())
 
 	addGlobalFlags(cmd)
+	addFlags(cmd).WithRasterSize()
 
 	return cmd
 }
@@ -64,7 +64,7 @@ func BuildBorderCmd() *cobra.Command {
 func RunBorderCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing images...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	hex, err := cmd.Flags().GetString("color")
@@ -83,13 +83,12 @@ func RunBorderCmd(cmd *cobra.Command, args []string) {
 		CornerRadius:    cornerRadius,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseBorderCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -143,7 +142,7 @@ func BuildGridCmd() *cobra.Command {
 func RunGridCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing images...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	gridSize, err := cmd.Flags().GetInt("size")
@@ -163,13 +162,12 @@ func RunGridCmd(cmd *cobra.Command, args []string) {
 		image.WithMaskonly(gridMask),
 	)
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseGridCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -200,7 +198,7 @@ func BuildRoundCmd() *cobra.Command {
 func RunRoundCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing images...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	cornerRadius, err := cmd.Flags().GetFloat64("radius")
@@ -210,13 +208,12 @@ func RunRoundCmd(cmd *cobra.Command, args []string) {
 		CornerRadius: cornerRadius,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseRoundCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
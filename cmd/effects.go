@@ -40,6 +40,7 @@ func BuildEffectsCmd() *cobra.Command {
 	cmd.AddCommand(BuildTiltCmd())
 
 	addGlobalFlags(cmd)
+	addFlags(cmd).WithRasterSize()
 
 	return cmd
 }
@@ -60,17 +61,16 @@ func BuildFlipCmd() *cobra.Command {
 func RunFlipCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	processor := &image.FlipProcessor{}
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil, // default
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseFlipCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -96,17 +96,16 @@ func BuildMirrorCmd() *cobra.Command {
 func RunMirrorCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	processor := &image.MirrorProcessor{}
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil, // default
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseMirrorCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -132,17 +131,16 @@ func BuildGrayscaleCmd() *cobra.Command {
 func RunGrayscaleCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	processor := &image.GrayScaleProcessor{}
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseGrayscaleCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -173,20 +171,19 @@ func BuildBrightnessCmd() *cobra.Command {
 func RunBrightnessCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	factor, err := cmd.Flags().GetFloat64("factor")
 	utils.HandleError(err, "Error")
 
 	processor := &image.BrightnessProcessor{Factor: factor}
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil, // default
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseBrightnessCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -234,7 +231,7 @@ func BuildContrastCmd() *cobra.Command {
 func RunContrastCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	mode, err := cmd.Flags().GetString("mode")
@@ -253,13 +250,12 @@ func RunContrastCmd(cmd *cobra.Command, args []string) {
 		SigmoidFactor: sigmoidFactor,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseContrastCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -329,7 +325,7 @@ func BuildGammaCmd() *cobra.Command {
 func RunGammaCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	gamma, err := cmd.Flags().GetFloat64("gamma")
@@ -339,13 +335,12 @@ func RunGammaCmd(cmd *cobra.Command, args []string) {
 		Gamma: gamma,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseGammaCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -382,7 +377,7 @@ func BuildSaturationCmd() *cobra.Command {
 func RunSaturationCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	percentage, err := cmd.Flags().GetFloat64("percentage")
@@ -392,13 +387,12 @@ func RunSaturationCmd(cmd *cobra.Command, args []string) {
 		Percentage: percentage,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseSaturationCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -456,7 +450,7 @@ func BuildTiltCmd() *cobra.Command {
 func RunTiltCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Processing image...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	preset, err := buildTiltPreset(cmd)
@@ -466,13 +460,12 @@ func RunTiltCmd(cmd *cobra.Command, args []string) {
 		Preset: preset,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func buildTiltPreset(cmd *cobra.Command) (image.Preset, error) {
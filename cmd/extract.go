@@ -4,9 +4,15 @@ Copyright © 2024 Achnologia <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/image"
 	imageio "github.com/Achno/gowall/internal/image_io"
+	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
 )
@@ -37,7 +43,7 @@ func BuildExtractCmd() *cobra.Command {
 }
 
 func RunExtractCmd(cmd *cobra.Command, args []string) {
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	numOfColors, err := cmd.Flags().GetInt("colors")
@@ -49,18 +55,80 @@ func RunExtractCmd(cmd *cobra.Command, args []string) {
 		NumOfColors: numOfColors,
 	}
 
-	_, err = image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	// every palette is written to memory and printed once its image completed, so concurrent palettes do not interleave
+	outputs := make(map[string]*paletteOutput, len(imageOps))
+	for i := range imageOps {
+		output := &paletteOutput{name: imageOps[i].ImageOutput.String()}
+		outputs[output.name] = output
+		imageOps[i].ImageOutput = output
+	}
+
+	processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme: "",
 		OnComplete: func(outputPath string, remaining int) {
+			if !logger.JSON() {
+				fmt.Print(outputs[outputPath].buf.String())
+			}
 		},
 	})
-	utils.HandleError(err, "Error")
+
+	if logger.JSON() {
+		logger.Document(palettesDocument{Palettes: collectPalettes(imageOps, outputs)})
+		return
+	}
 
 	if previewFlag {
 		utils.OpenURL(config.HexCodeVisualUrl)
 	}
 }
 
+type palette struct {
+	Input  string   `json:"input"`
+	Colors []string `json:"colors"`
+}
+
+// palettesDocument is printed by extract in --json mode
+type palettesDocument struct {
+	Palettes []palette `json:"palettes"`
+}
+
+// paletteOutput is the output of an extract operation, it keeps the palette written by ExtractProcessor in memory
+type paletteOutput struct {
+	name string
+	buf  bytes.Buffer
+}
+
+func (po *paletteOutput) Create() (io.WriteCloser, error) {
+	// a retried operation starts over
+	po.buf.Reset()
+	return nopWriteCloser{&po.buf}, nil
+}
+
+func (po *paletteOutput) String() string {
+	return po.name
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// collectPalettes returns the palettes of the inputs that completed, in the order of imageOps
+func collectPalettes(imageOps []imageio.ImageIO, outputs map[string]*paletteOutput) []palette {
+	palettes := make([]palette, 0, len(imageOps))
+	for _, op := range imageOps {
+		colors := strings.Fields(outputs[op.ImageOutput.String()].buf.String())
+		if len(colors) == 0 {
+			continue
+		}
+		palettes = append(palettes, palette{Input: op.ImageInput.String(), Colors: colors})
+	}
+	return palettes
+}
+
 func ValidateParseExtractCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
 	if err := validateInput(flags, args); err != nil {
 		return err
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/Achno/gowall/internal/image"
+	imageio "github.com/Achno/gowall/internal/image_io"
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/Achno/gowall/utils"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of --continue-on-error, any other failure exits with 1
+const (
+	ExitPartialFailure = 2 // some inputs failed, the others were saved
+	ExitAllFailed      = 3 // every input failed
+)
+
+// exitCode is set by handleBatchError and returned by Execute once the command is done
+var exitCode int
+
+// processImgs runs image.ProcessImgs with the --retries of the command and handles its failures with handleBatchError
+func processImgs(cmd *cobra.Command, processor image.ImageProcessor, ops []imageio.ImageIO, opts image.ProcessOptions) []string {
+	opts.Retries = shared.Retries
+	opts.RetryDelay = shared.RetryDelay
+
+	paths, err := image.ProcessImgs(cmd.Context(), processor, ops, opts)
+	handleBatchError(err)
+	return paths
+}
+
+// handleBatchError writes the --failure-report and exits with 1, unless --continue-on-error is set:
+// then the failures are logged and the command goes on with the saved images (e.g. preview),
+// gowall exits with ExitPartialFailure or ExitAllFailed afterwards. An interrupted batch always exits with 1.
+func handleBatchError(err error) {
+	if err == nil {
+		return
+	}
+	batchErr, ok := image.AsBatchError(err)
+	if !ok {
+		utils.HandleError(err, "Error")
+		return
+	}
+
+	if shared.FailureReport != "" && len(batchErr.Failures) > 0 {
+		if err := image.WriteFailureReport(shared.FailureReport, batchErr.Failures); err != nil {
+			logger.Warnf("::: Could not write the failure report: %v :::", err)
+		}
+	}
+
+	if !shared.ContinueOnError || batchErr.Interrupted != nil {
+		utils.HandleError(err, "Error")
+		return
+	}
+
+	// --json already emitted an event with the error of every failed input
+	if !logger.JSON() {
+		for _, failure := range batchErr.Failures {
+			logger.Errorf("Failed %s: %v", failure.Input, failure.Err)
+		}
+	}
+	logger.Warnf("::: %d of %d images failed :::", len(batchErr.Failures), batchErr.Total)
+
+	exitCode = ExitPartialFailure
+	if batchErr.AllFailed() {
+		exitCode = ExitAllFailed
+	}
+}
+
+// exitWithCode exits with the code set by handleBatchError, if any
+func exitWithCode() {
+	if exitCode != 0 {
+		utils.Spinner.Stop()
+		os.Exit(exitCode)
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -16,9 +15,11 @@ import (
 
 func BuildGifCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "gif [--batch,--dir] [PATH(S)]",
+		Use:   "gif [--batch,--dir] [PATH(S)] or gif -",
 		Short: "Create a gif Animation out of Images",
-		Long:  `Create a gif Animation out of Images specifying the delay between frames, if the gif loops forever and other options`,
+		Long: `Create a gif Animation out of Images specifying the delay between frames, if the gif loops forever and other options.
+With - the frames (concatenated images or a list of paths, one per line) are read from stdin and the gif is written to stdout.
+Example: cat frame*.png | gowall gif - > anim.gif`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return ValidateParseGifCmd(cmd, shared, args)
 		},
@@ -37,6 +38,7 @@ func BuildGifCmd() *cobra.Command {
 	flags.IntVarP(&loop, "loop", "l", 0, "Loop=0 (loops forever), Loop=-1 shows frames only 1 time, Loop=n (shows frames n+1)")
 
 	addGlobalFlags(cmd)
+	addFlags(cmd).WithDither()
 
 	return cmd
 }
@@ -44,7 +46,7 @@ func BuildGifCmd() *cobra.Command {
 func RunGifCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Creating Gif...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	delay, err := cmd.Flags().GetInt("delay")
@@ -55,16 +57,17 @@ func RunGifCmd(cmd *cobra.Command, args []string) {
 	utils.HandleError(err, "Error")
 
 	processor := &image.GifProcessor{
-		Loop:  loop,
-		Delay: delay,
-		Mode:  resize,
+		Loop:   loop,
+		Delay:  delay,
+		Mode:   resize,
+		Dither: ditherOptions(),
 	}
 
-	path, err := image.MultiProcessImgs(processor, imageOps, image.MultiProcessOptions{
+	path, err := image.MultiProcessImgs(cmd.Context(), processor, imageOps, image.MultiProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	openImageInViewer(cmd, shared, args, path)
+	openImageInViewer(cmd, shared, args, []string{path})
 	utils.HandleError(err, "Error")
 }
 
@@ -73,8 +76,8 @@ func ValidateParseGifCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags,
 		return err
 	}
 
-	if len(args) > 0 {
-		return fmt.Errorf("use --batch or --dir with gif with multiple images,stdout is disabled for the gif cmd")
+	if len(args) > 1 || (len(args) == 1 && args[0] != "-") {
+		return fmt.Errorf("use --batch, --dir or - (images or paths piped to stdin) with gif")
 	}
 
 	delay, _ := cmd.Flags().GetInt("delay")
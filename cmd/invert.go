@@ -6,7 +6,6 @@ package cmd
 import (
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -32,18 +31,17 @@ func RunInvertCmd(cmd *cobra.Command, args []string) {
 
 	logger.Print("Processing images...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	processor := &image.Inverter{}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseInvertCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
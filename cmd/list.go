@@ -42,6 +42,14 @@ func RunListCmd(cmd *cobra.Command, args []string) {
 		colors, err := image.GetThemeColors(theme)
 		utils.HandleError(err)
 
+		if logger.JSON() {
+			logger.Document(struct {
+				Theme  string   `json:"theme"`
+				Colors []string `json:"colors"`
+			}{theme, colors})
+			return
+		}
+
 		for _, color := range colors {
 			logger.Print(color)
 		}
@@ -53,6 +61,12 @@ func RunListCmd(cmd *cobra.Command, args []string) {
 	default:
 		allThemes := image.ListThemes()
 		sort.Strings(allThemes)
+		if logger.JSON() {
+			logger.Document(struct {
+				Themes []string `json:"themes"`
+			}{allThemes})
+			return
+		}
 		for _, theme := range allThemes {
 			logger.Print(theme)
 		}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2025 Achno <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Achno/gowall/config"
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
+	"github.com/Achno/gowall/internal/image"
+	imageio "github.com/Achno/gowall/internal/image_io"
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/Achno/gowall/utils"
+	"github.com/spf13/cobra"
+)
+
+// LUT formats of lut export
+const (
+	lutFormatCube = "cube"
+	lutFormatHald = "hald"
+)
+
+func BuildLutCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lut [COMMAND]",
+		Short: "Work with 3D LUTs - export themes as .cube or Hald PNG LUTs",
+		Long:  `Work with 3D LUTs - export the color grading of a theme as a .cube or Hald PNG LUT for darktable, Resolve, ffmpeg or mpv. Use convert --lut to apply an external LUT`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger.Print("Please specify a lut command")
+			err := cmd.Usage()
+			utils.HandleError(err)
+		},
+	}
+
+	cmd.AddCommand(BuildLutExportCmd())
+
+	return cmd
+}
+
+func BuildLutExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the conversion of a theme as a .cube or Hald PNG LUT",
+		Long:  `Export the HaldCLUT convert --theme uses as a .cube 3D LUT (darktable, Resolve, ffmpeg lut3d, mpv) or as a Hald PNG (ffmpeg haldclut, ImageMagick, RawTherapee)`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return ValidateParseLutExportCmd(cmd, shared, args)
+		},
+		Run: RunLutExportCmd,
+	}
+
+	flags := cmd.Flags()
+	var (
+		theme     string
+		format    string
+		output    string
+		clutLevel int
+		mapper    string
+	)
+	flags.StringVarP(&theme, "theme", "t", "", "Usage : --theme [ThemeName] or [PATH to Json file containing theme]")
+	flags.StringVarP(&format, "format", "f", lutFormatCube, "Usage: --format [cube,hald] .cube 3D LUT or Hald PNG")
+	flags.StringVarP(&output, "output", "o", "", "Usage: --output ~/luts/nord.cube (defaults to the luts folder of the output folder)")
+	flags.StringVar(&mapper, "mapper", "", "Usage: --mapper ["+strings.Join(haldclut.MapperNames, ",")+"] How the LUT maps colors to the palette, with options e.g. rbf:sigma=30 (overrides ColorCorrectionBackend of the config, defaults to rbf)")
+	flags.IntVar(&clutLevel, "clut-level", 0, "Usage: --clut-level [8,12,16] Level of the HaldCLUT, a .cube has level^2 entries per channel (overrides config, defaults to 8)")
+
+	cmd.MarkFlagRequired("theme")
+	cmd.RegisterFlagCompletionFunc("theme", themeCompletion)
+	cmd.RegisterFlagCompletionFunc("mapper", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return haldclut.MapperNames, cobra.ShellCompDirectiveNoFileComp
+	})
+	cmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{lutFormatCube, lutFormatHald}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
+
+func RunLutExportCmd(cmd *cobra.Command, args []string) {
+	theme, _ := cmd.Flags().GetString("theme")
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	clutLevel, _ := cmd.Flags().GetInt("clut-level")
+	mapper, _ := cmd.Flags().GetString("mapper")
+
+	// json themes are registered under the name in the file
+	if strings.HasSuffix(theme, ".json") {
+		name, err := image.LoadThemeFromJson(theme)
+		utils.HandleError(err, "Error")
+		theme = name
+	}
+
+	if output == "" {
+		ext := ".cube"
+		if format == lutFormatHald {
+			ext = ".png"
+		}
+		output = filepath.Join(config.GowallConfig.OutputFolder, "luts", theme+ext)
+	}
+	output = config.ExpandTilde([]string{output})[0]
+
+	// the LUT is generated, so the plan has no input
+	if shared.DryRun {
+		imageio.PrintPlan([]imageio.ImageIO{{ImageInput: imageio.NoInput{}, ImageOutput: imageio.FileWriter{Path: output}, Format: format}})
+		return
+	}
+
+	clut, level, err := newThemeConverter(mapper, clutLevel, cpkg.Metric{}).CLUT(theme)
+	utils.HandleError(err, "Error")
+
+	if format == lutFormatHald {
+		err = haldclut.SaveHaldCLUT(clut, output)
+	} else {
+		err = haldclut.SaveCube(clut, level, theme, output)
+	}
+	utils.HandleError(err, "Error")
+
+	if logger.JSON() {
+		logger.Document(struct {
+			Theme  string `json:"theme"`
+			Format string `json:"format"`
+			Level  int    `json:"level"`
+			Output string `json:"output"`
+		}{theme, format, level, output})
+		return
+	}
+	logger.Printf("::: LUT of %s saved in %s :::\n", theme, output)
+}
+
+func ValidateParseLutExportCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != lutFormatCube && format != lutFormatHald {
+		return fmt.Errorf("invalid --format %q, use %s or %s", format, lutFormatCube, lutFormatHald)
+	}
+	if cmd.Flags().Changed("mapper") {
+		mapper, _ := cmd.Flags().GetString("mapper")
+		if err := validateMapper(mapper, false); err != nil {
+			return err
+		}
+	} else if err := validateConfigMapper(); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("clut-level") {
+		clutLevel, _ := cmd.Flags().GetInt("clut-level")
+		if err := haldclut.ValidateLevel(clutLevel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(BuildLutCmd())
+}
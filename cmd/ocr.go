@@ -9,7 +9,6 @@ import (
 	"path/filepath"
 
 	"github.com/Achno/gowall/config"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/pdf"
 	"github.com/Achno/gowall/internal/providers"
 	"github.com/Achno/gowall/utils"
@@ -65,14 +64,19 @@ func runOCRcmd(cmd *cobra.Command, args []string) {
 	cfg, err := LoadOCRConfig(cmd)
 	utils.HandleError(err, "Error")
 
-	ops, err := imageio.DetermineImageOperations(shared, args, cmd)
+	ops, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
+	// --incremental skipped every input
+	if len(ops) == 0 {
+		return
+	}
+
 	n, err := providers.NewOCRProvider(cfg)
 	utils.HandleError(err, "Error")
 
 	service := providers.NewProviderService(n, cfg)
-	err = providers.StartOCRPipeline(ops, service)
+	err = providers.StartOCRPipeline(cmd.Context(), ops, service)
 	utils.HandleError(err, "Error")
 }
 
@@ -303,7 +307,7 @@ func setDefaultOCRConfig() providers.Config {
 		},
 		OCR: providers.ProviderConfig{
 			Format: "md",
-			Prompt: "Extract all visible text from this image and format the output as markdown. Include only the text content; no explanations or additional text should be included. If the image is empty, return an empty string. Fix any formatting issues or inconsistencies found in the extracted content",
+			Prompt: providers.DefaultOCRPrompt,
 		},
 		TextCorrection: providers.TextCorrectionConfig{
 			Enabled: false,
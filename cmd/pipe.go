@@ -0,0 +1,131 @@
+/*
+Copyright © 2026 Achno
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/image"
+	imageio "github.com/Achno/gowall/internal/image_io"
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/Achno/gowall/utils"
+	"github.com/spf13/cobra"
+)
+
+func BuildPipeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pipe [INPUT] [OPTIONAL OUTPUT]",
+		Short: "Chain multiple processors (convert, effects, draw, compress...) into a single run",
+		Long: `Chain multiple processors in memory and write a single output, either from a yaml recipe (--recipe) or inline steps (--step).
+Example: gowall pipe img.png --theme nord --step convert --step br:factor=1.2 --step "border:color=#5D3FD3,thickness=5" --step compress:quality=70`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return ValidateParsePipeCmd(cmd, shared, args)
+		},
+		Run: RunPipeCmd,
+	}
+
+	flags := cmd.Flags()
+	var (
+		recipe string
+		steps  []string
+		theme  string
+	)
+
+	flags.StringVar(&recipe, "recipe", "", "Usage: --recipe [PATH to yaml recipe]")
+	flags.StringArrayVarP(&steps, "step", "s", nil, "Usage: --step name:key=value,key=value (repeatable). Available steps: "+strings.Join(image.GetRecipeStepNames(), ", "))
+	flags.StringVarP(&theme, "theme", "t", "", "Usage : --theme [ThemeName] or [PATH to Json file containing theme], overrides the recipe theme")
+	flags.StringVarP(&shared.Format, "format", "f", "", "Usage : --format [image format] "+strings.Join(imageio.SupportedFormats(), ","))
+	cmd.RegisterFlagCompletionFunc("format", formatCompletion)
+
+	cmd.RegisterFlagCompletionFunc("theme", themeCompletion)
+	cmd.RegisterFlagCompletionFunc("step", pipeStepCompletion)
+
+	addGlobalFlags(cmd)
+
+	return cmd
+}
+
+func RunPipeCmd(cmd *cobra.Command, args []string) {
+	imageOps, err := determineImageOperations(cmd, args)
+	utils.HandleError(err, "Error")
+
+	recipe, err := buildRecipe(cmd)
+	utils.HandleError(err, "Error")
+
+	processor, err := image.NewPipelineProcessor(recipe, stepConfig())
+	utils.HandleError(err, "Error")
+
+	logger.Print("Processing images...")
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
+		Theme:      "",
+		OnComplete: nil,
+	})
+
+	openImageInViewer(cmd, shared, args, processedImages)
+}
+
+// buildRecipe loads the --recipe file and appends the inline --step flags, --theme overrides the recipe theme.
+func buildRecipe(cmd *cobra.Command) (image.Recipe, error) {
+	var recipe image.Recipe
+
+	recipePath, err := cmd.Flags().GetString("recipe")
+	if err != nil {
+		return image.Recipe{}, err
+	}
+	if recipePath != "" {
+		recipe, err = image.LoadRecipe(config.ExpandTilde([]string{recipePath})[0])
+		if err != nil {
+			return image.Recipe{}, err
+		}
+	}
+
+	steps, err := cmd.Flags().GetStringArray("step")
+	if err != nil {
+		return image.Recipe{}, err
+	}
+	for _, s := range steps {
+		step, err := image.ParseRecipeStep(s)
+		if err != nil {
+			return image.Recipe{}, err
+		}
+		recipe.Steps = append(recipe.Steps, step)
+	}
+
+	if cmd.Flags().Changed("theme") {
+		recipe.Theme, err = cmd.Flags().GetString("theme")
+		if err != nil {
+			return image.Recipe{}, err
+		}
+	}
+
+	return recipe, nil
+}
+
+func ValidateParsePipeCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
+	if err := validateInput(flags, args); err != nil {
+		return err
+	}
+
+	if !cmd.Flags().Changed("recipe") && !cmd.Flags().Changed("step") {
+		return fmt.Errorf("specify the processors to chain with --recipe or --step")
+	}
+
+	recipe, err := buildRecipe(cmd)
+	if err != nil {
+		return err
+	}
+
+	_, err = image.NewPipelineProcessor(recipe, stepConfig())
+	return err
+}
+
+func pipeStepCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return image.GetRecipeStepNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(BuildPipeCmd())
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -38,7 +37,7 @@ func BuildPixelateCmd() *cobra.Command {
 }
 
 func RunPixelateCmd(cmd *cobra.Command, args []string) {
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	scale, err := cmd.Flags().GetFloat64("scale")
@@ -49,17 +48,16 @@ func RunPixelateCmd(cmd *cobra.Command, args []string) {
 		Scale: scale,
 	}
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
 	if err != nil {
 		logger.Error(err, "The following images had errors while processing")
 	}
 
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParsePixelateCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
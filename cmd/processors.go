@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Achno/gowall/config"
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
+	"github.com/Achno/gowall/internal/backends/dither"
+	"github.com/Achno/gowall/internal/image"
+	"github.com/spf13/cobra"
+)
+
+// The processors don't read config.yml or the flags, these helpers map them onto their configuration.
+
+// newThemeConverter uses the ColorCorrectionBackend and CLUTLevel of config.yml unless mapper or level are set (--mapper, --clut-level),
+// distance is the metric of the nn mapper (see colorDistance)
+func newThemeConverter(mapper string, level int, distance cpkg.Metric) *image.ThemeConverter {
+	if mapper == "" {
+		mapper = configMapper()
+	}
+	if level == 0 {
+		level = config.GowallConfig.CLUTLevel
+	}
+	return image.NewThemeConverter(mapper, filepath.Join(config.GowallConfig.OutputFolder, "cluts"), level, distance)
+}
+
+// configMapper returns the ColorCorrectionBackend of config.yml as a mapper spec. Before the mappers every value
+// other than nn selected the HaldCLUT, so "clut" and any other value that does not name a mapper select the default rbf mapper.
+func configMapper() string {
+	backend := strings.TrimSpace(config.GowallConfig.ColorCorrectionBackend)
+	if backend == image.BackendNearestNeighbour {
+		return backend
+	}
+	name, _, _ := strings.Cut(strings.ToLower(backend), ":")
+	if !slices.Contains(haldclut.MapperNames, name) {
+		return ""
+	}
+	return backend
+}
+
+// validateConfigMapper checks the options of the ColorCorrectionBackend of config.yml once before any image is processed
+func validateConfigMapper() error {
+	if err := validateMapper(configMapper(), true); err != nil {
+		return fmt.Errorf("ColorCorrectionBackend: %w", err)
+	}
+	return nil
+}
+
+// colorDistance returns the metric named by --distance, or the ColorDistance of config.yml when name is empty
+func colorDistance(name string) (cpkg.Metric, error) {
+	if name == "" {
+		name = config.GowallConfig.ColorDistance
+	}
+	metric, err := cpkg.ParseMetric(name)
+	if err != nil {
+		return cpkg.Metric{}, fmt.Errorf("ColorDistance: %w", err)
+	}
+	return metric, nil
+}
+
+func distanceCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return cpkg.DistanceNames, cobra.ShellCompDirectiveNoFileComp
+}
+
+// validateMapper checks the value of --mapper, nn is only accepted when allowNearest is set
+func validateMapper(mapper string, allowNearest bool) error {
+	if mapper == image.BackendNearestNeighbour && allowNearest {
+		return nil
+	}
+	_, err := haldclut.ParseMapper(mapper)
+	return err
+}
+
+func mapperCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return append([]string{image.BackendNearestNeighbour}, haldclut.MapperNames...), cobra.ShellCompDirectiveNoFileComp
+}
+
+// processorConfig describes what the processor of the command resolves from config.yml (the mapper, CLUT level, distance
+// and the colors of the theme), --incremental adds it to the fingerprint of the outputs so editing config.yml reprocesses them
+func processorConfig(cmd *cobra.Command) string {
+	var parts []string
+	if theme, err := cmd.Flags().GetString("theme"); err == nil && theme != "" {
+		// custom themes of config.yml can change colors under the same name
+		if colors, err := image.GetThemeColors(theme); err == nil {
+			parts = append(parts, "palette="+cpkg.HashPalette(colors))
+		}
+	}
+
+	switch cmd.Name() {
+	case "convert", "pipe":
+		mapper, _ := cmd.Flags().GetString("mapper")
+		if mapper == "" {
+			mapper = configMapper()
+		}
+		if mapper != image.BackendNearestNeighbour {
+			if parsed, err := haldclut.ParseMapper(mapper); err == nil {
+				mapper = fmt.Sprint(parsed)
+			}
+		}
+		level, _ := cmd.Flags().GetInt("clut-level")
+		if level == 0 {
+			level = config.GowallConfig.CLUTLevel
+		}
+		if level == 0 {
+			level = haldclut.DefaultLevel
+		}
+		distanceName, _ := cmd.Flags().GetString("distance")
+		distance, _ := colorDistance(distanceName)
+		dithering := ditherOptions()
+		parts = append(parts, fmt.Sprintf("mapper=%s level=%d distance=%s dither=%s:%g", mapper, level, distance, dithering.Mode, dithering.Strength))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ditherOptions returns the dithering of --dither and --dither-strength
+func ditherOptions() dither.Options {
+	return dither.Options{Mode: shared.Dither, Strength: shared.DitherStrength}
+}
+
+func newUpscaleProcessor(scale int, modelName string) *image.UpscaleProcessor {
+	return image.NewUpscaleProcessor(scale, modelName, filepath.Join(config.GowallConfig.OutputFolder, "upscaler"))
+}
+
+func stepConfig() image.StepConfig {
+	return image.StepConfig{
+		ColorCorrectionBackend: configMapper(),
+		CLUTDir:                filepath.Join(config.GowallConfig.OutputFolder, "cluts"),
+		CLUTLevel:              config.GowallConfig.CLUTLevel,
+		ColorDistance:          config.GowallConfig.ColorDistance,
+		UpscalerDir:            filepath.Join(config.GowallConfig.OutputFolder, "upscaler"),
+		PngquantDir:            pngquantDir(),
+	}
+}
+
+func pngquantDir() string {
+	return filepath.Join(config.GowallConfig.OutputFolder, "compression", "pngquant")
+}
+
+// previewOptions reads the preview settings of config.yml, --preview overrides EnableImagePreviewing
+func previewOptions(cmd *cobra.Command, flags config.GlobalSubCommandFlags) image.PreviewOptions {
+	opts := image.PreviewOptions{
+		Enabled: config.GowallConfig.EnableImagePreviewing,
+		Backend: config.GowallConfig.ImagePreviewBackend,
+		Inline:  config.GowallConfig.InlineImagePreview,
+	}
+	if cmd.Flags().Changed("preview") {
+		opts.Enabled = flags.PreviewFlag == "true"
+	}
+	return opts
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -51,7 +50,7 @@ func BuildResizeCmd() *cobra.Command {
 
 func RunResizeCmd(cmd *cobra.Command, args []string) {
 
-	ops, err := imageio.DetermineImageOperations(shared, args, cmd)
+	ops, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	width, err := cmd.Flags().GetInt("width")
@@ -69,13 +68,12 @@ func RunResizeCmd(cmd *cobra.Command, args []string) {
 	)
 
 	logger.Print("Resizing images...")
-	resizedImages, err := image.ProcessImgs(processor, ops, image.ProcessOptions{
+	resizedImages := processImgs(cmd, processor, ops, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, resizedImages[0])
+	openImageInViewer(cmd, shared, args, resizedImages)
 }
 
 func ValidateParseResizeCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -4,13 +4,18 @@ Copyright © 2024 Achnologia <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/internal/api"
+	"github.com/Achno/gowall/internal/backends/dither"
+	"github.com/Achno/gowall/internal/backends/icc"
 	"github.com/Achno/gowall/internal/image"
 	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
@@ -28,24 +33,24 @@ func isInputBatch(flags config.GlobalSubCommandFlags) bool {
 	return len(flags.InputFiles) > 0 || len(flags.InputDir) > 0
 }
 
-func openImageInViewer(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string, path string) {
-	if imageio.IsStdoutOutput(flags, args) {
+// openImageInViewer previews the first processed image, paths is empty when --incremental skipped everything
+func openImageInViewer(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string, paths []string) {
+	if imageio.IsStdoutOutput(flags, args) || len(paths) == 0 {
 		return
 	}
+	path := paths[0]
 
 	if isInputBatch(shared) && !imageio.IsMultiInputSingleOutputCommand(cmd.Name()) {
 		return
 	}
 
-	if cmd.Flags().Changed("preview") {
-		config.GowallConfig.EnableImagePreviewing = flags.PreviewFlag == "true"
-	}
+	opts := previewOptions(cmd, flags)
 
 	var err error
 	if strings.EqualFold(filepath.Ext(path), ".gif") {
-		err = image.OpenGifInViewer(path)
+		err = image.OpenGifInViewer(path, opts)
 	} else {
-		err = image.OpenImageInViewer(path)
+		err = image.OpenImageInViewer(path, opts)
 	}
 
 	if err != nil {
@@ -61,9 +66,96 @@ func validateFlagsCompatibility(cmd *cobra.Command, args []string) error {
 	if (len(args) == 2 && args[1] == "-") && shared.OutputDestination != "" {
 		return fmt.Errorf("cannot use - pseudofile for stdout and --output flag at the same time")
 	}
+	if shared.Jobs < 0 {
+		return fmt.Errorf("--jobs must be non-negative (0 uses the number of CPUs), got: %d", shared.Jobs)
+	}
+	if (shared.Recursive || len(shared.Include) > 0 || len(shared.Exclude) > 0) && shared.InputDir == "" {
+		return fmt.Errorf("--recursive, --include and --exclude can only be used with --dir")
+	}
+	if shared.ColorSpace != "" && shared.ColorSpace != imageio.ColorManagementNone {
+		if _, err := icc.WorkingSpace(shared.ColorSpace); err != nil {
+			return err
+		}
+	}
+	switch shared.OutputProfile {
+	case "", imageio.OutputProfileTag, imageio.OutputProfileConvert, imageio.OutputProfileNone:
+	default:
+		return fmt.Errorf("invalid --output-profile %q, use tag, convert or none", shared.OutputProfile)
+	}
+	isVectorFormat := strings.EqualFold(shared.Format, imageio.FormatSVG) && imageio.SupportsVectorOutput(cmd.Name())
+	if shared.Format != "" && cmd.Name() != "ocr" && !isVectorFormat && !imageio.IsSupportedFormat(shared.Format) {
+		return fmt.Errorf("unsupported --format %q, available: %s", shared.Format, strings.Join(imageio.SupportedFormats(), ", "))
+	}
+	switch shared.Frames {
+	case "", imageio.FramesAnimate, imageio.FramesFirst, imageio.FramesAll:
+	default:
+		return fmt.Errorf("invalid --frames %q, use animate, first or all", shared.Frames)
+	}
+	if shared.Frames == imageio.FramesAll && imageio.IsMultiInputSingleOutputCommand(cmd.Name()) {
+		return fmt.Errorf("--frames %s is not supported by %s", imageio.FramesAll, cmd.Name())
+	}
+	if shared.RasterWidth < 0 || shared.RasterHeight < 0 || shared.RasterDPI < 0 {
+		return fmt.Errorf("--width, --height and --dpi must be positive numbers")
+	}
+	if shared.RasterDPI > 0 && (shared.RasterWidth > 0 || shared.RasterHeight > 0) {
+		return fmt.Errorf("cannot use --dpi with --width or --height, use one or the other")
+	}
+	if cmd.Flags().Changed("dither-strength") && shared.DitherStrength <= 0 {
+		return fmt.Errorf("--dither-strength must be in range (0, 1], got: %.2f", shared.DitherStrength)
+	}
+	if err := ditherOptions().Validate(); err != nil {
+		return err
+	}
+	if shared.KeepMetadata && shared.StripMetadata {
+		return fmt.Errorf("cannot use --keep-metadata and --strip-metadata together, use one or the other")
+	}
+	if shared.Retries < 0 || shared.RetryDelay < 0 {
+		return fmt.Errorf("--retries and --retry-delay must be non-negative")
+	}
+	if (shared.ContinueOnError || shared.FailureReport != "" || shared.Retries > 0) && (cmd.Name() == "ocr" || imageio.IsMultiInputSingleOutputCommand(cmd.Name())) {
+		return fmt.Errorf("--continue-on-error, --failure-report and --retries are not supported by %s", cmd.Name())
+	}
+	if shared.FailureReport != "" {
+		shared.FailureReport = config.ExpandTilde([]string{shared.FailureReport})[0]
+	}
+	switch shared.OnCollision {
+	case "", imageio.CollisionOverwrite, imageio.CollisionSkip, imageio.CollisionSuffix:
+	default:
+		return fmt.Errorf("invalid --on-collision %q, use overwrite, skip or suffix", shared.OnCollision)
+	}
+	if cmd.Flags().Changed("name") {
+		if !isInputBatch(shared) || imageio.IsMultiInputSingleOutputCommand(cmd.Name()) {
+			return fmt.Errorf("--name can only be used with --dir or --batch")
+		}
+		if err := imageio.ValidateNameTemplate(shared.NameTemplate); err != nil {
+			return err
+		}
+	}
+	if shared.Incremental && imageio.IsMultiInputSingleOutputCommand(cmd.Name()) {
+		return fmt.Errorf("--incremental is not supported by %s, it always creates a new output", cmd.Name())
+	}
 	return nil
 }
 
+// determineImageOperations resolves the image operations of the command, with --dry-run they are printed and gowall exits
+func determineImageOperations(cmd *cobra.Command, args []string) ([]imageio.ImageIO, error) {
+	ops, err := imageio.DetermineImageOperations(shared, args, cmd, processorConfig(cmd))
+	if err != nil || !shared.DryRun {
+		return ops, err
+	}
+	utils.Spinner.Stop()
+	imageio.PrintPlan(ops)
+	// both need to read the inputs, which a dry run does not
+	if shared.Frames == imageio.FramesAll && !imageio.IsMultiInputSingleOutputCommand(cmd.Name()) {
+		logger.Printf("::: --frames all: animated inputs are written to one <name>_frameNNN output per frame :::")
+	}
+	if shared.Incremental && !imageio.IsMultiInputSingleOutputCommand(cmd.Name()) {
+		logger.Printf("::: --incremental: inputs whose output is up to date are skipped when running :::")
+	}
+	os.Exit(0)
+	return nil, nil
+}
+
 func validateInput(flags config.GlobalSubCommandFlags, args []string) error {
 	if len(args) > 0 || len(flags.InputDir) > 0 || len(flags.InputFiles) > 0 {
 		return nil
@@ -93,6 +185,14 @@ func (f *GlobalFlagBuilder) WithDir() *GlobalFlagBuilder {
 	return f
 }
 
+// WithDirFilters adds the --recursive, --include and --exclude flags to choose which files of --dir are processed.
+func (f *GlobalFlagBuilder) WithDirFilters() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().BoolVar(&shared.Recursive, "recursive", false, "Usage: --dir ~/walls --recursive Also process the subdirectories of --dir, the output mirrors the directory tree")
+	f.cmd.PersistentFlags().StringArrayVar(&shared.Include, "include", nil, "Usage: --include '*.png' Only process the files of --dir matching the glob (repeatable, supports **, like .gitignore patterns match at any depth unless they start with /)")
+	f.cmd.PersistentFlags().StringArrayVar(&shared.Exclude, "exclude", nil, "Usage: --exclude 'raw/**' Skip the files and folders of --dir matching the glob (repeatable, supports **, like .gitignore patterns match at any depth unless they start with /)")
+	return f
+}
+
 // WithOutput adds the --output flag to specify the output destination.
 func (f *GlobalFlagBuilder) WithOutput() *GlobalFlagBuilder {
 	f.cmd.PersistentFlags().StringVar(&shared.OutputDestination, "output", "", "Usage: --output ~/Folder (works on --dir and --batch also) or --output ~/NewDir/img.png")
@@ -111,15 +211,113 @@ func (f *GlobalFlagBuilder) WithYes() *GlobalFlagBuilder {
 	return f
 }
 
+// WithJobs adds the --jobs flag to limit how many images are processed at once.
+func (f *GlobalFlagBuilder) WithJobs() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().IntVarP(&shared.Jobs, "jobs", "j", 0, "Usage: --jobs 4 Maximum number of images processed concurrently (overrides config, defaults to the number of CPUs)")
+	return f
+}
+
+// WithIncremental adds the --incremental flag to skip the images whose output is already up to date.
+func (f *GlobalFlagBuilder) WithIncremental() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().BoolVar(&shared.Incremental, "incremental", false, "Only process images that changed since the last run, tracked by a "+imageio.ManifestFileName+" file in the output folder")
+	return f
+}
+
+// WithMetadata adds the --keep-metadata and --strip-metadata flags to control the metadata copied from the input.
+func (f *GlobalFlagBuilder) WithMetadata() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().BoolVar(&shared.KeepMetadata, "keep-metadata", false, "Copy the EXIF metadata (camera, date, GPS...) of the input to the output, by default only the color profile is kept")
+	f.cmd.PersistentFlags().BoolVar(&shared.StripMetadata, "strip-metadata", false, "Do not copy any metadata of the input to the output, not even the color profile")
+	return f
+}
+
+// WithColorManagement adds the --color-space and --output-profile flags to convert inputs with an embedded ICC profile.
+func (f *GlobalFlagBuilder) WithColorManagement() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().StringVar(&shared.ColorSpace, "color-space", "srgb", "Usage: --color-space [srgb,display-p3,adobe-rgb,prophoto,none] Working space inputs with an embedded ICC profile are converted to before processing, none disables color management")
+	f.cmd.PersistentFlags().StringVar(&shared.OutputProfile, "output-profile", imageio.OutputProfileTag, "Usage: --output-profile [tag,convert,none] tag the output with the working space profile, convert it back to the profile of the input or write no profile")
+	f.cmd.RegisterFlagCompletionFunc("color-space", colorSpaceCompletion)
+	f.cmd.RegisterFlagCompletionFunc("output-profile", outputProfileCompletion)
+	return f
+}
+
+func colorSpaceCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return append(icc.WorkingSpaceNames(), imageio.ColorManagementNone), cobra.ShellCompDirectiveNoFileComp
+}
+
+func outputProfileCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{imageio.OutputProfileTag, imageio.OutputProfileConvert, imageio.OutputProfileNone}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// WithFrames adds the --frames flag to choose how the frames of animated GIF and WebP inputs are processed.
+func (f *GlobalFlagBuilder) WithFrames() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().StringVar(&shared.Frames, "frames", imageio.FramesAnimate, "Usage: --frames [animate,first,all] Process every frame of animated GIF/WebP inputs and save them as an animated gif (animate, needs a gif output e.g. --format gif), only the first frame (first) or every frame to its own output (all, name_frame001.png...)")
+	f.cmd.RegisterFlagCompletionFunc("frames", framesCompletion)
+	return f
+}
+
+func framesCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{imageio.FramesAnimate, imageio.FramesFirst, imageio.FramesAll}, cobra.ShellCompDirectiveNoFileComp
+}
+
+func formatCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return imageio.SupportedFormats(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// WithRasterSize adds the --width, --height and --dpi flags to choose the size SVG inputs are rasterised at.
+func (f *GlobalFlagBuilder) WithRasterSize() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().IntVar(&shared.RasterWidth, "width", 0, "Usage: --width 3840 Width SVG inputs are rasterised at, the height keeps the aspect ratio unless --height is set")
+	f.cmd.PersistentFlags().IntVar(&shared.RasterHeight, "height", 0, "Usage: --height 2160 Height SVG inputs are rasterised at, the width keeps the aspect ratio unless --width is set")
+	f.cmd.PersistentFlags().Float64Var(&shared.RasterDPI, "dpi", 0, "Usage: --dpi 192 Resolution SVG inputs are rasterised at, 96 is their intrinsic size")
+	return f
+}
+
+// WithDither adds the --dither and --dither-strength flags to choose how images are reduced to a palette.
+func (f *GlobalFlagBuilder) WithDither() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().StringVar(&shared.Dither, "dither", "", "Usage: --dither ["+strings.Join(dither.Modes, ",")+"] Dithering used when reducing the image to a palette, error diffusion (floyd-steinberg, atkinson, sierra) or ordered patterns (bayer2/4/8, blue-noise) for a retro look")
+	f.cmd.PersistentFlags().Float64Var(&shared.DitherStrength, "dither-strength", dither.DefaultStrength, "Usage: --dither-strength 0.5 (0,1] How much of the error is diffused or how strong the ordered pattern is")
+	f.cmd.RegisterFlagCompletionFunc("dither", ditherCompletion)
+	return f
+}
+
+func ditherCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return dither.Modes, cobra.ShellCompDirectiveNoFileComp
+}
+
+// WithNaming adds the --name and --on-collision flags to choose the output names of --dir and --batch.
+func (f *GlobalFlagBuilder) WithNaming() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().StringVar(&shared.NameTemplate, "name", "", "Usage: --name '{stem}-{theme}-{w}x{h}.{ext}' or '{date}/{stem}.{ext}' Output name of every --dir or --batch image relative to the output folder, placeholders: {"+strings.Join(imageio.NameVariables, "}, {")+"}")
+	f.cmd.PersistentFlags().StringVar(&shared.OnCollision, "on-collision", imageio.CollisionOverwrite, "Usage: --on-collision [overwrite,skip,suffix] What to do when an output already exists or two images get the same name, suffix writes name-1.png, name-2.png...")
+	f.cmd.RegisterFlagCompletionFunc("on-collision", onCollisionCompletion)
+	return f
+}
+
+func onCollisionCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{imageio.CollisionOverwrite, imageio.CollisionSkip, imageio.CollisionSuffix}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// WithErrorPolicy adds the --continue-on-error, --failure-report, --retries and --retry-delay flags to choose what happens when some images of a batch fail.
+func (f *GlobalFlagBuilder) WithErrorPolicy() *GlobalFlagBuilder {
+	f.cmd.PersistentFlags().BoolVar(&shared.ContinueOnError, "continue-on-error", false, fmt.Sprintf("Keep the images that were processed when others fail and exit with %d (some failed) or %d (all failed) instead of 1", ExitPartialFailure, ExitAllFailed))
+	f.cmd.PersistentFlags().StringVar(&shared.FailureReport, "failure-report", "", "Usage: --failure-report failed.txt Write every input that failed and its error to the file, as json when it ends in .json")
+	f.cmd.PersistentFlags().IntVar(&shared.Retries, "retries", 0, "Usage: --retries 3 Retry inputs that failed for a transient reason, e.g. the upscaler or pngquant crashing or a url that could not be fetched")
+	f.cmd.PersistentFlags().DurationVar(&shared.RetryDelay, "retry-delay", image.DefaultRetryDelay, "Usage: --retry-delay 500ms Wait before the first retry, doubled after each one")
+	return f
+}
+
 // addGlobalFlags adds all common global flags to the command.
 func addGlobalFlags(cmd *cobra.Command) {
-	addFlags(cmd).WithBatch().WithDir().WithOutput().WithPreview().WithYes()
+	addFlags(cmd).WithBatch().WithDir().WithDirFilters().WithOutput().WithPreview().WithYes().WithJobs().WithIncremental().WithMetadata().WithColorManagement().WithFrames().WithNaming().WithErrorPolicy()
 }
 
 // Configure logger, spinner with quiet modes for Unix pipes and redirections and validates flags
 func initCli(cmd *cobra.Command, args []string) error {
+	// gif and stack read a stream of images or paths with "-", the result goes to stdout unless --output is given
+	if imageio.IsMultiInputSingleOutputCommand(cmd.Name()) && len(args) > 0 && args[0] == "-" && shared.OutputDestination == "" {
+		shared.OutputDestination = "-"
+	}
+
 	logger.SetQuiet(imageio.IsStdoutOutput(shared, args))
-	utils.SetSpinnerQuiet(imageio.IsStdoutOutput(shared, args))
+	logger.SetJSON(shared.JSON)
+	utils.SetSpinnerQuiet(imageio.IsStdoutOutput(shared, args) || shared.JSON)
 
 	shared.InputFiles = config.ExpandTilde(shared.InputFiles)
 	if shared.InputDir != "" {
@@ -129,6 +327,10 @@ func initCli(cmd *cobra.Command, args []string) error {
 		shared.OutputDestination = config.ExpandTilde([]string{shared.OutputDestination})[0]
 	}
 
+	if shared.Jobs > 0 {
+		config.GowallConfig.Concurrency = shared.Jobs
+	}
+
 	config.GlobalFlags = shared
 	return validateFlagsCompatibility(cmd, args)
 }
@@ -162,7 +364,7 @@ var rootCmd = &cobra.Command{
 			path, err := imageio.SaveUrlAsImg(url)
 			utils.HandleError(err)
 			utils.Spinner.Stop()
-			err = image.OpenImageInViewer(path)
+			err = image.OpenImageInViewer(path, previewOptions(cmd, shared))
 			utils.HandleError(err)
 
 			ok := utils.Confirm("Do you want to download this image?")
@@ -190,15 +392,26 @@ func Execute() {
 	// Prevents Cobra from printing errors so we can wrap them into our logs
 	rootCmd.SilenceErrors = true
 
-	err := rootCmd.Execute()
+	// The first Ctrl-C cancels the context so batches stop gracefully, a second one kills gowall.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		// os.Exit(1)
 		utils.HandleError(err, "Error")
 	}
+	exitWithCode()
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "show gowall version")
+	rootCmd.PersistentFlags().BoolVar(&shared.DryRun, "dry-run", false, "Print what would be read and written (input, output, format) without loading, processing or saving any image. No input is read: {w} and {h} of --name stay unresolved, --frames all and --incremental only apply when running")
+	rootCmd.PersistentFlags().BoolVar(&shared.JSON, "json", false, "Emit machine readable json lines (one event per processed file) and json documents for commands that print data")
 	rootCmd.Flags().BoolVarP(&wallOfTheDayFlag, "wall", "w", false, "fetches the wallpaper of the day!")
 }
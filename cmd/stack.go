@@ -11,7 +11,6 @@ import (
 	"github.com/Achno/gowall/config"
 	cpkg "github.com/Achno/gowall/internal/backends/color"
 	"github.com/Achno/gowall/internal/image"
-	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
@@ -19,9 +18,11 @@ import (
 
 func BuildStackCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "stack [--batch,--dir]",
+		Use:   "stack [--batch,--dir] or stack -",
 		Short: "Stack multiple images into a single image",
-		Long:  "Stack multiple images in a horizontal, vertical, or NxM grid layout.",
+		Long: `Stack multiple images in a horizontal, vertical, or NxM grid layout.
+With - the images (concatenated or a list of paths, one per line) are read from stdin and the result is written to stdout.
+Example: ls *.png | gowall stack - --layout 2x2 > grid.png`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return ValidateParseStackCmd(cmd, shared, args)
 		},
@@ -52,7 +53,7 @@ func BuildStackCmd() *cobra.Command {
 func RunStackCmd(cmd *cobra.Command, args []string) {
 	logger.Print("Stacking images...")
 
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	layout, err := cmd.Flags().GetString("layout")
@@ -78,13 +79,13 @@ func RunStackCmd(cmd *cobra.Command, args []string) {
 		ResizeMode:      strings.ToLower(resizeMode),
 	}
 
-	outputPath, err := image.MultiProcessImgs(processor, imageOps, image.MultiProcessOptions{
+	outputPath, err := image.MultiProcessImgs(cmd.Context(), processor, imageOps, image.MultiProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
 	utils.HandleError(err, "Error")
 
-	openImageInViewer(cmd, shared, args, outputPath)
+	openImageInViewer(cmd, shared, args, []string{outputPath})
 }
 
 func ValidateParseStackCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -92,8 +93,8 @@ func ValidateParseStackCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlag
 		return err
 	}
 
-	if len(args) > 0 {
-		return fmt.Errorf("use --batch or --dir with stack")
+	if len(args) > 1 || (len(args) == 1 && args[0] != "-") {
+		return fmt.Errorf("use --batch, --dir or - (images or paths piped to stdin) with stack")
 	}
 
 	layout, _ := cmd.Flags().GetString("layout")
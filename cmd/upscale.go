@@ -48,7 +48,7 @@ func BuildUpscaleCmd() *cobra.Command {
 }
 
 func RunUpscaleCmd(cmd *cobra.Command, args []string) {
-	imageOps, err := imageio.DetermineImageOperations(shared, args, cmd)
+	imageOps, err := determineImageOperations(cmd, args)
 	utils.HandleError(err, "Error")
 
 	scale, err := cmd.Flags().GetInt("scale")
@@ -57,21 +57,14 @@ func RunUpscaleCmd(cmd *cobra.Command, args []string) {
 	utils.HandleError(err, "Error")
 
 	logger.Print("Upscaling images...")
-	processor := &image.UpscaleProcessor{
-		Scale:     scale,
-		ModelName: modelName,
-	}
+	processor := newUpscaleProcessor(scale, modelName)
 
-	processedImages, err := image.ProcessImgs(processor, imageOps, image.ProcessOptions{
+	processedImages := processImgs(cmd, processor, imageOps, image.ProcessOptions{
 		Theme:      "",
 		OnComplete: nil,
 	})
-	utils.HandleError(err, "Error")
 
-	if err != nil {
-		logger.Error(err, "The following images had errors while processing")
-	}
-	openImageInViewer(cmd, shared, args, processedImages[0])
+	openImageInViewer(cmd, shared, args, processedImages)
 }
 
 func ValidateParseUpscaleCmd(cmd *cobra.Command, flags config.GlobalSubCommandFlags, args []string) error {
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +16,15 @@ var SupportedImageExtensions = map[string]bool{
 	".jpg":  true,
 	".webp": true,
 	".avif": true,
+	".gif":  true,
+	".tiff": true,
+	".tif":  true,
+	".bmp":  true,
+	".qoi":  true,
+	".ppm":  true,
+	".pgm":  true,
+	".pnm":  true,
+	".svg":  true,
 }
 
 var SupportedTextExtensions = map[string]bool{
@@ -28,6 +38,29 @@ type GlobalSubCommandFlags struct {
 	Format            string
 	PreviewFlag       string
 	Yes               bool
+	Jobs              int
+	Incremental       bool
+	Recursive         bool
+	Include           []string
+	Exclude           []string
+	JSON              bool
+	DryRun            bool
+	KeepMetadata      bool
+	StripMetadata     bool
+	ColorSpace        string
+	OutputProfile     string
+	Frames            string
+	RasterWidth       int
+	RasterHeight      int
+	RasterDPI         float64
+	Dither            string
+	DitherStrength    float64
+	NameTemplate      string
+	OnCollision       string
+	ContinueOnError   bool
+	FailureReport     string
+	Retries           int
+	RetryDelay        time.Duration
 }
 
 type themeWrapper struct {
@@ -40,12 +73,15 @@ type Options struct {
 	InlineImagePreview     bool           `yaml:"InlineImagePreview"`
 	ImagePreviewBackend    string         `yaml:"ImagePreviewBackend"`
 	ColorCorrectionBackend string         `yaml:"ColorCorrectionBackend"`
+	CLUTLevel              int            `yaml:"CLUTLevel"`
+	ColorDistance          string         `yaml:"ColorDistance"`
 	OutputFolder           string         `yaml:"OutputFolder"`
 	Themes                 []themeWrapper `yaml:"themes"`
 	EnvConfig              *EnvConfig
 	EnvFilePath            string `yaml:"EnvFilePath"`
 	OnnxRuntimeFolderPath  string `yaml:"OnnxRuntimeFolderPath"`
 	OnnxModelFolderPath    string `yaml:"OnnxModelFolderPath"`
+	Concurrency            int    `yaml:"Concurrency"`
 }
 
 func (o *Options) Resolve() error {
@@ -13,6 +13,9 @@ require (
 	github.com/muesli/gamut v0.3.1
 	github.com/openai/openai-go v0.1.0-beta.10
 	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
 	github.com/synoptiq/go-fluxus v1.1.1
 	github.com/theckman/yacspin v0.13.12
 	github.com/yalue/onnxruntime_go v1.21.0
@@ -44,7 +47,6 @@ require (
 	github.com/muesli/clusters v0.0.0-20200529215643-2700303c1762 // indirect
 	github.com/muesli/kmeans v0.3.1 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/tetratelabs/wazero v1.9.0 // indirect
 	github.com/tidwall/gjson v1.14.4 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
@@ -0,0 +1,312 @@
+// Package pnm implements the Netpbm formats PBM (P1, P4), PGM (P2, P5) and PPM (P3, P6).
+// Importing it registers the decoders with image.Decode, 16-bit files (maxval > 255) keep their precision.
+package pnm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// Formats written by Encode
+const (
+	FormatPPM = "ppm" // color, P6
+	FormatPGM = "pgm" // grayscale, P5
+	FormatPNM = "pnm" // P5 for grayscale images and P6 otherwise
+)
+
+// images bigger than this are rejected instead of allocating gigabytes for a corrupt header
+const maxPixels = 400_000_000
+
+var errInvalid = errors.New("pnm: invalid format")
+
+func init() {
+	for _, magic := range []string{"P1", "P2", "P3", "P4", "P5", "P6"} {
+		image.RegisterFormat("pnm", magic, Decode, DecodeConfig)
+	}
+}
+
+type header struct {
+	magic         byte // '1' to '6'
+	width, height int
+	maxval        int
+}
+
+func (h header) gray() bool {
+	return h.magic != '3' && h.magic != '6'
+}
+
+func (h header) colorModel() color.Model {
+	switch {
+	case h.gray() && h.maxval > 255:
+		return color.Gray16Model
+	case h.gray():
+		return color.GrayModel
+	case h.maxval > 255:
+		return color.RGBA64Model
+	}
+	return color.RGBAModel
+}
+
+// readToken returns the next whitespace separated token, skipping # comments
+func readToken(r *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			return "", err
+		}
+		switch {
+		case b == '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
+	}
+}
+
+func readInt(r *bufio.Reader) (int, error) {
+	token, err := readToken(r)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(token)
+	if err != nil || v < 0 {
+		return 0, errInvalid
+	}
+	return v, nil
+}
+
+// readHeader parses the header, the single whitespace after it is consumed so r points to the raster
+func readHeader(r *bufio.Reader) (header, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return header{}, err
+	}
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return header{}, errInvalid
+	}
+
+	h := header{magic: magic[1], maxval: 1}
+	var err error
+	if h.width, err = readInt(r); err != nil {
+		return header{}, err
+	}
+	if h.height, err = readInt(r); err != nil {
+		return header{}, err
+	}
+	if h.magic != '1' && h.magic != '4' {
+		if h.maxval, err = readInt(r); err != nil {
+			return header{}, err
+		}
+	}
+
+	if h.width == 0 || h.height == 0 || h.width*h.height > maxPixels || h.maxval == 0 || h.maxval > 65535 {
+		return header{}, errInvalid
+	}
+	return h, nil
+}
+
+// DecodeConfig returns the color model and dimensions of a Netpbm image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// Decode reads a Netpbm image as an *image.Gray, *image.Gray16, *image.RGBA or *image.RGBA64 depending on its type and maxval
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := 1
+	if !h.gray() {
+		channels = 3
+	}
+	samples := make([]int, h.width*h.height*channels)
+
+	switch h.magic {
+	case '1', '2', '3':
+		for i := range samples {
+			if h.magic == '1' {
+				samples[i], err = readBit(br)
+			} else {
+				samples[i], err = readInt(br)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("pnm: truncated image: %w", err)
+			}
+		}
+	case '4':
+		rowBytes := (h.width + 7) / 8
+		row := make([]byte, rowBytes)
+		for y := range h.height {
+			if _, err := io.ReadFull(br, row); err != nil {
+				return nil, fmt.Errorf("pnm: truncated image: %w", err)
+			}
+			for x := range h.width {
+				samples[y*h.width+x] = int(row[x/8]>>(7-x%8)) & 1
+			}
+		}
+	default:
+		bytesPerSample := 1
+		if h.maxval > 255 {
+			bytesPerSample = 2
+		}
+		raster := make([]byte, len(samples)*bytesPerSample)
+		if _, err := io.ReadFull(br, raster); err != nil {
+			return nil, fmt.Errorf("pnm: truncated image: %w", err)
+		}
+		for i := range samples {
+			if bytesPerSample == 2 {
+				samples[i] = int(raster[2*i])<<8 | int(raster[2*i+1])
+			} else {
+				samples[i] = int(raster[i])
+			}
+		}
+	}
+
+	// in PBM files 1 is black
+	if h.magic == '1' || h.magic == '4' {
+		for i, s := range samples {
+			samples[i] = 1 - s
+		}
+	}
+
+	return toImage(h, samples), nil
+}
+
+func readBit(r *bufio.Reader) (int, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case '0', '1':
+			return int(b - '0'), nil
+		case '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// toImage scales the samples from [0,maxval] to the range of the color model
+func toImage(h header, samples []int) image.Image {
+	rect := image.Rect(0, 0, h.width, h.height)
+	scale := func(v, max int) int {
+		return min(v, h.maxval) * max / h.maxval
+	}
+
+	switch h.colorModel() {
+	case color.GrayModel:
+		img := image.NewGray(rect)
+		for i, s := range samples {
+			img.Pix[i] = uint8(scale(s, 255))
+		}
+		return img
+	case color.Gray16Model:
+		img := image.NewGray16(rect)
+		for i, s := range samples {
+			v := scale(s, 65535)
+			img.Pix[2*i], img.Pix[2*i+1] = uint8(v>>8), uint8(v)
+		}
+		return img
+	case color.RGBA64Model:
+		img := image.NewRGBA64(rect)
+		for i := 0; i < len(samples)/3; i++ {
+			for c := range 3 {
+				v := scale(samples[3*i+c], 65535)
+				img.Pix[8*i+2*c], img.Pix[8*i+2*c+1] = uint8(v>>8), uint8(v)
+			}
+			img.Pix[8*i+6], img.Pix[8*i+7] = 0xff, 0xff
+		}
+		return img
+	default:
+		img := image.NewRGBA(rect)
+		for i := 0; i < len(samples)/3; i++ {
+			for c := range 3 {
+				img.Pix[4*i+c] = uint8(scale(samples[3*i+c], 255))
+			}
+			img.Pix[4*i+3] = 0xff
+		}
+		return img
+	}
+}
+
+// Encode writes the image as a binary PPM (P6) or PGM (P5) according to format, see FormatPNM.
+// Images with more than 8 bits per channel are written with a maxval of 65535, the alpha channel is dropped.
+func Encode(w io.Writer, img image.Image, format string) error {
+	gray := false
+	switch format {
+	case FormatPGM:
+		gray = true
+	case FormatPNM:
+		switch img.(type) {
+		case *image.Gray, *image.Gray16:
+			gray = true
+		}
+	}
+
+	sixteen := false
+	switch img.(type) {
+	case *image.Gray16, *image.RGBA64, *image.NRGBA64:
+		sixteen = true
+	}
+
+	bounds := img.Bounds()
+	magic, maxval := "P6", 255
+	if gray {
+		magic = "P5"
+	}
+	if sixteen {
+		maxval = 65535
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\n%d %d\n%d\n", magic, bounds.Dx(), bounds.Dy(), maxval)
+
+	write := func(v uint16) {
+		if sixteen {
+			bw.WriteByte(uint8(v >> 8))
+			bw.WriteByte(uint8(v))
+		} else {
+			bw.WriteByte(uint8(v >> 8))
+		}
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// the non premultiplied color, pnm has no alpha channel
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			if gray {
+				write(color.Gray16Model.Convert(color.NRGBA64{R: c.R, G: c.G, B: c.B, A: 0xffff}).(color.Gray16).Y)
+				continue
+			}
+			write(c.R)
+			write(c.G)
+			write(c.B)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,85 @@
+package pnm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rect := image.Rect(0, 0, 13, 7)
+	rgba := image.NewRGBA(rect)
+	rgba64 := image.NewRGBA64(rect)
+	gray := image.NewGray(rect)
+	gray16 := image.NewGray16(rect)
+	for y := range rect.Dy() {
+		for x := range rect.Dx() {
+			rgba.SetRGBA(x, y, color.RGBA{R: uint8(x * 19), G: uint8(y * 36), B: uint8(x * y), A: 0xff})
+			rgba64.SetRGBA64(x, y, color.RGBA64{R: uint16(x * 5003), G: uint16(y * 9001), B: uint16(x * y * 701), A: 0xffff})
+			gray.SetGray(x, y, color.Gray{Y: uint8(x*19 + y)})
+			gray16.SetGray16(x, y, color.Gray16{Y: uint16(x*5003 + y)})
+		}
+	}
+
+	tests := []struct {
+		name   string
+		img    image.Image
+		format string
+		magic  string
+	}{
+		{"ppm", rgba, FormatPPM, "P6\n13 7\n255\n"},
+		{"ppm 16-bit", rgba64, FormatPNM, "P6\n13 7\n65535\n"},
+		{"pgm", gray, FormatPNM, "P5\n13 7\n255\n"},
+		{"pgm 16-bit", gray16, FormatPGM, "P5\n13 7\n65535\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Encode(&buf, tt.img, tt.format); err != nil {
+			t.Fatalf("%s: Encode: %v", tt.name, err)
+		}
+		if !strings.HasPrefix(buf.String(), tt.magic) {
+			t.Errorf("%s: header %q, want %q", tt.name, buf.String()[:len(tt.magic)], tt.magic)
+		}
+		decoded, format, err := image.Decode(&buf)
+		if err != nil || format != "pnm" {
+			t.Fatalf("%s: image.Decode = %q, %v", tt.name, format, err)
+		}
+		for y := range rect.Dy() {
+			for x := range rect.Dx() {
+				got, want := color.RGBA64Model.Convert(decoded.At(x, y)), color.RGBA64Model.Convert(tt.img.At(x, y))
+				if got != want {
+					t.Fatalf("%s: pixel %d,%d = %v, want %v", tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodePlain(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []uint8 // gray values of the pixels
+	}{
+		{"pbm", "P1\n# comment\n3 2\n1 0 1\n0 1 0\n", []uint8{0, 255, 0, 255, 0, 255}},
+		{"pbm binary", "P4\n3 2\n\xa0\x40", []uint8{0, 255, 0, 255, 0, 255}},
+		{"pgm", "P2\n2 2\n4\n0 1\n2 4\n", []uint8{0, 63, 127, 255}},
+	}
+
+	for _, tt := range tests {
+		img, err := Decode(strings.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tt.name, err)
+		}
+		bounds := img.Bounds()
+		for i, want := range tt.want {
+			x, y := i%bounds.Dx(), i/bounds.Dx()
+			if got := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y; got != want {
+				t.Errorf("%s: pixel %d,%d = %d, want %d", tt.name, x, y, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package qoi implements the "Quite OK Image" format (https://qoiformat.org/qoi-specification.pdf).
+// Importing it registers the decoder with image.Decode.
+package qoi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+const (
+	magic = "qoif"
+
+	opIndex = 0x00 // 00xxxxxx
+	opDiff  = 0x40 // 01xxxxxx
+	opLuma  = 0x80 // 10xxxxxx
+	opRun   = 0xc0 // 11xxxxxx
+	opRGB   = 0xfe
+	opRGBA  = 0xff
+	opMask  = 0xc0
+
+	headerSize = 14
+	// images bigger than this are rejected instead of allocating gigabytes for a corrupt header
+	maxPixels = 400_000_000
+)
+
+var endMarker = []byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+var errInvalid = errors.New("qoi: invalid format")
+
+func init() {
+	image.RegisterFormat("qoi", magic, Decode, DecodeConfig)
+}
+
+type pixel struct{ r, g, b, a uint8 }
+
+func (p pixel) hash() int {
+	return (int(p.r)*3 + int(p.g)*5 + int(p.b)*7 + int(p.a)*11) % 64
+}
+
+type header struct {
+	width, height uint32
+	channels      uint8
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var buf [headerSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return header{}, err
+	}
+	if string(buf[:4]) != magic {
+		return header{}, errInvalid
+	}
+	h := header{
+		width:    binary.BigEndian.Uint32(buf[4:]),
+		height:   binary.BigEndian.Uint32(buf[8:]),
+		channels: buf[12],
+	}
+	if h.width == 0 || h.height == 0 || uint64(h.width)*uint64(h.height) > maxPixels || (h.channels != 3 && h.channels != 4) {
+		return header{}, errInvalid
+	}
+	return h, nil
+}
+
+// DecodeConfig returns the dimensions of a QOI image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(h.width), Height: int(h.height)}, nil
+}
+
+// Decode reads a QOI image as an *image.NRGBA
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(h.width), int(h.height)))
+	var index [64]pixel
+	px := pixel{a: 255}
+	run := 0
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		if run > 0 {
+			run--
+		} else {
+			b1, err := br.ReadByte()
+			if err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+
+			switch {
+			case b1 == opRGB:
+				if err := readBytes(br, &px.r, &px.g, &px.b); err != nil {
+					return nil, err
+				}
+			case b1 == opRGBA:
+				if err := readBytes(br, &px.r, &px.g, &px.b, &px.a); err != nil {
+					return nil, err
+				}
+			case b1&opMask == opIndex:
+				px = index[b1]
+			case b1&opMask == opDiff:
+				px.r += (b1>>4)&0x03 - 2
+				px.g += (b1>>2)&0x03 - 2
+				px.b += b1&0x03 - 2
+			case b1&opMask == opLuma:
+				b2, err := br.ReadByte()
+				if err != nil {
+					return nil, io.ErrUnexpectedEOF
+				}
+				vg := b1&0x3f - 32
+				px.r += vg - 8 + (b2>>4)&0x0f
+				px.g += vg
+				px.b += vg - 8 + b2&0x0f
+			case b1&opMask == opRun:
+				run = int(b1 & 0x3f)
+			}
+			index[px.hash()] = px
+		}
+
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = px.r, px.g, px.b, px.a
+	}
+	return img, nil
+}
+
+func readBytes(r io.ByteReader, dst ...*uint8) error {
+	for _, d := range dst {
+		b, err := r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		*d = b
+	}
+	return nil
+}
+
+// Encode writes the image in the QOI format, with an alpha channel only if the image has transparent pixels
+func Encode(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	if bounds.Empty() || uint64(bounds.Dx())*uint64(bounds.Dy()) > maxPixels {
+		return errors.New("qoi: invalid image size")
+	}
+
+	src, ok := img.(*image.NRGBA)
+	if !ok || src.Rect.Min != (image.Point{}) || src.Stride != 4*bounds.Dx() {
+		src = image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+
+	channels := uint8(3)
+	if !src.Opaque() {
+		channels = 4
+	}
+
+	bw := bufio.NewWriter(w)
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	binary.BigEndian.PutUint32(hdr[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(hdr[8:], uint32(bounds.Dy()))
+	hdr[12] = channels
+	hdr[13] = 0 // sRGB with linear alpha
+	bw.Write(hdr[:])
+
+	var index [64]pixel
+	prev := pixel{a: 255}
+	run := 0
+	last := len(src.Pix) - 4
+
+	for i := 0; i < len(src.Pix); i += 4 {
+		px := pixel{src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}
+
+		if px == prev {
+			run++
+			if run == 62 || i == last {
+				bw.WriteByte(opRun | byte(run-1))
+				run = 0
+			}
+			continue
+		}
+
+		if run > 0 {
+			bw.WriteByte(opRun | byte(run-1))
+			run = 0
+		}
+
+		hash := px.hash()
+		switch {
+		case index[hash] == px:
+			bw.WriteByte(opIndex | byte(hash))
+		case px.a == prev.a:
+			index[hash] = px
+			vr := int8(px.r - prev.r)
+			vg := int8(px.g - prev.g)
+			vb := int8(px.b - prev.b)
+			vgr := vr - vg
+			vgb := vb - vg
+
+			switch {
+			case vr > -3 && vr < 2 && vg > -3 && vg < 2 && vb > -3 && vb < 2:
+				bw.WriteByte(opDiff | byte(vr+2)<<4 | byte(vg+2)<<2 | byte(vb+2))
+			case vgr > -9 && vgr < 8 && vg > -33 && vg < 32 && vgb > -9 && vgb < 8:
+				bw.WriteByte(opLuma | byte(vg+32))
+				bw.WriteByte(byte(vgr+8)<<4 | byte(vgb+8))
+			default:
+				bw.Write([]byte{opRGB, px.r, px.g, px.b})
+			}
+		default:
+			index[hash] = px
+			bw.Write([]byte{opRGBA, px.r, px.g, px.b, px.a})
+		}
+		prev = px
+	}
+
+	bw.Write(endMarker)
+	return bw.Flush()
+}
@@ -0,0 +1,55 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	opaque := image.NewNRGBA(image.Rect(0, 0, 37, 21))
+	transparent := image.NewNRGBA(image.Rect(0, 0, 37, 21))
+	for y := range 21 {
+		for x := range 37 {
+			// runs, small diffs, lumas and full colors all show up in a gradient with some flat areas
+			c := color.NRGBA{R: uint8(x * 7), G: uint8(y * 12), B: uint8((x / 4) * 50), A: 0xff}
+			opaque.SetNRGBA(x, y, c)
+			c.A = uint8(x * y)
+			transparent.SetNRGBA(x, y, c)
+		}
+	}
+
+	for name, img := range map[string]*image.NRGBA{"opaque": opaque, "transparent": transparent} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img); err != nil {
+			t.Fatalf("%s: Encode: %v", name, err)
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil || cfg.Width != 37 || cfg.Height != 21 {
+			t.Errorf("%s: DecodeConfig = %dx%d, %v, want 37x21", name, cfg.Width, cfg.Height, err)
+		}
+		decoded, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", name, err)
+		}
+		for y := range 21 {
+			for x := range 37 {
+				want := img.NRGBAAt(x, y)
+				if got := color.NRGBAModel.Convert(decoded.At(x, y)); got != want {
+					t.Fatalf("%s: pixel %d,%d = %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeRegistered(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if _, format, err := image.Decode(&buf); err != nil || format != "qoi" {
+		t.Errorf("image.Decode = %q, %v, want qoi", format, err)
+	}
+}
@@ -0,0 +1,187 @@
+package svg
+
+import (
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// the properties that hold a paint color, as presentation attributes (fill="#fff") or CSS declarations (fill: #fff)
+const colorProperties = `fill|stroke|stop-color|flood-color|lighting-color|color`
+
+var (
+	attributeRe   = regexp.MustCompile(`(^|\s)(` + colorProperties + `)(\s*=\s*)("[^"]*"|'[^']*')`)
+	declarationRe = regexp.MustCompile(`(^|[\s;{"'])(` + colorProperties + `)(\s*:\s*)([^;"'}<]+)`)
+	rgbRe         = regexp.MustCompile(`^rgba?\(\s*([^,\s]+)\s*,?\s*([^,\s]+)\s*,?\s*([^,\s)/]+)\s*(?:[,/]\s*([^)\s]+)\s*)?\)$`)
+)
+
+// Recolor rewrites every color of the document (fill, stroke and gradient stops, in attributes, style attributes and
+// style elements) with the color returned by mapColor. The rest of the document is kept byte for byte,
+// values that are not a color (none, currentColor, url(#gradient)...) are left untouched.
+func Recolor(data []byte, mapColor func(color.Color) color.Color) []byte {
+	data = attributeRe.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := attributeRe.FindSubmatch(match)
+		quoted := string(groups[4])
+		value := recolorValue(quoted[1:len(quoted)-1], mapColor)
+		return fmt.Appendf(nil, "%s%s%s%c%s%c", groups[1], groups[2], groups[3], quoted[0], value, quoted[0])
+	})
+
+	return declarationRe.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := declarationRe.FindSubmatch(match)
+		value := recolorValue(string(groups[4]), mapColor)
+		return fmt.Appendf(nil, "%s%s%s%s", groups[1], groups[2], groups[3], value)
+	})
+}
+
+// recolorValue maps the color at the start of a value, the rest (e.g. !important) and the surrounding spaces are kept
+func recolorValue(value string, mapColor func(color.Color) color.Color) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return value
+	}
+	token, rest := trimmed, ""
+	if !strings.HasPrefix(trimmed, "rgb") {
+		if i := strings.IndexAny(trimmed, " \t"); i >= 0 {
+			token, rest = trimmed[:i], trimmed[i:]
+		}
+	} else if i := strings.Index(trimmed, ")"); i >= 0 {
+		token, rest = trimmed[:i+1], trimmed[i+1:]
+	}
+
+	c, alpha, ok := parseColor(token)
+	if !ok {
+		return value
+	}
+	r, g, b, _ := mapColor(c).RGBA()
+	if uint8(r>>8) == c.R && uint8(g>>8) == c.G && uint8(b>>8) == c.B {
+		return value
+	}
+	newColor := fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+	if alpha != "" {
+		newColor = fmt.Sprintf("rgba(%d, %d, %d, %s)", r>>8, g>>8, b>>8, alpha)
+	}
+
+	start := strings.Index(value, trimmed)
+	return value[:start] + newColor + rest + value[start+len(trimmed):]
+}
+
+// parseColor parses a hex, rgb() or named color, the alpha of rgba() and #rrggbbaa colors is returned as written
+func parseColor(s string) (color.RGBA, string, bool) {
+	lower := strings.ToLower(s)
+
+	if strings.HasPrefix(lower, "#") {
+		hex := lower[1:]
+		alpha := ""
+		switch len(hex) {
+		case 3, 4:
+			expanded := ""
+			for _, ch := range hex {
+				expanded += string(ch) + string(ch)
+			}
+			hex = expanded
+		case 6, 8:
+		default:
+			return color.RGBA{}, "", false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.RGBA{}, "", false
+		}
+		if len(hex) == 8 {
+			alpha = strconv.FormatFloat(float64(v&0xff)/255, 'f', 3, 64)
+			v >>= 8
+		}
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, alpha, true
+	}
+
+	if m := rgbRe.FindStringSubmatch(lower); m != nil {
+		var channels [3]uint8
+		for i, component := range m[1:4] {
+			v, ok := parseComponent(component)
+			if !ok {
+				return color.RGBA{}, "", false
+			}
+			channels[i] = v
+		}
+		return color.RGBA{R: channels[0], G: channels[1], B: channels[2], A: 0xff}, m[4], true
+	}
+
+	if c, ok := colornames.Map[lower]; ok {
+		return c, "", true
+	}
+	return color.RGBA{}, "", false
+}
+
+// parseComponent parses a channel of rgb(), either 0-255 or a percentage
+func parseComponent(s string) (uint8, bool) {
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s, scale = strings.TrimSuffix(s, "%"), 255.0/100
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint8(max(0, min(255, v*scale+0.5))), true
+}
+
+// opaqueColors rewrites the colors with an alpha channel (rgba(), #rrggbbaa), which oksvg can't parse,
+// as an opaque color followed by the matching opacity property, e.g. fill="#ff00ff" fill-opacity="0.5".
+func opaqueColors(data []byte) []byte {
+	data = attributeRe.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := attributeRe.FindSubmatch(match)
+		quoted := string(groups[4])
+		hex, opacity, ok := splitAlpha(quoted[1 : len(quoted)-1])
+		if !ok {
+			return match
+		}
+		out := fmt.Appendf(nil, "%s%s%s%c%s%c", groups[1], groups[2], groups[3], quoted[0], hex, quoted[0])
+		if property := opacityProperty(string(groups[2])); property != "" {
+			out = fmt.Appendf(out, " %s=%c%s%c", property, quoted[0], opacity, quoted[0])
+		}
+		return out
+	})
+
+	return declarationRe.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := declarationRe.FindSubmatch(match)
+		hex, opacity, ok := splitAlpha(string(groups[4]))
+		if !ok {
+			return match
+		}
+		out := fmt.Appendf(nil, "%s%s%s%s", groups[1], groups[2], groups[3], hex)
+		if property := opacityProperty(string(groups[2])); property != "" {
+			out = fmt.Appendf(out, ";%s:%s", property, opacity)
+		}
+		return out
+	})
+}
+
+// splitAlpha splits a color with an alpha channel into its opaque hex color and its opacity in [0,1]
+func splitAlpha(value string) (string, string, bool) {
+	c, alpha, ok := parseColor(strings.TrimSpace(value))
+	if !ok || alpha == "" {
+		return "", "", false
+	}
+	opacity, err := strconv.ParseFloat(strings.TrimSuffix(alpha, "%"), 64)
+	if err != nil {
+		return "", "", false
+	}
+	if strings.HasSuffix(alpha, "%") {
+		opacity /= 100
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B), strconv.FormatFloat(max(0, min(1, opacity)), 'f', -1, 64), true
+}
+
+func opacityProperty(property string) string {
+	switch property {
+	case "fill", "stroke":
+		return property + "-opacity"
+	case "stop-color", "flood-color":
+		return strings.TrimSuffix(property, "-color") + "-opacity"
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+// Package svg rasterises SVG documents at a requested size and rewrites the colors of their shapes,
+// so vector wallpapers can be processed like any other image or themed without losing their vectors.
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+const (
+	// DefaultDPI is the resolution of CSS pixels, an SVG without --dpi is rasterised at one pixel per user unit
+	DefaultDPI = 96
+	// images bigger than this are rejected instead of allocating gigabytes for a huge --dpi
+	maxPixels = 400_000_000
+)
+
+// the size of an SVG without width, height or viewBox, like browsers do
+const defaultWidth, defaultHeight = 300, 150
+
+// Options is the size an SVG is rasterised at, zero values are unset.
+// With only one of Width and Height the other one keeps the aspect ratio, with both the drawing is centered in them.
+// DPI scales the intrinsic size of the document (its width and height attributes or its viewBox).
+type Options struct {
+	Width  int
+	Height int
+	DPI    float64
+}
+
+// IsSVG reports whether data looks like an SVG document
+func IsSVG(data []byte) bool {
+	head := data[:min(len(data), 4096)]
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	return bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<svg"))
+}
+
+// Rasterise draws the SVG document on a transparent image according to opts.
+// Elements oksvg doesn't support (text, filters, masks...) are skipped.
+func Rasterise(data []byte, opts Options) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(opaqueColors(data)), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, err
+	}
+
+	intrinsicW, intrinsicH := intrinsicSize(data)
+	viewW, viewH := icon.ViewBox.W, icon.ViewBox.H
+	switch {
+	case intrinsicW <= 0 || intrinsicH <= 0:
+		intrinsicW, intrinsicH = viewW, viewH
+	case viewW <= 0 || viewH <= 0:
+		viewW, viewH = intrinsicW, intrinsicH
+	}
+	if intrinsicW <= 0 || intrinsicH <= 0 {
+		intrinsicW, intrinsicH = defaultWidth, defaultHeight
+		viewW, viewH = intrinsicW, intrinsicH
+	}
+
+	width, height := rasterSize(intrinsicW, intrinsicH, opts)
+	if width*height > maxPixels {
+		return nil, errors.New("svg: the requested size is too big")
+	}
+
+	// keep the aspect ratio of the viewBox and center it (preserveAspectRatio="xMidYMid meet")
+	scale := math.Min(float64(width)/viewW, float64(height)/viewH)
+	drawW, drawH := viewW*scale, viewH*scale
+	icon.SetTarget((float64(width)-drawW)/2, (float64(height)-drawH)/2, drawW, drawH)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+	return img, nil
+}
+
+// rasterSize returns the pixel size of the image for an intrinsic size in CSS pixels
+func rasterSize(intrinsicW, intrinsicH float64, opts Options) (int, int) {
+	round := func(v float64) int {
+		return max(1, int(math.Round(v)))
+	}
+
+	switch {
+	case opts.Width > 0 && opts.Height > 0:
+		return opts.Width, opts.Height
+	case opts.Width > 0:
+		return opts.Width, round(float64(opts.Width) * intrinsicH / intrinsicW)
+	case opts.Height > 0:
+		return round(float64(opts.Height) * intrinsicW / intrinsicH), opts.Height
+	}
+
+	scale := 1.0
+	if opts.DPI > 0 {
+		scale = opts.DPI / DefaultDPI
+	}
+	return round(intrinsicW * scale), round(intrinsicH * scale)
+}
+
+// intrinsicSize reads the width and height attributes of the root element in CSS pixels,
+// relative sizes (%, em) are unknown and return 0.
+func intrinsicSize(data []byte) (float64, float64) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var width, height float64
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "width":
+				width = parseLength(attr.Value)
+			case "height":
+				height = parseLength(attr.Value)
+			}
+		}
+		return width, height
+	}
+}
+
+// parseLength converts an absolute SVG length to CSS pixels
+func parseLength(value string) float64 {
+	units := map[string]float64{
+		"":   1,
+		"px": 1,
+		"pt": 96.0 / 72,
+		"pc": 16,
+		"in": 96,
+		"cm": 96 / 2.54,
+		"mm": 96 / 25.4,
+	}
+
+	value = strings.TrimSpace(value)
+	number := strings.TrimRight(value, "abcdefghijklmnopqrstuvwxyz%")
+	factor, ok := units[value[len(number):]]
+	if !ok {
+		return 0
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return v * factor
+}
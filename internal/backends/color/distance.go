@@ -0,0 +1,226 @@
+package color
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Names of the distance metrics of ParseMetric
+const (
+	DistanceRGB       = "rgb"       // euclidean distance of the RGB values, the default
+	DistanceRedmean   = "redmean"   // RGB weighted by how sensitive the eye is to each channel, cheap and close to Lab
+	DistanceCIE76     = "cie76"     // euclidean distance in CIE L*a*b*
+	DistanceCIEDE2000 = "ciede2000" // CIEDE2000 delta E, the most accurate and the slowest
+	DistanceOKLab     = "oklab"     // euclidean distance in OKLab
+)
+
+// DistanceNames are the metrics ParseMetric accepts, the first one is the default
+var DistanceNames = []string{DistanceRGB, DistanceRedmean, DistanceCIE76, DistanceCIEDE2000, DistanceOKLab}
+
+// maxRGBDistance is the RGB distance between black and white, every metric is scaled to it
+var maxRGBDistance = 255 * math.Sqrt(3)
+
+// Point is a color converted once by a Metric, comparing points is cheaper than comparing colors
+type Point [3]float64
+
+// Metric measures how different two colors look. Every metric is scaled so that black and white are
+// as far apart as in RGB (255*sqrt(3)), so thresholds made for the RGB distance keep their meaning.
+// The zero value is the rgb metric.
+type Metric struct {
+	name    string
+	convert func(c color.RGBA) Point
+	compare func(p1, p2 Point) float64
+}
+
+var metrics = map[string]Metric{
+	DistanceRGB: {
+		name:    DistanceRGB,
+		convert: rgbPoint,
+		compare: euclidean(1),
+	},
+	DistanceRedmean: {
+		name:    DistanceRedmean,
+		convert: rgbPoint,
+		compare: redmean,
+	},
+	DistanceCIE76: {
+		name: DistanceCIE76,
+		convert: func(c color.RGBA) Point {
+			lab := RGBToLab(c)
+			return Point{lab.L, lab.A, lab.B}
+		},
+		compare: euclidean(maxRGBDistance / 100),
+	},
+	DistanceCIEDE2000: {
+		name: DistanceCIEDE2000,
+		convert: func(c color.RGBA) Point {
+			lab := RGBToLab(c)
+			return Point{lab.L, lab.A, lab.B}
+		},
+		compare: func(p1, p2 Point) float64 {
+			return CIEDE2000(LAB{L: p1[0], A: p1[1], B: p1[2]}, LAB{L: p2[0], A: p2[1], B: p2[2]}) * maxRGBDistance / 100
+		},
+	},
+	DistanceOKLab: {
+		name: DistanceOKLab,
+		convert: func(c color.RGBA) Point {
+			lab := RGBToOKLab(c)
+			return Point{lab.L, lab.A, lab.B}
+		},
+		compare: euclidean(maxRGBDistance),
+	},
+}
+
+// ParseMetric returns the metric with the name, "" is the rgb metric
+func ParseMetric(name string) (Metric, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DistanceRGB
+	}
+	metric, ok := metrics[name]
+	if !ok {
+		return Metric{}, fmt.Errorf("unknown distance %q, available: %s", name, strings.Join(DistanceNames, ", "))
+	}
+	return metric, nil
+}
+
+func (m Metric) orDefault() Metric {
+	if m.convert == nil {
+		return metrics[DistanceRGB]
+	}
+	return m
+}
+
+// String returns the name of the metric
+func (m Metric) String() string {
+	return m.orDefault().name
+}
+
+// Point converts the color for Compare
+func (m Metric) Point(c color.Color) Point {
+	return m.orDefault().convert(toRGBA8(c))
+}
+
+// Compare returns the distance between two points of the metric
+func (m Metric) Compare(p1, p2 Point) float64 {
+	return m.orDefault().compare(p1, p2)
+}
+
+// Distance returns how different the two colors look
+func (m Metric) Distance(c1, c2 color.Color) float64 {
+	return m.Compare(m.Point(c1), m.Point(c2))
+}
+
+// SimilarityWeight is 1 for equal colors and goes down to 0 when the distance reaches threshold*sqrt(3),
+// threshold is the difference of each RGB channel in [0,255] that still counts as similar
+func (m Metric) SimilarityWeight(c1, c2 color.Color, threshold float64) float64 {
+	return m.PointSimilarityWeight(m.Point(c1), m.Point(c2), threshold)
+}
+
+// PointSimilarityWeight is SimilarityWeight of two converted colors, to convert a color compared many times only once
+func (m Metric) PointSimilarityWeight(p1, p2 Point, threshold float64) float64 {
+	threshold = min(max(threshold, 0), 255)
+	maxDistance := threshold * math.Sqrt(3)
+	if maxDistance <= 0 {
+		return 0
+	}
+
+	distance := m.Compare(p1, p2)
+	if distance > maxDistance {
+		return 0
+	}
+	return 1 - (distance / maxDistance)
+}
+
+// CIEDE2000 returns the CIEDE2000 delta E between two CIE L*a*b* colors, 100 between black and white
+func CIEDE2000(lab1, lab2 LAB) float64 {
+	const pow25to7 = 6103515625.0 // 25^7
+	deg := math.Pi / 180
+
+	cBar := (math.Hypot(lab1.A, lab1.B) + math.Hypot(lab2.A, lab2.B)) / 2
+	cBar7 := math.Pow(cBar, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25to7)))
+
+	a1, a2 := (1+g)*lab1.A, (1+g)*lab2.A
+	c1, c2 := math.Hypot(a1, lab1.B), math.Hypot(a2, lab2.B)
+	h1, h2 := hueAngle(lab1.B, a1), hueAngle(lab2.B, a2)
+
+	deltaL := lab2.L - lab1.L
+	deltaC := c2 - c1
+	deltaH := 0.0
+	if c1*c2 != 0 {
+		dh := h2 - h1
+		switch {
+		case dh > 180:
+			dh -= 360
+		case dh < -180:
+			dh += 360
+		}
+		deltaH = 2 * math.Sqrt(c1*c2) * math.Sin(dh/2*deg)
+	}
+
+	lBar := (lab1.L + lab2.L) / 2
+	cBarPrime := (c1 + c2) / 2
+	hBar := h1 + h2
+	if c1*c2 != 0 {
+		switch {
+		case math.Abs(h1-h2) <= 180:
+			hBar /= 2
+		case h1+h2 < 360:
+			hBar = (hBar + 360) / 2
+		default:
+			hBar = (hBar - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos((hBar-30)*deg) + 0.24*math.Cos(2*hBar*deg) + 0.32*math.Cos((3*hBar+6)*deg) - 0.20*math.Cos((4*hBar-63)*deg)
+	deltaTheta := 30 * math.Exp(-math.Pow((hBar-275)/25, 2))
+	cBarPrime7 := math.Pow(cBarPrime, 7)
+	rc := 2 * math.Sqrt(cBarPrime7/(cBarPrime7+pow25to7))
+	sl := 1 + 0.015*math.Pow(lBar-50, 2)/math.Sqrt(20+math.Pow(lBar-50, 2))
+	sc := 1 + 0.045*cBarPrime
+	sh := 1 + 0.015*cBarPrime*t
+	rt := -math.Sin(2*deltaTheta*deg) * rc
+
+	l, c, h := deltaL/sl, deltaC/sc, deltaH/sh
+	return math.Sqrt(l*l + c*c + h*h + rt*c*h)
+}
+
+// hueAngle returns the hue of a*, b* in degrees in [0,360)
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func rgbPoint(c color.RGBA) Point {
+	return Point{float64(c.R), float64(c.G), float64(c.B)}
+}
+
+func euclidean(scale float64) func(p1, p2 Point) float64 {
+	return func(p1, p2 Point) float64 {
+		d0, d1, d2 := p1[0]-p2[0], p1[1]-p2[1], p1[2]-p2[2]
+		return math.Sqrt(d0*d0+d1*d1+d2*d2) * scale
+	}
+}
+
+// redmean is the "redmean" approximation of https://www.compuphase.com/cmetric.htm
+func redmean(p1, p2 Point) float64 {
+	// 2*2.5 + 4 = 9 is the weight of black to white, scaled to 3 like the RGB distance
+	const scale = 1 / 1.7320508075688772 // sqrt(3) / sqrt(9)
+	rMean := (p1[0] + p2[0]) / 2
+	dr, dg, db := p1[0]-p2[0], p1[1]-p2[1], p1[2]-p2[2]
+	return math.Sqrt((2+rMean/256)*dr*dr+4*dg*dg+(2+(255-rMean)/256)*db*db) * scale
+}
+
+func toRGBA8(c color.Color) color.RGBA {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+}
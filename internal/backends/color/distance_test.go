@@ -0,0 +1,78 @@
+package color
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// Test data of Sharma, Wu and Dalal, "The CIEDE2000 color-difference formula: implementation notes,
+// supplementary test data, and mathematical observations" (2005)
+var sharmaPairs = []struct {
+	lab1, lab2 LAB
+	deltaE     float64
+}{
+	{LAB{50, 2.6772, -79.7751}, LAB{50, 0, -82.7485}, 2.0425},
+	{LAB{50, 3.1571, -77.2803}, LAB{50, 0, -82.7485}, 2.8615},
+	{LAB{50, 2.8361, -74.0200}, LAB{50, 0, -82.7485}, 3.4412},
+	{LAB{50, -1.3802, -84.2814}, LAB{50, 0, -82.7485}, 1.0000},
+	{LAB{50, -1.1848, -84.8006}, LAB{50, 0, -82.7485}, 1.0000},
+	{LAB{50, -0.9009, -85.5211}, LAB{50, 0, -82.7485}, 1.0000},
+	{LAB{50, 0, 0}, LAB{50, -1, 2}, 2.3669},
+	{LAB{50, -1, 2}, LAB{50, 0, 0}, 2.3669},
+	{LAB{50, 2.4900, -0.0010}, LAB{50, -2.4900, 0.0009}, 7.1792},
+	{LAB{50, 2.4900, -0.0010}, LAB{50, -2.4900, 0.0010}, 7.1792},
+	{LAB{50, 2.4900, -0.0010}, LAB{50, -2.4900, 0.0011}, 7.2195},
+	{LAB{50, 2.4900, -0.0010}, LAB{50, -2.4900, 0.0012}, 7.2195},
+	{LAB{50, -0.0010, 2.4900}, LAB{50, 0.0009, -2.4900}, 4.8045},
+	{LAB{50, -0.0010, 2.4900}, LAB{50, 0.0010, -2.4900}, 4.8045},
+	{LAB{50, -0.0010, 2.4900}, LAB{50, 0.0011, -2.4900}, 4.7461},
+	{LAB{50, 2.5000, 0}, LAB{50, 0, -2.5000}, 4.3065},
+	{LAB{50, 2.5000, 0}, LAB{73, 25, -18}, 27.1492},
+	{LAB{50, 2.5000, 0}, LAB{61, -5, 29}, 22.8977},
+	{LAB{50, 2.5000, 0}, LAB{56, -27, -3}, 31.9030},
+	{LAB{50, 2.5000, 0}, LAB{58, 24, 15}, 19.4535},
+	{LAB{50, 2.5000, 0}, LAB{50, 3.1736, 0.5854}, 1.0000},
+	{LAB{50, 2.5000, 0}, LAB{50, 3.2972, 0}, 1.0000},
+	{LAB{50, 2.5000, 0}, LAB{50, 1.8634, 0.5757}, 1.0000},
+	{LAB{50, 2.5000, 0}, LAB{50, 3.2592, 0.3350}, 1.0000},
+	{LAB{60.2574, -34.0099, 36.2677}, LAB{60.4626, -34.1751, 39.4387}, 1.2644},
+	{LAB{63.0109, -31.0961, -5.8663}, LAB{62.8187, -29.7946, -4.0864}, 1.2630},
+	{LAB{61.2901, 3.7196, -5.3901}, LAB{61.4292, 2.2480, -4.9620}, 1.8731},
+	{LAB{35.0831, -44.1164, 3.7933}, LAB{35.0232, -40.0716, 1.5901}, 1.8645},
+	{LAB{22.7233, 20.0904, -46.6940}, LAB{23.0331, 14.9730, -42.5619}, 2.0373},
+	{LAB{36.4612, 47.8580, 18.3852}, LAB{36.2715, 50.5065, 21.2231}, 1.4146},
+	{LAB{90.8027, -2.0831, 1.4410}, LAB{91.1528, -1.6435, 0.0447}, 1.4441},
+	{LAB{90.9257, -0.5406, -0.9208}, LAB{88.6381, -0.8985, -0.7239}, 1.5381},
+	{LAB{6.7747, -0.2908, -2.4247}, LAB{5.8714, -0.0985, -2.2286}, 0.6377},
+	{LAB{2.0776, 0.0795, -1.1350}, LAB{0.9033, -0.0636, -0.5514}, 0.9082},
+}
+
+func TestCIEDE2000(t *testing.T) {
+	for i, pair := range sharmaPairs {
+		// the reference values are rounded to 4 decimals
+		if got := CIEDE2000(pair.lab1, pair.lab2); math.Abs(got-pair.deltaE) > 5e-5 {
+			t.Errorf("pair %d: CIEDE2000(%v, %v) = %.4f, want %.4f", i+1, pair.lab1, pair.lab2, got, pair.deltaE)
+		}
+		if got, reverse := CIEDE2000(pair.lab1, pair.lab2), CIEDE2000(pair.lab2, pair.lab1); math.Abs(got-reverse) > 1e-9 {
+			t.Errorf("pair %d: CIEDE2000 is not symmetric, %.6f and %.6f", i+1, got, reverse)
+		}
+	}
+}
+
+func TestMetricScale(t *testing.T) {
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for _, name := range DistanceNames {
+		metric, err := ParseMetric(name)
+		if err != nil {
+			t.Fatalf("ParseMetric(%q): %v", name, err)
+		}
+		if got := metric.Distance(black, white); math.Abs(got-maxRGBDistance) > 0.5 {
+			t.Errorf("%s: distance from black to white = %.2f, want %.2f", name, got, maxRGBDistance)
+		}
+		if got := metric.SimilarityWeight(white, white, 8.5); got != 1 {
+			t.Errorf("%s: similarity of equal colors = %v, want 1", name, got)
+		}
+	}
+}
@@ -15,8 +15,8 @@ import (
 
 // ColorBox represents a colored terminal box with its color value
 type ColorBox struct {
-	ColorStr string // The original color string (can be any format)
-	Box      string // The colored box ANSI code
+	ColorStr string `json:"color"` // The original color string (can be any format)
+	Box      string `json:"-"`     // The colored box ANSI code
 }
 
 // CreateColorBox creates a colored box with ANSI codes from any color format
@@ -39,8 +39,8 @@ func CreateColorBox(colorStr string) (ColorBox, error) {
 
 // Transformation represents input colors -> output colors
 type Transformation struct {
-	Inputs  []ColorBox
-	Outputs []ColorBox
+	Inputs  []ColorBox `json:"inputs"`
+	Outputs []ColorBox `json:"outputs"`
 }
 
 // NewTransformation creates a transformation from input hex colors to output hex colors
@@ -0,0 +1,64 @@
+package color
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// OKLAB represents a color in the OKLab color space, which is more perceptually uniform than CIE L*a*b*
+type OKLAB struct {
+	L float64 // Lightness: 0-1
+	A float64 // Green-Red: about -0.4 to 0.4
+	B float64 // Blue-Yellow: about -0.4 to 0.4
+}
+
+// RGBToLab converts an sRGB color to CIE L*a*b* with a D65 white point
+func RGBToLab(c color.RGBA) LAB {
+	l, a, b := toColorful(c).Lab()
+	return LAB{L: l * 100, A: a * 100, B: b * 100}
+}
+
+// LabToRGB converts a CIE L*a*b* color to sRGB, colors outside of the sRGB gamut are clamped
+func LabToRGB(lab LAB) color.RGBA {
+	return fromColorful(colorful.Lab(lab.L/100, lab.A/100, lab.B/100))
+}
+
+// RGBToOKLab converts an sRGB color to OKLab
+func RGBToOKLab(c color.RGBA) OKLAB {
+	r, g, b := toColorful(c).LinearRgb()
+
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	return OKLAB{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// OKLabToRGB converts an OKLab color to sRGB, colors outside of the sRGB gamut are clamped
+func OKLabToRGB(lab OKLAB) color.RGBA {
+	l := lab.L + 0.3963377774*lab.A + 0.2158037573*lab.B
+	m := lab.L - 0.1055613458*lab.A - 0.0638541728*lab.B
+	s := lab.L - 0.0894841775*lab.A - 1.2914855480*lab.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	return fromColorful(colorful.LinearRgb(
+		+4.0767416621*l-3.3077115913*m+0.2309699292*s,
+		-1.2684380046*l+2.6097574011*m-0.3413193965*s,
+		-0.0041960863*l-0.7034186147*m+1.7076147010*s,
+	))
+}
+
+func toColorful(c color.RGBA) colorful.Color {
+	return colorful.Color{R: float64(c.R) / 255, G: float64(c.G) / 255, B: float64(c.B) / 255}
+}
+
+func fromColorful(c colorful.Color) color.RGBA {
+	r, g, b := c.Clamped().RGB255()
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
@@ -1,12 +1,17 @@
 package haldclut
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
+	"io"
+	"math"
 	"os"
 	"sync"
+
+	imageio "github.com/Achno/gowall/internal/image_io"
 )
 
 // Interface for all the possible interpolation and mapping algorithms
@@ -14,6 +19,13 @@ type Mapperfunc interface {
 	Map(color.RGBA, []color.RGBA) color.RGBA
 }
 
+// PreparedMapper is a Mapperfunc that converts the palette once instead of for every color,
+// InterpolateCLUT maps the entries of the CLUT with the function Prepare returns.
+type PreparedMapper interface {
+	Mapperfunc
+	Prepare(palette []color.RGBA) func(color.RGBA) color.RGBA
+}
+
 func GenerateIdentityCLUT(level int) (*image.RGBA, error) {
 	cubeSize := level * level
 	imageSize := cubeSize * level
@@ -41,12 +53,9 @@ func GenerateIdentityCLUT(level int) (*image.RGBA, error) {
 }
 
 func SaveHaldCLUT(clut *image.RGBA, filePath string) error {
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	return png.Encode(file, clut)
+	return imageio.WriteFile(filePath, func(w io.Writer) error {
+		return png.Encode(w, clut)
+	})
 }
 
 func LoadHaldCLUT(filePath string) (*image.RGBA, error) {
@@ -67,32 +76,133 @@ func LoadHaldCLUT(filePath string) (*image.RGBA, error) {
 	return clut, nil
 }
 
-func ApplyCLUT(img *image.RGBA, clut *image.RGBA, level int) *image.RGBA {
-	// cubeSize := level * level
-	newImg := image.NewRGBA(img.Bounds())
+// DefaultLevel is the HaldCLUT level used when none is configured, 64 steps per channel
+const DefaultLevel = 8
 
-	for y := 0; y < img.Bounds().Dy(); y++ {
-		for x := 0; x < img.Bounds().Dx(); x++ {
-			original := img.RGBAAt(x, y)
+// MaxLevel is the largest supported level, a 4096x4096 HaldCLUT with 256 steps per channel
+const MaxLevel = 16
 
-			clutX, clutY := correctPixel(original, level)
+// ValidateLevel checks that a HaldCLUT of the level can be generated
+func ValidateLevel(level int) error {
+	if level < 2 || level > MaxLevel {
+		return fmt.Errorf("invalid CLUT level %d, it must be between 2 and %d (e.g. 8, 12 or 16)", level, MaxLevel)
+	}
+	return nil
+}
 
-			mappedColor := clut.RGBAAt(clutX, clutY)
-			if original.A == 0 {
-				mappedColor.A = 0
-			}
-			newImg.SetRGBA(x, y, mappedColor)
+// ApplyCLUT maps every pixel through the CLUT, interpolating between the 4 cube cells around it
+// so that smooth gradients do not get posterised into the steps of the level.
+// The CLUT is looked up with the color before alpha premultiplication and the alpha is kept.
+func ApplyCLUT(img *image.NRGBA, clut *image.RGBA, level int) *image.NRGBA {
+	bounds := img.Bounds()
+	newImg := image.NewNRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			newImg.SetNRGBA(x, y, MapColor(clut, level, img.NRGBAAt(x, y)))
 		}
 	}
 	return newImg
 }
 
-func correctPixel(original color.RGBA, level int) (int, int) {
-	cubeSize := level * level
+// ApplyCLUT64 is ApplyCLUT for 16-bit images, the CLUT is looked up with the full precision of the
+// pixel and the mapped color is returned in a 16-bit image so it is not truncated to 8 bits
+func ApplyCLUT64(img *image.NRGBA64, clut *image.RGBA, level int) *image.NRGBA64 {
+	bounds := img.Bounds()
+	newImg := image.NewNRGBA64(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			original := img.NRGBA64At(x, y)
+
+			r, g, b := lookup(clut, level, float64(original.R)/65535, float64(original.G)/65535, float64(original.B)/65535)
+			newImg.SetNRGBA64(x, y, color.NRGBA64{
+				R: toUint16(r),
+				G: toUint16(g),
+				B: toUint16(b),
+				A: original.A,
+			})
+		}
+	}
+	return newImg
+}
 
-	r := int(original.R) * (cubeSize - 1) / 255
-	g := int(original.G) * (cubeSize - 1) / 255
-	b := int(original.B) * (cubeSize - 1) / 255
+// MapColor returns the color the CLUT maps c to, the alpha of c is kept
+func MapColor(clut *image.RGBA, level int, c color.NRGBA) color.NRGBA {
+	r, g, b := lookup(clut, level, float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+	return color.NRGBA{R: toUint8(r), G: toUint8(g), B: toUint8(b), A: c.A}
+}
+
+// lookup returns the color of the CLUT at r,g,b in [0,1], the result is in [0,255]
+func lookup(clut *image.RGBA, level int, r, g, b float64) (float64, float64, float64) {
+	c := tetrahedral(level*level, func(r, g, b int) [3]float64 {
+		entry := clut.RGBAAt(clutCoordinates(r, g, b, level))
+		return [3]float64{float64(entry.R), float64(entry.G), float64(entry.B)}
+	}, r, g, b)
+	return c[0], c[1], c[2]
+}
+
+// tetrahedral interpolates a lattice of size^3 entries returned by at at r,g,b in [0,1]:
+// the cube cell around the color is split into 6 tetrahedra and the color is blended from the 4 corners
+// of the one containing it
+func tetrahedral(size int, at func(r, g, b int) [3]float64, r, g, b float64) [3]float64 {
+	maxIndex := size - 1
+
+	r0, fr := cell(r, maxIndex)
+	g0, fg := cell(g, maxIndex)
+	b0, fb := cell(b, maxIndex)
+	r1, g1, b1 := min(r0+1, maxIndex), min(g0+1, maxIndex), min(b0+1, maxIndex)
+
+	// the corners of the tetrahedron after the origin and before the opposite corner, and their weights
+	c000, c111 := at(r0, g0, b0), at(r1, g1, b1)
+	var c1, c2 [3]float64
+	var w0, w1, w2, w3 float64
+	switch {
+	case fr >= fg && fg >= fb:
+		c1, c2 = at(r1, g0, b0), at(r1, g1, b0)
+		w0, w1, w2, w3 = 1-fr, fr-fg, fg-fb, fb
+	case fr >= fb && fb >= fg:
+		c1, c2 = at(r1, g0, b0), at(r1, g0, b1)
+		w0, w1, w2, w3 = 1-fr, fr-fb, fb-fg, fg
+	case fb >= fr && fr >= fg:
+		c1, c2 = at(r0, g0, b1), at(r1, g0, b1)
+		w0, w1, w2, w3 = 1-fb, fb-fr, fr-fg, fg
+	case fg >= fr && fr >= fb:
+		c1, c2 = at(r0, g1, b0), at(r1, g1, b0)
+		w0, w1, w2, w3 = 1-fg, fg-fr, fr-fb, fb
+	case fg >= fb && fb >= fr:
+		c1, c2 = at(r0, g1, b0), at(r0, g1, b1)
+		w0, w1, w2, w3 = 1-fg, fg-fb, fb-fr, fr
+	default: // fb >= fg >= fr
+		c1, c2 = at(r0, g0, b1), at(r0, g1, b1)
+		w0, w1, w2, w3 = 1-fb, fb-fg, fg-fr, fr
+	}
+
+	var blended [3]float64
+	for i := range blended {
+		blended[i] = w0*c000[i] + w1*c1[i] + w2*c2[i] + w3*c111[i]
+	}
+	return blended
+}
+
+// cell returns the index of the cube cell below v in [0,1] and how far v is towards the next one
+func cell(v float64, maxIndex int) (int, float64) {
+	pos := v * float64(maxIndex)
+	index := min(int(pos), maxIndex)
+	return index, pos - float64(index)
+}
+
+func toUint8(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 255)))
+}
+
+func toUint16(v float64) uint16 {
+	return uint16(math.Round(min(max(v*257, 0), 65535)))
+}
+
+// clutCoordinates returns the position in the hald image of the cube cell r,g,b
+func clutCoordinates(r, g, b, level int) (int, int) {
+	cubeSize := level * level
 
 	x := (r % cubeSize) + (g%level)*cubeSize
 	y := (b * level) + (g / level)
@@ -104,6 +214,11 @@ func InterpolateCLUT(identityClut *image.RGBA, palette []color.RGBA, level int,
 	bounds := identityClut.Bounds()
 	newClut := image.NewRGBA(bounds)
 
+	mapColor := func(c color.RGBA) color.RGBA { return mapper.Map(c, palette) }
+	if prepared, ok := mapper.(PreparedMapper); ok {
+		mapColor = prepared.Prepare(palette)
+	}
+
 	wg := sync.WaitGroup{}
 
 	chunkSize := 128 // goroutines on 128x128 chunks
@@ -123,7 +238,7 @@ func InterpolateCLUT(identityClut *image.RGBA, palette []color.RGBA, level int,
 				for y := startY; y < endY; y++ {
 					for x := startX; x < endX; x++ {
 						originalColor := identityClut.RGBAAt(x, y)
-						interpolatedColor := mapper.Map(originalColor, palette)
+						interpolatedColor := mapColor(originalColor)
 						newClut.SetRGBA(x, y, interpolatedColor)
 					}
 				}
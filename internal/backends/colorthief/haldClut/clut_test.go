@@ -0,0 +1,52 @@
+package haldclut
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyCLUTKeepsAlpha(t *testing.T) {
+	const level = 4
+	identity, err := GenerateIdentityCLUT(level)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	pixels := []color.NRGBA{
+		{R: 255, G: 255, B: 255, A: 128},
+		{R: 255, G: 0, B: 0, A: 255},
+		{R: 0, G: 0, B: 255, A: 0},
+	}
+	for x, c := range pixels {
+		img.SetNRGBA(x, 0, c)
+	}
+
+	mapped := ApplyCLUT(img, identity, level)
+	for x, want := range pixels {
+		got := mapped.NRGBAAt(x, 0)
+		if want.A == 0 {
+			if got.A != 0 {
+				t.Errorf("pixel %d: alpha %d, want 0", x, got.A)
+			}
+			continue
+		}
+		if got != want {
+			t.Errorf("pixel %d: got %v, want %v through the identity CLUT", x, got, want)
+		}
+	}
+
+	// the 8-bit and the 16-bit path agree
+	img64 := image.NewNRGBA64(img.Bounds())
+	for x, c := range pixels {
+		img64.Set(x, 0, c)
+	}
+	mapped64 := ApplyCLUT64(img64, identity, level)
+	for x := range pixels {
+		got, got64 := mapped.NRGBAAt(x, 0), color.NRGBAModel.Convert(mapped64.NRGBA64At(x, 0)).(color.NRGBA)
+		if got.A != 0 && got != got64 {
+			t.Errorf("pixel %d: ApplyCLUT %v, ApplyCLUT64 %v", x, got, got64)
+		}
+	}
+}
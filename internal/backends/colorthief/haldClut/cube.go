@@ -0,0 +1,227 @@
+package haldclut
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	imageio "github.com/Achno/gowall/internal/image_io"
+)
+
+// MaxCubeSize is the largest LUT_3D_SIZE ReadCube accepts, the size of a HaldCLUT of MaxLevel
+const MaxCubeSize = MaxLevel * MaxLevel
+
+// Cube is a 3D LUT in the Adobe/Resolve .cube format, the red index changes fastest in Table
+type Cube struct {
+	Title     string
+	Size      int
+	DomainMin [3]float64
+	DomainMax [3]float64
+	Table     [][3]float64 // Size^3 output colors in [0,1]
+}
+
+// ReadCube parses a .cube 3D LUT, 1D LUTs are not supported
+func ReadCube(r io.Reader) (*Cube, error) {
+	cube := &Cube{DomainMax: [3]float64{1, 1, 1}}
+	scanner := bufio.NewScanner(r)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		switch fields[0] {
+		case "TITLE":
+			cube.Title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "TITLE")), `"`)
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("line %d: 1D LUTs are not supported, only 3D LUTs", lineNum)
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: invalid LUT_3D_SIZE", lineNum)
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 2 || size > MaxCubeSize {
+				return nil, fmt.Errorf("line %d: LUT_3D_SIZE must be between 2 and %d", lineNum, MaxCubeSize)
+			}
+			cube.Size = size
+			cube.Table = make([][3]float64, 0, size*size*size)
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			values, err := parseTriplet(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", lineNum, fields[0], err)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				cube.DomainMin = values
+			} else {
+				cube.DomainMax = values
+			}
+		case "LUT_3D_INPUT_RANGE":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: invalid LUT_3D_INPUT_RANGE", lineNum)
+			}
+			lo, errLo := strconv.ParseFloat(fields[1], 64)
+			hi, errHi := strconv.ParseFloat(fields[2], 64)
+			if errLo != nil || errHi != nil {
+				return nil, fmt.Errorf("line %d: invalid LUT_3D_INPUT_RANGE", lineNum)
+			}
+			cube.DomainMin, cube.DomainMax = [3]float64{lo, lo, lo}, [3]float64{hi, hi, hi}
+		default:
+			values, err := parseTriplet(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if cube.Size == 0 {
+				return nil, fmt.Errorf("line %d: LUT_3D_SIZE must come before the table", lineNum)
+			}
+			cube.Table = append(cube.Table, values)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if cube.Size == 0 {
+		return nil, fmt.Errorf("LUT_3D_SIZE is missing")
+	}
+	if expected := cube.Size * cube.Size * cube.Size; len(cube.Table) != expected {
+		return nil, fmt.Errorf("expected %d entries for LUT_3D_SIZE %d, got %d", expected, cube.Size, len(cube.Table))
+	}
+	for i := range 3 {
+		if cube.DomainMax[i] <= cube.DomainMin[i] {
+			return nil, fmt.Errorf("DOMAIN_MAX must be larger than DOMAIN_MIN")
+		}
+	}
+	return cube, nil
+}
+
+func parseTriplet(fields []string) ([3]float64, error) {
+	var values [3]float64
+	if len(fields) != 3 {
+		return values, fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return values, fmt.Errorf("invalid value %q", field)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// Level returns the smallest HaldCLUT level with at least as many steps per channel as the cube
+func (c *Cube) Level() int {
+	level := 2
+	for level*level < c.Size {
+		level++
+	}
+	return level
+}
+
+// ToHald resamples the cube into a HaldCLUT of the level, so it can be used with ApplyCLUT
+func (c *Cube) ToHald(level int) *image.RGBA {
+	at := func(r, g, b int) [3]float64 {
+		return c.Table[r+g*c.Size+b*c.Size*c.Size]
+	}
+	cubeSize := level * level
+	imageSize := cubeSize * level
+	clut := image.NewRGBA(image.Rect(0, 0, imageSize, imageSize))
+
+	for b := range cubeSize {
+		for g := range cubeSize {
+			for r := range cubeSize {
+				in := [3]float64{float64(r), float64(g), float64(b)}
+				for i := range in {
+					// position of the hald entry in the domain of the cube
+					in[i] = in[i] / float64(cubeSize-1)
+					in[i] = (in[i] - c.DomainMin[i]) / (c.DomainMax[i] - c.DomainMin[i])
+					in[i] = min(max(in[i], 0), 1)
+				}
+				out := tetrahedral(c.Size, at, in[0], in[1], in[2])
+
+				x, y := clutCoordinates(r, g, b, level)
+				offset := clut.PixOffset(x, y)
+				clut.Pix[offset] = toUint8(out[0] * 255)
+				clut.Pix[offset+1] = toUint8(out[1] * 255)
+				clut.Pix[offset+2] = toUint8(out[2] * 255)
+				clut.Pix[offset+3] = 255
+			}
+		}
+	}
+	return clut
+}
+
+// WriteCube writes the HaldCLUT of the level as a .cube 3D LUT of size level^2, for darktable, Resolve, ffmpeg or mpv
+func WriteCube(w io.Writer, clut *image.RGBA, level int, title string) error {
+	cubeSize := level * level
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# Generated by gowall\n")
+	fmt.Fprintf(bw, "TITLE %q\n", title)
+	fmt.Fprintf(bw, "LUT_3D_SIZE %d\n", cubeSize)
+	fmt.Fprintf(bw, "DOMAIN_MIN 0.0 0.0 0.0\n")
+	fmt.Fprintf(bw, "DOMAIN_MAX 1.0 1.0 1.0\n")
+
+	for b := range cubeSize {
+		for g := range cubeSize {
+			for r := range cubeSize {
+				entry := clut.RGBAAt(clutCoordinates(r, g, b, level))
+				fmt.Fprintf(bw, "%.6f %.6f %.6f\n", float64(entry.R)/255, float64(entry.G)/255, float64(entry.B)/255)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// SaveCube writes the HaldCLUT of the level to filePath with WriteCube
+func SaveCube(clut *image.RGBA, level int, title string, filePath string) error {
+	return imageio.WriteFile(filePath, func(w io.Writer) error {
+		return WriteCube(w, clut, level, title)
+	})
+}
+
+// HaldLevel returns the level of a HaldCLUT image, which is level^3 pixels wide and high
+func HaldLevel(clut image.Image) (int, error) {
+	width, height := clut.Bounds().Dx(), clut.Bounds().Dy()
+	for level := 2; level <= MaxLevel; level++ {
+		if level*level*level == width && width == height {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("a %dx%d image is not a HaldCLUT, it has to be level^3 pixels wide and high with a level between 2 and %d", width, height, MaxLevel)
+}
+
+// LoadLUT loads a .cube 3D LUT or a Hald PNG and returns it as a HaldCLUT with its level
+func LoadLUT(path string) (*image.RGBA, int, error) {
+	if strings.EqualFold(filepath.Ext(path), ".cube") {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer file.Close()
+
+		cube, err := ReadCube(file)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", path, err)
+		}
+		level := cube.Level()
+		return cube.ToHald(level), level, nil
+	}
+
+	clut, err := LoadHaldCLUT(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	level, err := HaldLevel(clut)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return clut, level, nil
+}
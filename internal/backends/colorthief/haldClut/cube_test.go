@@ -0,0 +1,115 @@
+package haldclut
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// identityCube returns the text of an identity .cube of the size
+func identityCube(size int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "TITLE \"identity\"\nLUT_3D_SIZE %d\n", size)
+	for b := range size {
+		for g := range size {
+			for r := range size {
+				n := float64(size - 1)
+				fmt.Fprintf(&sb, "%.6f %.6f %.6f\n", float64(r)/n, float64(g)/n, float64(b)/n)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// maxIdentityDiff returns the largest difference between a hald entry and the color it stands for
+func maxIdentityDiff(clut *image.RGBA, level int) int {
+	cubeSize := level * level
+	step := func(i int) int {
+		return int(math.Round(float64(i) * 255 / float64(cubeSize-1)))
+	}
+	diff := 0
+	for b := range cubeSize {
+		for g := range cubeSize {
+			for r := range cubeSize {
+				c := clut.RGBAAt(clutCoordinates(r, g, b, level))
+				for _, d := range []int{int(c.R) - step(r), int(c.G) - step(g), int(c.B) - step(b)} {
+					diff = max(diff, d, -d)
+				}
+			}
+		}
+	}
+	return diff
+}
+
+func TestReadCubeIdentity(t *testing.T) {
+	cube, err := ReadCube(strings.NewReader(identityCube(33)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cube.Title != "identity" || cube.Size != 33 {
+		t.Errorf("got title %q size %d", cube.Title, cube.Size)
+	}
+
+	level := cube.Level()
+	if level != 6 {
+		t.Errorf("level %d, want 6, the smallest with at least 33 steps", level)
+	}
+	if diff := maxIdentityDiff(cube.ToHald(level), level); diff != 0 {
+		t.Errorf("the identity cube resampled to level %d differs by %d", level, diff)
+	}
+}
+
+func TestWriteCubeRoundTrip(t *testing.T) {
+	const level = 8
+	clut := (&Cube{Size: 2, DomainMax: [3]float64{1, 1, 1}, Table: [][3]float64{
+		{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}, {0, 0, 1}, {1, 0, 1}, {0, 1, 1}, {1, 1, 1},
+	}}).ToHald(level)
+	if diff := maxIdentityDiff(clut, level); diff != 0 {
+		t.Fatalf("the identity cube of size 2 differs by %d", diff)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCube(&buf, clut, level, "nord"); err != nil {
+		t.Fatal(err)
+	}
+	cube, err := ReadCube(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cube.Title != "nord" || cube.Size != level*level || cube.Level() != level {
+		t.Errorf("got title %q size %d level %d", cube.Title, cube.Size, cube.Level())
+	}
+	if !bytes.Equal(cube.ToHald(level).Pix, clut.Pix) {
+		t.Error("the HaldCLUT changed after writing and reading it as a .cube")
+	}
+}
+
+func TestSaveCube(t *testing.T) {
+	const level = 4
+	clut, err := GenerateIdentityCLUT(level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "luts", "identity.cube")
+	if err := SaveCube(clut, level, "identity", path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, loadedLevel, err := LoadLUT(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedLevel != level || !bytes.Equal(loaded.Pix, clut.Pix) {
+		t.Errorf("LoadLUT returned level %d and a different HaldCLUT", loadedLevel)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the luts folder, the temporary file should be gone", len(entries))
+	}
+}
@@ -0,0 +1,230 @@
+package haldclut
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+)
+
+// Names of the mappers of ParseMapper
+const (
+	MapperRBF       = "rbf"       // gaussian radial basis functions in RGB, option sigma
+	MapperIDW       = "idw"       // inverse distance weighting in RGB, option power
+	MapperLuminance = "luminance" // rbf that keeps the lightness of the color and only takes the hue and chroma of the palette, option sigma
+	MapperLab       = "lab"       // rbf in CIE L*a*b*, option sigma in delta E
+	MapperOKLab     = "oklab"     // rbf in OKLab, option sigma in OKLab units
+)
+
+// MapperNames are the mappers ParseMapper accepts, the first one is the default
+var MapperNames = []string{MapperRBF, MapperIDW, MapperLuminance, MapperLab, MapperOKLab}
+
+// Default options of the mappers
+const (
+	DefaultIDWPower   = 2.0
+	DefaultLabSigma   = 20.0
+	DefaultOKLabSigma = 0.1
+)
+
+// ParseMapper returns the mapper of a spec like "oklab" or "rbf:sigma=30", "" is the default rbf mapper.
+// The mappers implement fmt.Stringer, String returns the spec with every option so it can be used as a cache key.
+func ParseMapper(spec string) (Mapperfunc, error) {
+	name, rawOptions, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	if name == "" {
+		name = MapperRBF
+	}
+
+	options := map[string]float64{}
+	if rawOptions != "" {
+		for _, option := range strings.Split(rawOptions, ",") {
+			key, rawValue, ok := strings.Cut(option, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid mapper option %q, use key=value e.g. %s:sigma=30", option, MapperRBF)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(rawValue), 64)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("mapper option %s must be a positive number, got %q", key, rawValue)
+			}
+			options[strings.TrimSpace(key)] = value
+		}
+	}
+	option := func(allowed string) (float64, error) {
+		for key := range options {
+			if key != allowed {
+				return 0, fmt.Errorf("unknown option %q for the %s mapper, available: %s", key, name, allowed)
+			}
+		}
+		return options[allowed], nil
+	}
+
+	var mapper Mapperfunc
+	switch name {
+	case MapperRBF, MapperLuminance:
+		sigma, err := option("sigma")
+		if err != nil {
+			return nil, err
+		}
+		mapper = NewRBFMapper(RBFMapperOptions{Sigma: sigma})
+		if name == MapperLuminance {
+			mapper = &LuminanceMapper{Mapper: mapper}
+		}
+	case MapperIDW:
+		power, err := option("power")
+		if err != nil {
+			return nil, err
+		}
+		mapper = NewIDWMapper(power)
+	case MapperLab, MapperOKLab:
+		sigma, err := option("sigma")
+		if err != nil {
+			return nil, err
+		}
+		mapper = NewPerceptualMapper(name, sigma)
+	default:
+		return nil, fmt.Errorf("unknown mapper %q, available: %s", name, strings.Join(MapperNames, ", "))
+	}
+	return mapper, nil
+}
+
+// IDWMapper blends the palette with weights of 1/distance^Power, colors of the palette are kept as is
+type IDWMapper struct {
+	Power float64
+}
+
+// NewIDWMapper returns an IDWMapper, a power of 0 uses DefaultIDWPower
+func NewIDWMapper(power float64) *IDWMapper {
+	if power <= 0 {
+		power = DefaultIDWPower
+	}
+	return &IDWMapper{Power: power}
+}
+
+func (m *IDWMapper) Map(original color.RGBA, palette []color.RGBA) color.RGBA {
+	var numeratorR, numeratorG, numeratorB, denominator float64
+
+	for _, pColor := range palette {
+		distance := math.Sqrt(math.Pow(float64(original.R)-float64(pColor.R), 2) +
+			math.Pow(float64(original.G)-float64(pColor.G), 2) +
+			math.Pow(float64(original.B)-float64(pColor.B), 2))
+		if distance == 0 {
+			return color.RGBA{R: pColor.R, G: pColor.G, B: pColor.B, A: 255}
+		}
+
+		weight := 1 / math.Pow(distance, m.Power)
+		numeratorR += float64(pColor.R) * weight
+		numeratorG += float64(pColor.G) * weight
+		numeratorB += float64(pColor.B) * weight
+		denominator += weight
+	}
+
+	if denominator > 0 {
+		return color.RGBA{
+			R: uint8(math.Round(numeratorR / denominator)),
+			G: uint8(math.Round(numeratorG / denominator)),
+			B: uint8(math.Round(numeratorB / denominator)),
+			A: 255,
+		}
+	}
+	return color.RGBA{R: 0, G: 0, B: 0, A: 255}
+}
+
+func (m *IDWMapper) String() string {
+	return fmt.Sprintf("%s:power=%g", MapperIDW, m.Power)
+}
+
+// LuminanceMapper maps the color with Mapper and keeps the OKLab lightness of the original,
+// so only the hue and chroma come from the palette and shading is preserved
+type LuminanceMapper struct {
+	Mapper Mapperfunc
+}
+
+func (m *LuminanceMapper) Map(original color.RGBA, palette []color.RGBA) color.RGBA {
+	mapped := cpkg.RGBToOKLab(m.Mapper.Map(original, palette))
+	mapped.L = cpkg.RGBToOKLab(original).L
+	return cpkg.OKLabToRGB(mapped)
+}
+
+func (m *LuminanceMapper) String() string {
+	return MapperLuminance + strings.TrimPrefix(fmt.Sprint(m.Mapper), MapperRBF)
+}
+
+// PerceptualMapper is the rbf mapper in CIE L*a*b* (MapperLab) or OKLab (MapperOKLab),
+// distances and blending follow how different colors look rather than their RGB values
+type PerceptualMapper struct {
+	Space string // MapperLab or MapperOKLab
+	Sigma float64
+}
+
+// NewPerceptualMapper returns a PerceptualMapper, a sigma of 0 uses the default one of the space
+func NewPerceptualMapper(space string, sigma float64) *PerceptualMapper {
+	if sigma <= 0 {
+		sigma = DefaultLabSigma
+		if space == MapperOKLab {
+			sigma = DefaultOKLabSigma
+		}
+	}
+	return &PerceptualMapper{Space: space, Sigma: sigma}
+}
+
+func (m *PerceptualMapper) Map(original color.RGBA, palette []color.RGBA) color.RGBA {
+	return m.Prepare(palette)(original)
+}
+
+// Prepare converts the palette to the space once, the returned function maps colors like Map
+func (m *PerceptualMapper) Prepare(palette []color.RGBA) func(color.RGBA) color.RGBA {
+	points := make([][3]float64, len(palette))
+	for i, pColor := range palette {
+		points[i] = m.toSpace(pColor)
+	}
+
+	return func(original color.RGBA) color.RGBA {
+		target := m.toSpace(original)
+
+		var numerator [3]float64
+		var denominator float64
+		nearest, nearestDistanceSq := -1, math.Inf(1)
+		for i, p := range points {
+			distanceSq := (target[0]-p[0])*(target[0]-p[0]) + (target[1]-p[1])*(target[1]-p[1]) + (target[2]-p[2])*(target[2]-p[2])
+			if distanceSq < nearestDistanceSq {
+				nearest, nearestDistanceSq = i, distanceSq
+			}
+
+			weight := math.Exp(-distanceSq / (2 * m.Sigma * m.Sigma))
+			for c := range numerator {
+				numerator[c] += p[c] * weight
+			}
+			denominator += weight
+		}
+
+		if denominator == 0 {
+			// every weight underflowed, the color is too far from the palette for the sigma
+			if nearest < 0 {
+				return color.RGBA{R: 0, G: 0, B: 0, A: 255}
+			}
+			return color.RGBA{R: palette[nearest].R, G: palette[nearest].G, B: palette[nearest].B, A: 255}
+		}
+		for c := range numerator {
+			numerator[c] /= denominator
+		}
+		if m.Space == MapperOKLab {
+			return cpkg.OKLabToRGB(cpkg.OKLAB{L: numerator[0], A: numerator[1], B: numerator[2]})
+		}
+		return cpkg.LabToRGB(cpkg.LAB{L: numerator[0], A: numerator[1], B: numerator[2]})
+	}
+}
+
+func (m *PerceptualMapper) toSpace(c color.RGBA) [3]float64 {
+	if m.Space == MapperOKLab {
+		lab := cpkg.RGBToOKLab(c)
+		return [3]float64{lab.L, lab.A, lab.B}
+	}
+	lab := cpkg.RGBToLab(c)
+	return [3]float64{lab.L, lab.A, lab.B}
+}
+
+func (m *PerceptualMapper) String() string {
+	return fmt.Sprintf("%s:sigma=%g", m.Space, m.Sigma)
+}
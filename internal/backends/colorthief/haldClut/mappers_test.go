@@ -0,0 +1,54 @@
+package haldclut
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestMappersFallBackToNearestColor(t *testing.T) {
+	palette := []color.RGBA{
+		{R: 255, G: 0, B: 0, A: 255},
+		{R: 0, G: 0, B: 255, A: 255},
+	}
+
+	tests := []struct {
+		spec     string
+		original color.RGBA
+		want     color.RGBA
+	}{
+		{"lab:sigma=1", color.RGBA{R: 0, G: 0, B: 128, A: 255}, palette[1]},
+		{"lab:sigma=1", color.RGBA{R: 255, G: 255, B: 0, A: 255}, palette[0]},
+		{"oklab:sigma=0.001", color.RGBA{R: 255, G: 255, B: 255, A: 255}, palette[0]},
+		{"rbf:sigma=1", color.RGBA{R: 0, G: 255, B: 0, A: 255}, palette[0]},
+		{"rbf:sigma=1", color.RGBA{R: 0, G: 40, B: 220, A: 255}, palette[1]},
+	}
+
+	for _, tt := range tests {
+		mapper, err := ParseMapper(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mapper.Map(tt.original, palette); got != tt.want {
+			t.Errorf("%s: Map(%v) = %v, want %v", tt.spec, tt.original, got, tt.want)
+		}
+	}
+}
+
+func TestPerceptualMapperPrepare(t *testing.T) {
+	palette := []color.RGBA{
+		{R: 30, G: 30, B: 46, A: 255},
+		{R: 243, G: 139, B: 168, A: 255},
+		{R: 166, G: 227, B: 161, A: 255},
+	}
+
+	for _, space := range []string{MapperLab, MapperOKLab} {
+		mapper := NewPerceptualMapper(space, 0)
+		mapColor := mapper.Prepare(palette)
+		for v := 0; v < 256; v += 17 {
+			original := color.RGBA{R: uint8(v), G: uint8(255 - v), B: uint8(v / 2), A: 255}
+			if got, want := mapColor(original), mapper.Map(original, palette); got != want {
+				t.Errorf("%s: Prepare(palette)(%v) = %v, Map = %v", space, original, got, want)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package haldclut
 
 import (
+	"fmt"
 	"image/color"
 	"math"
 )
@@ -13,26 +14,44 @@ type RBFMapperOptions struct {
 	Sigma float64 // std makes the gaussian wider
 }
 
-func NewRBFMapper() RBFMapperOptions {
+func DefaultRBFMapperOptions() RBFMapperOptions {
 	return RBFMapperOptions{
 		Sigma: 50.0,
 	}
 }
 
+// NewRBFMapper returns an RBFMapper with the options, a Sigma of 0 uses the default one
+func NewRBFMapper(options RBFMapperOptions) *RBFMapper {
+	if options.Sigma <= 0 {
+		options.Sigma = DefaultRBFMapperOptions().Sigma
+	}
+	return &RBFMapper{options: options}
+}
+
 func (m *RBFMapper) Map(original color.RGBA, palette []color.RGBA) color.RGBA {
+	sigma := m.options.Sigma
+	if sigma <= 0 {
+		sigma = DefaultRBFMapperOptions().Sigma
+	}
+	return rbfInterpolation(original, palette, sigma)
+}
 
-	m.options = NewRBFMapper()
-	return rbfInterpolation(original, palette, m.options.Sigma)
+func (m *RBFMapper) String() string {
+	return fmt.Sprintf("%s:sigma=%g", MapperRBF, NewRBFMapper(m.options).options.Sigma)
 }
 
 func rbfInterpolation(target color.RGBA, palette []color.RGBA, sigma float64) color.RGBA {
 	var numeratorR, numeratorG, numeratorB, denominator float64
+	nearest, nearestDistance := -1, math.Inf(1)
 
-	for _, pColor := range palette {
+	for i, pColor := range palette {
 		// Euclidean distance between target and palette color
 		distance := math.Sqrt(math.Pow(float64(target.R)-float64(pColor.R), 2) +
 			math.Pow(float64(target.G)-float64(pColor.G), 2) +
 			math.Pow(float64(target.B)-float64(pColor.B), 2))
+		if distance < nearestDistance {
+			nearest, nearestDistance = i, distance
+		}
 
 		// Gaussian RBF weight
 		weight := math.Exp(-distance * distance / (2 * sigma * sigma))
@@ -53,6 +72,9 @@ func rbfInterpolation(target color.RGBA, palette []color.RGBA, sigma float64) co
 		}
 	}
 
-	// Fallback if no weight is applied
+	// every weight underflowed with a small sigma, the nearest color of the palette is the closest match
+	if nearest >= 0 {
+		return color.RGBA{R: palette[nearest].R, G: palette[nearest].G, B: palette[nearest].B, A: 255}
+	}
 	return color.RGBA{R: 0, G: 0, B: 0, A: 255}
 }
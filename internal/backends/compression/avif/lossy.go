@@ -1,7 +1,6 @@
 package avif
 
 import (
-	"fmt"
 	"image"
 	"io"
 
@@ -23,7 +22,6 @@ func NewLossyAvifStrategy(quality int, speed int) (*LossyAvifStrategy, error) {
 }
 
 func (l *LossyAvifStrategy) Compress(img image.Image) (image.Image, types.ImageMetadata, error) {
-	fmt.Println(l.Quality)
 	avifOptions := avifpkg.Options{
 		Quality:           l.Quality,
 		QualityAlpha:      l.Quality,
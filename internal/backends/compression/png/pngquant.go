@@ -2,6 +2,7 @@ package png
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
@@ -17,9 +18,10 @@ type PngquantStrategy struct {
 	Speed      int
 }
 
-func NewPngquantStrategy(quality int, speed int) (*PngquantStrategy, error) {
+// NewPngquantStrategy finds pngquant in $PATH or binaryDir, it offers to download it to binaryDir when it is missing
+func NewPngquantStrategy(quality int, speed int, binaryDir string) (*PngquantStrategy, error) {
 
-	_, err := CheckPngquantInstalled()
+	_, err := CheckPngquantInstalled(binaryDir)
 	if err != nil {
 
 		text := `◈ It seems that pngquant is not setup yet, would you like for gowall to set it up.`
@@ -29,13 +31,13 @@ func NewPngquantStrategy(quality int, speed int) (*PngquantStrategy, error) {
 			return nil, fmt.Errorf("pngquant has not been setup, you could always use another backend for png compression via --method, or install pngquant via your package manager")
 		}
 
-		err := SetupPngquant()
+		err := SetupPngquant(binaryDir)
 		if err != nil {
 			return nil, fmt.Errorf("while setting up pngquant: %w", err)
 		}
 	}
 
-	binaryPath, err := CheckPngquantInstalled()
+	binaryPath, err := CheckPngquantInstalled(binaryDir)
 	if err != nil {
 	}
 
@@ -80,7 +82,13 @@ func (p *PngquantStrategy) Compress(img image.Image) (image.Image, types.ImageMe
 
 	err = cmd.Run()
 	if err != nil {
-		return nil, types.ImageMetadata{}, fmt.Errorf("pngquant failed: %w, stderr: %s", err, errorBuffer.String())
+		err = fmt.Errorf("pngquant failed: %w, stderr: %s", err, errorBuffer.String())
+		// 98 and 99 mean the image can not be compressed within the quality range, anything else is a crash
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && (exitErr.ExitCode() == 98 || exitErr.ExitCode() == 99) {
+			return nil, types.ImageMetadata{}, err
+		}
+		return nil, types.ImageMetadata{}, utils.Transient(err)
 	}
 
 	compressedImg, err := png.Decode(&outputBuffer)
@@ -2,7 +2,6 @@ package png
 
 import (
 	"fmt"
-	"path/filepath"
 	"runtime"
 
 	"github.com/Achno/gowall/config"
@@ -10,8 +9,8 @@ import (
 	"github.com/Achno/gowall/utils"
 )
 
-func SetupPngquant() error {
-	destFolder := filepath.Join(config.GowallConfig.OutputFolder, "compression", "pngquant")
+// SetupPngquant downloads the pngquant binary to destFolder
+func SetupPngquant(destFolder string) error {
 
 	urls := map[string]string{
 		"linux":   "https://pngquant.org/pngquant-linux.tar.bz2",
@@ -34,15 +33,13 @@ func SetupPngquant() error {
 	return nil
 }
 
-// CheckPngquantInstalled checks if pngquant is available (either installed or downloaded)
-func CheckPngquantInstalled() (string, error) {
+// CheckPngquantInstalled checks if pngquant is available (either installed or downloaded to destFolder)
+func CheckPngquantInstalled(destFolder string) (string, error) {
 	binaryNames := map[string]string{
 		"linux":   config.PngquantBinaryName,
 		"windows": config.PngquantBinaryName + ".exe",
 		"darwin":  config.PngquantBinaryName,
 	}
 
-	destFolder := filepath.Join(config.GowallConfig.OutputFolder, "compression", "pngquant")
-
 	return utils.FindBinary(binaryNames, destFolder)
 }
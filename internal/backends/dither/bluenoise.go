@@ -0,0 +1,118 @@
+package dither
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// blueNoiseSize is the width and height of the blue noise texture, it is tiled over the image
+const blueNoiseSize = 64
+
+var (
+	blueNoiseOnce   sync.Once
+	blueNoiseMatrix [][]float64
+)
+
+// blueNoise returns the blue noise texture as thresholds in [-0.5,0.5), it is generated once with void and cluster
+func blueNoise() [][]float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMatrix = toThresholds(voidAndCluster(blueNoiseSize, 1.5))
+	})
+	return blueNoiseMatrix
+}
+
+// voidAndCluster ranks the pixels of a size x size torus so that every prefix of the ranking is spread evenly,
+// see Ulichney, "The void-and-cluster method for dither array generation" (1993)
+func voidAndCluster(size int, sigma float64) [][]int {
+	n := size * size
+
+	// gaussian energy of a pixel at each toroidal offset
+	kernel := make([]float64, n)
+	for dy := range size {
+		for dx := range size {
+			wx := float64(min(dx, size-dx))
+			wy := float64(min(dy, size-dy))
+			kernel[dy*size+dx] = math.Exp(-(wx*wx + wy*wy) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	toggle := func(i int, on bool) {
+		pattern[i] = on
+		sign := 1.0
+		if !on {
+			sign = -1
+		}
+		ix, iy := i%size, i/size
+		for j := range energy {
+			dx := (j%size - ix + size) % size
+			dy := (j/size - iy + size) % size
+			energy[j] += sign * kernel[dy*size+dx]
+		}
+	}
+	// tightest returns the set pixel with the most energy, largestVoid the unset pixel with the least
+	tightest := func() int {
+		best := -1
+		for i, on := range pattern {
+			if on && (best < 0 || energy[i] > energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	largestVoid := func() int {
+		best := -1
+		for i, on := range pattern {
+			if !on && (best < 0 || energy[i] < energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	// initial pattern, a fixed seed keeps the texture and therefore the output reproducible
+	rng := rand.New(rand.NewSource(1))
+	ones := n / 10
+	for _, i := range rng.Perm(n)[:ones] {
+		toggle(i, true)
+	}
+
+	// move pixels from clusters to voids until the pattern is even
+	for {
+		cluster := tightest()
+		toggle(cluster, false)
+		void := largestVoid()
+		toggle(void, true)
+		if void == cluster {
+			break
+		}
+	}
+	prototype := make([]bool, n)
+	copy(prototype, pattern)
+	prototypeEnergy := make([]float64, n)
+	copy(prototypeEnergy, energy)
+
+	ranks := make([]int, n)
+	// the pixels of the prototype are ranked by removing the tightest cluster
+	for rank := ones - 1; rank >= 0; rank-- {
+		i := tightest()
+		toggle(i, false)
+		ranks[i] = rank
+	}
+	// the remaining pixels fill the largest void
+	copy(pattern, prototype)
+	copy(energy, prototypeEnergy)
+	for rank := ones; rank < n; rank++ {
+		i := largestVoid()
+		toggle(i, true)
+		ranks[i] = rank
+	}
+
+	matrix := make([][]int, size)
+	for y := range matrix {
+		matrix[y] = ranks[y*size : (y+1)*size]
+	}
+	return matrix
+}
@@ -0,0 +1,52 @@
+package dither
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestVoidAndClusterDeterministic(t *testing.T) {
+	first := voidAndCluster(16, 1.5)
+	second := voidAndCluster(16, 1.5)
+
+	ranks := make([]int, 0, 16*16)
+	for y := range first {
+		if !slices.Equal(first[y], second[y]) {
+			t.Fatalf("row %d differs between runs: %v and %v", y, first[y], second[y])
+		}
+		ranks = append(ranks, first[y]...)
+	}
+	slices.Sort(ranks)
+	for i, rank := range ranks {
+		if rank != i {
+			t.Fatalf("ranks are not a permutation of 0..%d, sorted[%d] = %d", len(ranks)-1, i, rank)
+		}
+	}
+}
+
+func TestBlueNoiseDeterministic(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 80, 70))
+	for y := range 70 {
+		for x := range 80 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 3), G: uint8(y * 3), B: 128, A: 255})
+		}
+	}
+	palette := color.Palette{
+		color.RGBA{A: 255},
+		color.RGBA{R: 255, A: 255},
+		color.RGBA{G: 255, A: 255},
+		color.RGBA{B: 255, A: 255},
+		color.RGBA{R: 255, G: 255, B: 255, A: 255},
+	}
+
+	first := Paletted(img, palette, nil, Options{Mode: BlueNoise})
+	second := Paletted(img, palette, nil, Options{Mode: BlueNoise})
+	if !slices.Equal(first.Pix, second.Pix) {
+		t.Error("blue noise dithering differs between runs")
+	}
+	if matrix := blueNoise(); len(matrix) != blueNoiseSize {
+		t.Errorf("blue noise texture is %d wide, want %d", len(matrix), blueNoiseSize)
+	}
+}
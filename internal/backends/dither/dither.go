@@ -0,0 +1,239 @@
+package dither
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Dithering modes of Options
+const (
+	None           = "none"            // every pixel gets its nearest color, flat areas stay flat
+	FloydSteinberg = "floyd-steinberg" // error diffusion to 4 neighbours, smooth gradients
+	Atkinson       = "atkinson"        // diffuses 3/4 of the error, higher contrast like early Macs
+	Sierra         = "sierra"          // error diffusion to 10 neighbours, less directional artifacts
+	Bayer2         = "bayer2"          // ordered 2x2 threshold matrix, coarse crosshatch
+	Bayer4         = "bayer4"          // ordered 4x4 threshold matrix
+	Bayer8         = "bayer8"          // ordered 8x8 threshold matrix, the classic pixel-art pattern
+	BlueNoise      = "blue-noise"      // ordered with a blue noise texture, no visible pattern
+)
+
+// Modes are the dithering modes Options accepts, the first one is the default
+var Modes = []string{None, FloydSteinberg, Atkinson, Sierra, Bayer2, Bayer4, Bayer8, BlueNoise}
+
+// DefaultStrength is the Strength of the zero Options, the full error or threshold spread
+const DefaultStrength = 1.0
+
+// Options configures the dithering of Paletted, the zero value does not dither
+type Options struct {
+	Mode     string  // one of Modes, "" = None
+	Strength float64 // (0,1] how much of the error is diffused or how far the thresholds spread, 0 = DefaultStrength
+}
+
+// Validate checks the mode and the strength
+func (o Options) Validate() error {
+	if o.Mode != "" && !slices.Contains(Modes, strings.ToLower(o.Mode)) {
+		return fmt.Errorf("unknown dithering mode %q, available: %s", o.Mode, strings.Join(Modes, ", "))
+	}
+	if o.Strength < 0 || o.Strength > 1 {
+		return fmt.Errorf("dithering strength must be in range (0, 1], got: %.2f", o.Strength)
+	}
+	return nil
+}
+
+// Enabled reports whether the options dither at all
+func (o Options) Enabled() bool {
+	mode := strings.ToLower(o.Mode)
+	return mode != "" && mode != None
+}
+
+func (o Options) strength() float64 {
+	if o.Strength <= 0 {
+		return DefaultStrength
+	}
+	return o.Strength
+}
+
+// weight is the share of the error a neighbour at dx,dy of the pixel receives
+type weight struct {
+	dx, dy int
+	share  float64
+}
+
+var kernels = map[string][]weight{
+	FloydSteinberg: {
+		{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	Atkinson: {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8}, {-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8}, {0, 2, 1.0 / 8},
+	},
+	Sierra: {
+		{1, 0, 5.0 / 32}, {2, 0, 3.0 / 32},
+		{-2, 1, 2.0 / 32}, {-1, 1, 4.0 / 32}, {0, 1, 5.0 / 32}, {1, 1, 4.0 / 32}, {2, 1, 2.0 / 32},
+		{-1, 2, 2.0 / 32}, {0, 2, 3.0 / 32}, {1, 2, 2.0 / 32},
+	},
+}
+
+// thresholdMaps return a square matrix of thresholds in [-0.5,0.5) that is tiled over the image
+var thresholdMaps = map[string]func() [][]float64{
+	Bayer2:    func() [][]float64 { return bayer(2) },
+	Bayer4:    func() [][]float64 { return bayer(4) },
+	Bayer8:    func() [][]float64 { return bayer(8) },
+	BlueNoise: blueNoise,
+}
+
+// Paletted draws img with the colors of the palette and the dithering of opts.
+// nearest returns the index of the palette color closest to c, which is not alpha-premultiplied, nil uses palette.Index.
+// Pixels that end up on a transparent palette color do not diffuse their error.
+func Paletted(img image.Image, palette color.Palette, nearest func(c color.RGBA) int, opts Options) *image.Paletted {
+	if nearest == nil {
+		nearest = func(c color.RGBA) int { return palette.Index(color.NRGBA(c)) }
+	}
+	bounds := img.Bounds()
+	dst := image.NewPaletted(bounds, palette)
+	mode := strings.ToLower(opts.Mode)
+
+	switch {
+	case kernels[mode] != nil:
+		diffuse(dst, img, nearest, kernels[mode], opts.strength())
+	case thresholdMaps[mode] != nil:
+		ordered(dst, img, nearest, thresholdMaps[mode](), opts.strength())
+	default:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				dst.SetColorIndex(x, y, uint8(nearest(toRGBA8(img.At(x, y)))))
+			}
+		}
+	}
+	return dst
+}
+
+// diffuse spreads the quantisation error of every pixel over its unvisited neighbours,
+// only the rows the kernel reaches are kept in memory
+func diffuse(dst *image.Paletted, img image.Image, nearest func(c color.RGBA) int, kernel []weight, strength float64) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	rows := 0
+	for _, w := range kernel {
+		rows = max(rows, w.dy+1)
+	}
+	errs := make([][][3]float64, rows)
+	for i := range errs {
+		errs[i] = make([][3]float64, width)
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		current := errs[0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := x - bounds.Min.X
+			src := toRGBA8(img.At(x, y))
+			// clamping keeps the error of colors the palette cannot reach from piling up
+			want := color.RGBA{
+				R: clamp8(float64(src.R) + current[i][0]),
+				G: clamp8(float64(src.G) + current[i][1]),
+				B: clamp8(float64(src.B) + current[i][2]),
+				A: src.A,
+			}
+			index := nearest(want)
+			dst.SetColorIndex(x, y, uint8(index))
+
+			got := toRGBA8(dst.Palette[index])
+			if src.A == 0 || got.A == 0 {
+				continue
+			}
+			diff := [3]float64{float64(want.R) - float64(got.R), float64(want.G) - float64(got.G), float64(want.B) - float64(got.B)}
+			for _, w := range kernel {
+				nx := i + w.dx
+				if nx < 0 || nx >= width {
+					continue
+				}
+				for c := range diff {
+					errs[w.dy][nx][c] += diff[c] * w.share * strength
+				}
+			}
+		}
+
+		// the next row becomes the current one and the cleared current row is reused as the last one
+		clear(current)
+		errs = append(errs[1:], current)
+	}
+}
+
+// ordered offsets every pixel by the threshold of its position in the tiled matrix before picking the nearest color.
+// The offsets spread over the average distance between the colors of the palette.
+func ordered(dst *image.Paletted, img image.Image, nearest func(c color.RGBA) int, matrix [][]float64, strength float64) {
+	bounds := img.Bounds()
+	size := len(matrix)
+	spread := 255 / max(math.Cbrt(float64(len(dst.Palette))), 2) * strength
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := matrix[mod(y, size)]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			src := toRGBA8(img.At(x, y))
+			offset := row[mod(x, size)] * spread
+			index := nearest(color.RGBA{
+				R: clamp8(float64(src.R) + offset),
+				G: clamp8(float64(src.G) + offset),
+				B: clamp8(float64(src.B) + offset),
+				A: src.A,
+			})
+			dst.SetColorIndex(x, y, uint8(index))
+		}
+	}
+}
+
+// bayer returns the size x size Bayer matrix (size a power of 2) as thresholds in [-0.5,0.5)
+func bayer(size int) [][]float64 {
+	index := [][]int{{0}}
+	for n := 1; n < size; n *= 2 {
+		next := make([][]int, 2*n)
+		for y := range next {
+			next[y] = make([]int, 2*n)
+			for x := range next[y] {
+				// each quadrant is the previous matrix times 4 plus 0, 2, 3 or 1
+				quadrant := [2][2]int{{0, 2}, {3, 1}}[y/n][x/n]
+				next[y][x] = 4*index[y%n][x%n] + quadrant
+			}
+		}
+		index = next
+	}
+	return toThresholds(index)
+}
+
+// toThresholds maps the ranks 0..n-1 of a matrix with n entries evenly into [-0.5,0.5)
+func toThresholds(ranks [][]int) [][]float64 {
+	count := float64(len(ranks) * len(ranks))
+	matrix := make([][]float64, len(ranks))
+	for y, row := range ranks {
+		matrix[y] = make([]float64, len(row))
+		for x, rank := range row {
+			matrix[y][x] = (float64(rank)+0.5)/count - 0.5
+		}
+	}
+	return matrix
+}
+
+func toRGBA8(c color.Color) color.RGBA {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return color.RGBA{}
+	}
+	// un-premultiply so the error is measured on the visible color
+	return color.RGBA{
+		R: uint8(r * 0xffff / a >> 8),
+		G: uint8(g * 0xffff / a >> 8),
+		B: uint8(b * 0xffff / a >> 8),
+		A: uint8(a >> 8),
+	}
+}
+
+func clamp8(v float64) uint8 {
+	return uint8(min(max(math.Round(v), 0), 255))
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
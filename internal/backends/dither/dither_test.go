@@ -0,0 +1,156 @@
+package dither
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+var blackWhite = color.Palette{color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}}
+
+func TestBayerMatrix(t *testing.T) {
+	tests := []struct {
+		size  int
+		ranks [][]int
+	}{
+		{2, [][]int{
+			{0, 2},
+			{3, 1},
+		}},
+		{4, [][]int{
+			{0, 8, 2, 10},
+			{12, 4, 14, 6},
+			{3, 11, 1, 9},
+			{15, 7, 13, 5},
+		}},
+	}
+
+	for _, tt := range tests {
+		matrix := bayer(tt.size)
+		want := toThresholds(tt.ranks)
+		for y := range want {
+			for x := range want[y] {
+				if matrix[y][x] != want[y][x] {
+					t.Errorf("bayer(%d)[%d][%d] = %g, want %g", tt.size, y, x, matrix[y][x], want[y][x])
+				}
+			}
+		}
+	}
+}
+
+func TestThresholdRange(t *testing.T) {
+	for mode, thresholds := range thresholdMaps {
+		matrix := thresholds()
+		size := len(matrix)
+		step := 1 / float64(size*size)
+
+		seen := make(map[float64]bool)
+		sum := 0.0
+		for y, row := range matrix {
+			if len(row) != size {
+				t.Fatalf("%s: row %d has %d entries, want %d", mode, y, len(row), size)
+			}
+			for x, v := range row {
+				if v < -0.5 || v >= 0.5 {
+					t.Errorf("%s: threshold [%d][%d] = %g, want in [-0.5,0.5)", mode, y, x, v)
+				}
+				// every rank appears once, so the thresholds are the evenly spaced steps
+				rank := (v+0.5)/step - 0.5
+				if math.Abs(rank-math.Round(rank)) > 1e-9 || seen[math.Round(rank)] {
+					t.Errorf("%s: threshold [%d][%d] = %g is not a distinct rank", mode, y, x, v)
+				}
+				seen[math.Round(rank)] = true
+				sum += v
+			}
+		}
+		if math.Abs(sum) > 1e-9 {
+			t.Errorf("%s: thresholds sum to %g, want 0", mode, sum)
+		}
+	}
+}
+
+func TestDiffuseEdges(t *testing.T) {
+	bounds := []image.Rectangle{
+		image.Rect(0, 0, 1, 9),
+		image.Rect(0, 0, 2, 9),
+		image.Rect(-3, -2, 13, 14),
+		image.Rect(5, 7, 37, 11),
+	}
+
+	for mode := range kernels {
+		for _, b := range bounds {
+			img := image.NewRGBA(b)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					img.Set(x, y, color.RGBA{R: 128, G: 128, B: 128, A: 255})
+				}
+			}
+
+			dst := Paletted(img, blackWhite, nil, Options{Mode: mode})
+			if dst.Bounds() != b {
+				t.Errorf("%s %v: bounds = %v", mode, b, dst.Bounds())
+			}
+			if b.Dx() < 16 {
+				continue
+			}
+			// the error that falls outside the edge columns is dropped, a mid gray still comes out about half white
+			white := 0
+			for _, index := range dst.Pix {
+				white += int(index)
+			}
+			ratio := float64(white) / float64(len(dst.Pix))
+			if ratio < 0.35 || ratio > 0.65 {
+				t.Errorf("%s %v: %.2f of the pixels are white, want about half", mode, b, ratio)
+			}
+		}
+	}
+}
+
+func TestDiffuseTransparent(t *testing.T) {
+	palette := append(slices.Clone(blackWhite), color.Transparent)
+	transparent := len(palette) - 1
+	nearest := func(c color.RGBA) int {
+		if c.A < 128 {
+			return transparent
+		}
+		if int(c.R)+int(c.G)+int(c.B) >= 3*128 {
+			return 1
+		}
+		return 0
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 100})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+
+	for mode := range kernels {
+		dst := Paletted(img, palette, nearest, Options{Mode: mode})
+		if got := int(dst.ColorIndexAt(0, 0)); got != transparent {
+			t.Errorf("%s: translucent pixel got index %d, want the transparent one", mode, got)
+		}
+		// the white of the transparent pixel must not brighten its neighbour
+		if got := dst.ColorIndexAt(1, 0); got != 0 {
+			t.Errorf("%s: pixel next to a transparent one got index %d, want black", mode, got)
+		}
+	}
+}
+
+func TestPalettedUnpremultiplies(t *testing.T) {
+	// a half transparent light gray, premultiplied its components are half as bright
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{R: 100, G: 100, B: 100, A: 128})
+
+	for _, mode := range Modes {
+		var got color.RGBA
+		nearest := func(c color.RGBA) int {
+			got = c
+			return 0
+		}
+		Paletted(img, blackWhite, nearest, Options{Mode: mode, Strength: 0.01})
+		if got.A != 128 || got.R < 195 || got.R > 203 {
+			t.Errorf("%s: nearest got %v, want the un-premultiplied color about {199 199 199 128}", mode, got)
+		}
+	}
+}
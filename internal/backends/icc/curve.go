@@ -0,0 +1,126 @@
+package icc
+
+import "math"
+
+// Curve is a tone reproduction curve, it maps an encoded channel value in [0,1] to a linear one
+type Curve interface {
+	Eval(x float64) float64
+}
+
+// GammaCurve is a pure power function (e.g. Adobe RGB)
+type GammaCurve float64
+
+func (g GammaCurve) Eval(x float64) float64 {
+	return math.Pow(clamp01(x), float64(g))
+}
+
+// TableCurve is a sampled curve, values in between are linearly interpolated
+type TableCurve []float64
+
+func (t TableCurve) Eval(x float64) float64 {
+	if len(t) == 1 {
+		return t[0]
+	}
+	pos := clamp01(x) * float64(len(t)-1)
+	i := int(pos)
+	if i >= len(t)-1 {
+		return t[len(t)-1]
+	}
+	frac := pos - float64(i)
+	return t[i]*(1-frac) + t[i+1]*frac
+}
+
+// ParametricCurve is the ICC parametric curve of type 4, every other type is a special case of it:
+//
+//	x >= D: (A*x + B)^G + E
+//	x <  D: C*x + F
+type ParametricCurve struct {
+	G, A, B, C, D, E, F float64
+}
+
+func (p ParametricCurve) Eval(x float64) float64 {
+	x = clamp01(x)
+	if x >= p.D {
+		base := p.A*x + p.B
+		if base < 0 {
+			base = 0
+		}
+		return math.Pow(base, p.G) + p.E
+	}
+	return p.C*x + p.F
+}
+
+// sRGBCurve is the transfer function of sRGB and Display P3
+var sRGBCurve = ParametricCurve{G: 2.4, A: 1 / 1.055, B: 0.055 / 1.055, C: 1 / 12.92, D: 0.04045}
+
+// parametricFromICC converts the parameters of the 'para' function types 0-4 to a ParametricCurve
+func parametricFromICC(fn uint16, p [7]float64) ParametricCurve {
+	switch fn {
+	case 0:
+		return ParametricCurve{G: p[0], A: 1}
+	case 1:
+		return ParametricCurve{G: p[0], A: p[1], B: p[2], D: -p[2] / p[1]}
+	case 2:
+		return ParametricCurve{G: p[0], A: p[1], B: p[2], D: -p[2] / p[1], E: p[3], F: p[3]}
+	case 3:
+		return ParametricCurve{G: p[0], A: p[1], B: p[2], C: p[3], D: p[4]}
+	default:
+		return ParametricCurve{G: p[0], A: p[1], B: p[2], C: p[3], D: p[4], E: p[5], F: p[6]}
+	}
+}
+
+func clamp01(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
+
+// lut8 samples the curve for every 8 bit value
+func lut8(c Curve) [256]float64 {
+	var lut [256]float64
+	for i := range lut {
+		lut[i] = c.Eval(float64(i) / 255)
+	}
+	return lut
+}
+
+// inverseLUTSize is the number of values sampled by inverseLUT and sampleLUT
+const inverseLUTSize = 4096
+
+// sampleLUT samples the curve like inverseLUT, it is used for inputs with more than 8 bits per channel
+func sampleLUT(c Curve) []float64 {
+	lut := make([]float64, inverseLUTSize+1)
+	for i := range lut {
+		lut[i] = c.Eval(float64(i) / inverseLUTSize)
+	}
+	return lut
+}
+
+// interpolateLUT looks up v in [0,1] in a table of inverseLUTSize+1 samples
+func interpolateLUT(lut []float64, v float64) float64 {
+	v = clamp01(v) * inverseLUTSize
+	i := int(v)
+	if i >= inverseLUTSize {
+		return lut[inverseLUTSize]
+	}
+	frac := v - float64(i)
+	return lut[i]*(1-frac) + lut[i+1]*frac
+}
+
+// inverseLUT samples the inverse of the (monotonic) curve, it maps linear values back to encoded values
+func inverseLUT(c Curve) []float64 {
+	lut := make([]float64, inverseLUTSize+1)
+	for i := range lut {
+		y := float64(i) / inverseLUTSize
+		// binary search the encoded value producing y
+		lo, hi := 0.0, 1.0
+		for range 30 {
+			mid := (lo + hi) / 2
+			if c.Eval(mid) < y {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		lut[i] = (lo + hi) / 2
+	}
+	return lut
+}
@@ -0,0 +1,105 @@
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Encode serializes the profile as an ICC v2 display profile, so outputs can be tagged with their color space
+func (p *Profile) Encode() []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+
+	var tags []tag
+	tags = append(tags, tag{"desc", encodeDescription(p.Name)})
+	tags = append(tags, tag{"cprt", encodeText("No copyright, use freely")})
+	tags = append(tags, tag{"wtpt", encodeXYZ(pcsD50)})
+	for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		tags = append(tags, tag{sig, encodeXYZ([3]float64{p.Matrix[0][i], p.Matrix[1][i], p.Matrix[2][i]})})
+	}
+	for i, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		tags = append(tags, tag{sig, encodeCurve(p.Curves[i])})
+	}
+
+	// the tag data follows the header (128 bytes) and the tag table, 4 byte aligned
+	offset := 128 + 4 + 12*len(tags)
+	var table, data bytes.Buffer
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	for _, t := range tags {
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, uint32(offset+data.Len()))
+		binary.Write(&table, binary.BigEndian, uint32(len(t.data)))
+		data.Write(t.data)
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+
+	size := 128 + table.Len() + data.Len()
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	copy(header[68:], encodeXYZ(pcsD50)[8:])
+	copy(header[80:], "gwll") // creator
+
+	out := make([]byte, 0, size)
+	out = append(out, header...)
+	out = append(out, table.Bytes()...)
+	return append(out, data.Bytes()...)
+}
+
+func encodeS15Fixed16(v float64) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(int32(math.Round(v*65536))))
+	return b
+}
+
+func encodeXYZ(xyz [3]float64) []byte {
+	b := []byte("XYZ \x00\x00\x00\x00")
+	for _, v := range xyz {
+		b = append(b, encodeS15Fixed16(v)...)
+	}
+	return b
+}
+
+func encodeCurve(c Curve) []byte {
+	var b bytes.Buffer
+	b.WriteString("curv\x00\x00\x00\x00")
+
+	if g, ok := c.(GammaCurve); ok {
+		binary.Write(&b, binary.BigEndian, uint32(1))
+		binary.Write(&b, binary.BigEndian, uint16(math.Round(float64(g)*256)))
+		return b.Bytes()
+	}
+
+	// parametric curves are v4 only, v2 profiles sample them
+	const samples = 1024
+	binary.Write(&b, binary.BigEndian, uint32(samples))
+	for i := range samples {
+		v := c.Eval(float64(i) / (samples - 1))
+		binary.Write(&b, binary.BigEndian, uint16(math.Round(clamp01(v)*65535)))
+	}
+	return b.Bytes()
+}
+
+// encodeDescription writes a v2 textDescriptionType with an empty unicode and scriptcode description
+func encodeDescription(name string) []byte {
+	var b bytes.Buffer
+	b.WriteString("desc\x00\x00\x00\x00")
+	binary.Write(&b, binary.BigEndian, uint32(len(name)+1))
+	b.WriteString(name)
+	b.WriteByte(0)
+	b.Write(make([]byte, 4+4+2+1+67))
+	return b.Bytes()
+}
+
+func encodeText(text string) []byte {
+	return append([]byte("text\x00\x00\x00\x00"+text), 0)
+}
@@ -0,0 +1,162 @@
+// Package icc implements the subset of ICC color profiles gowall needs to color manage RGB images:
+// matrix/TRC profiles (sRGB, Display P3, Adobe RGB, ProPhoto...) can be parsed, converted between and generated.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrUnsupportedProfile = errors.New("unsupported ICC profile, only RGB matrix/TRC profiles are supported")
+
+// Profile is an RGB matrix/TRC profile, the matrix converts linear RGB to the D50 XYZ profile connection space
+type Profile struct {
+	Name   string
+	Matrix [3][3]float64
+	Curves [3]Curve
+}
+
+// Parse reads the colorants and tone curves of an embedded ICC profile
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("invalid ICC profile")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, ErrUnsupportedProfile
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := range count {
+		entry := 132 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		sig := string(data[entry : entry+4])
+		offset := int(binary.BigEndian.Uint32(data[entry+4 : entry+8]))
+		size := int(binary.BigEndian.Uint32(data[entry+8 : entry+12]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("invalid ICC profile, tag %s is out of bounds", sig)
+		}
+		tags[sig] = data[offset : offset+size]
+	}
+
+	p := &Profile{Name: profileDescription(tags["desc"])}
+	for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseXYZ(tags[sig])
+		if err != nil {
+			return nil, err
+		}
+		// the colorants are the columns of the matrix
+		for row := range 3 {
+			p.Matrix[row][i] = xyz[row]
+		}
+	}
+	for i, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseCurve(tags[sig])
+		if err != nil {
+			return nil, err
+		}
+		p.Curves[i] = curve
+	}
+	return p, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func parseXYZ(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, ErrUnsupportedProfile
+	}
+	return [3]float64{s15Fixed16(tag[8:12]), s15Fixed16(tag[12:16]), s15Fixed16(tag[16:20])}, nil
+}
+
+func parseCurve(tag []byte) (Curve, error) {
+	if len(tag) < 12 {
+		return nil, ErrUnsupportedProfile
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		switch {
+		case n == 0:
+			return GammaCurve(1), nil
+		case n == 1 && len(tag) >= 14:
+			return GammaCurve(float64(binary.BigEndian.Uint16(tag[12:14])) / 256), nil
+		case len(tag) >= 12+2*n:
+			table := make(TableCurve, n)
+			for i := range n {
+				table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+			}
+			return table, nil
+		}
+	case "para":
+		// number of parameters of each function type
+		numParams := map[uint16]int{0: 1, 1: 3, 2: 4, 3: 5, 4: 7}
+		fn := binary.BigEndian.Uint16(tag[8:10])
+		n, ok := numParams[fn]
+		if !ok || len(tag) < 12+4*n {
+			return nil, ErrUnsupportedProfile
+		}
+		var params [7]float64
+		for i := range n {
+			params[i] = s15Fixed16(tag[12+4*i:])
+		}
+		return parametricFromICC(fn, params), nil
+	}
+	return nil, ErrUnsupportedProfile
+}
+
+// profileDescription reads the ascii name of 'desc' (v2) or 'mluc' (v4) tags
+func profileDescription(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if n > 0 && 12+n <= len(tag) {
+			return string(tag[12 : 12+n-1])
+		}
+	case "mluc":
+		if len(tag) < 28 {
+			return ""
+		}
+		length := int(binary.BigEndian.Uint32(tag[20:24]))
+		offset := int(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		// utf-16be, good enough for the ascii names of profiles
+		var name []byte
+		for i := offset; i+1 < offset+length; i += 2 {
+			name = append(name, tag[i+1])
+		}
+		return string(name)
+	}
+	return ""
+}
+
+// Equal reports whether both profiles describe the same color space, ignoring tiny rounding differences
+func (p *Profile) Equal(other *Profile) bool {
+	for row := range 3 {
+		for col := range 3 {
+			if math.Abs(p.Matrix[row][col]-other.Matrix[row][col]) > 0.002 {
+				return false
+			}
+		}
+	}
+	for c := range 3 {
+		for _, x := range []float64{0.05, 0.2, 0.5, 0.8} {
+			if math.Abs(p.Curves[c].Eval(x)-other.Curves[c].Eval(x)) > 0.002 {
+				return false
+			}
+		}
+	}
+	return true
+}
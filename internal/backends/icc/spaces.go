@@ -0,0 +1,131 @@
+package icc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// xy chromaticity coordinates
+type chromaticity struct{ x, y float64 }
+
+var (
+	whiteD65 = chromaticity{0.3127, 0.3290}
+	whiteD50 = chromaticity{0.3457, 0.3585}
+)
+
+// the D50 illuminant of the profile connection space, as stored in ICC headers
+var pcsD50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// workingSpaces are the color spaces images can be converted to before processing
+var workingSpaces = map[string]func() *Profile{
+	"srgb": func() *Profile {
+		return newProfile("sRGB", whiteD65, [3]chromaticity{{0.64, 0.33}, {0.30, 0.60}, {0.15, 0.06}}, sRGBCurve)
+	},
+	"display-p3": func() *Profile {
+		return newProfile("Display P3", whiteD65, [3]chromaticity{{0.680, 0.320}, {0.265, 0.690}, {0.150, 0.060}}, sRGBCurve)
+	},
+	"adobe-rgb": func() *Profile {
+		return newProfile("Adobe RGB (1998)", whiteD65, [3]chromaticity{{0.64, 0.33}, {0.21, 0.71}, {0.15, 0.06}}, GammaCurve(563.0/256))
+	},
+	"prophoto": func() *Profile {
+		return newProfile("ProPhoto RGB", whiteD50, [3]chromaticity{{0.7347, 0.2653}, {0.1596, 0.8404}, {0.0366, 0.0001}}, GammaCurve(1.8))
+	},
+}
+
+// SRGB returns the sRGB profile, the color space of images without an embedded profile
+func SRGB() *Profile {
+	return workingSpaces["srgb"]()
+}
+
+// WorkingSpace returns the profile of a working space by name (srgb, display-p3, adobe-rgb, prophoto)
+func WorkingSpace(name string) (*Profile, error) {
+	space, ok := workingSpaces[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown color space %q, available: %s", name, strings.Join(WorkingSpaceNames(), ", "))
+	}
+	return space(), nil
+}
+
+func WorkingSpaceNames() []string {
+	names := make([]string, 0, len(workingSpaces))
+	for name := range workingSpaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newProfile builds the D50 adapted RGB to XYZ matrix from the primaries and white point of a color space
+func newProfile(name string, white chromaticity, primaries [3]chromaticity, curve Curve) *Profile {
+	var m [3][3]float64
+	for i, p := range primaries {
+		m[0][i] = p.x / p.y
+		m[1][i] = 1
+		m[2][i] = (1 - p.x - p.y) / p.y
+	}
+
+	// scale the primaries so that RGB(1,1,1) is the white point
+	w := xyToXYZ(white)
+	s := mulVec(invert(m), w)
+	for row := range 3 {
+		for col := range 3 {
+			m[row][col] *= s[col]
+		}
+	}
+
+	return &Profile{
+		Name:   name,
+		Matrix: mul(bradford(w, pcsD50), m),
+		Curves: [3]Curve{curve, curve, curve},
+	}
+}
+
+func xyToXYZ(c chromaticity) [3]float64 {
+	return [3]float64{c.x / c.y, 1, (1 - c.x - c.y) / c.y}
+}
+
+// bradford returns the chromatic adaptation matrix from the src to the dst white point
+func bradford(src, dst [3]float64) [3][3]float64 {
+	cone := [3][3]float64{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+	s := mulVec(cone, src)
+	d := mulVec(cone, dst)
+	scale := [3][3]float64{{d[0] / s[0], 0, 0}, {0, d[1] / s[1], 0}, {0, 0, d[2] / s[2]}}
+	return mul(invert(cone), mul(scale, cone))
+}
+
+func mul(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				m[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return m
+}
+
+func mulVec(m [3][3]float64, v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+func invert(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	return [3][3]float64{
+		{(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det, (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det, (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det},
+		{(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det, (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det, (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det},
+		{(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det, (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det, (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det},
+	}
+}
@@ -0,0 +1,85 @@
+package icc
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Transform converts colors from one profile to another (relative colorimetric, out of gamut colors are clipped)
+type Transform struct {
+	toLinear   [3][256]float64
+	toLinear16 [3][]float64  // sampled like fromLinear, for 16 bit images
+	matrix     [3][3]float64 // src linear RGB -> dst linear RGB
+	fromLinear [3][]float64
+}
+
+func NewTransform(src, dst *Profile) *Transform {
+	t := &Transform{
+		matrix: mul(invert(dst.Matrix), src.Matrix),
+	}
+	for c := range 3 {
+		t.toLinear[c] = lut8(src.Curves[c])
+		t.toLinear16[c] = sampleLUT(src.Curves[c])
+		t.fromLinear[c] = inverseLUT(dst.Curves[c])
+	}
+	return t
+}
+
+// encode maps a linear value back to an 8 bit value of the destination profile
+func (t *Transform) encode(c int, v float64) uint8 {
+	return uint8(math.Round(interpolateLUT(t.fromLinear[c], v) * 255))
+}
+
+// encode16 maps a linear value back to a 16 bit value of the destination profile
+func (t *Transform) encode16(c int, v float64) uint16 {
+	return uint16(math.Round(interpolateLUT(t.fromLinear[c], v) * 65535))
+}
+
+// Apply returns a copy of the image converted to the destination profile, the alpha channel is left untouched.
+// High bit depth images (e.g. 16-bit PNGs) are converted to an *image.NRGBA64, every other image to an *image.NRGBA.
+func (t *Transform) Apply(img image.Image) image.Image {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return t.apply16(img)
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	m := t.matrix
+	for i := 0; i < len(dst.Pix); i += 4 {
+		r := t.toLinear[0][dst.Pix[i]]
+		g := t.toLinear[1][dst.Pix[i+1]]
+		b := t.toLinear[2][dst.Pix[i+2]]
+
+		dst.Pix[i] = t.encode(0, m[0][0]*r+m[0][1]*g+m[0][2]*b)
+		dst.Pix[i+1] = t.encode(1, m[1][0]*r+m[1][1]*g+m[1][2]*b)
+		dst.Pix[i+2] = t.encode(2, m[2][0]*r+m[2][1]*g+m[2][2]*b)
+	}
+	return dst
+}
+
+func (t *Transform) apply16(img image.Image) *image.NRGBA64 {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	m := t.matrix
+	for i := 0; i < len(dst.Pix); i += 8 {
+		r := interpolateLUT(t.toLinear16[0], float64(uint16(dst.Pix[i])<<8|uint16(dst.Pix[i+1]))/65535)
+		g := interpolateLUT(t.toLinear16[1], float64(uint16(dst.Pix[i+2])<<8|uint16(dst.Pix[i+3]))/65535)
+		b := interpolateLUT(t.toLinear16[2], float64(uint16(dst.Pix[i+4])<<8|uint16(dst.Pix[i+5]))/65535)
+
+		for c, v := range [3]uint16{
+			t.encode16(0, m[0][0]*r+m[0][1]*g+m[0][2]*b),
+			t.encode16(1, m[1][0]*r+m[1][1]*g+m[1][2]*b),
+			t.encode16(2, m[2][0]*r+m[2][1]*g+m[2][2]*b),
+		} {
+			dst.Pix[i+2*c] = uint8(v >> 8)
+			dst.Pix[i+2*c+1] = uint8(v)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,130 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// CompositeOp is a Porter-Duff operator, it decides how a source pixel is combined with the destination pixel under it.
+type CompositeOp int
+
+const (
+	CompositeClear   CompositeOp = iota // neither the source nor the destination
+	CompositeSrc                        // the source replaces the destination
+	CompositeDst                        // the destination is kept
+	CompositeOver                       // the source is drawn over the destination
+	CompositeDstOver                    // the destination is drawn over the source
+	CompositeIn                         // the source where the destination is opaque
+	CompositeDstIn                      // the destination where the source is opaque
+	CompositeOut                        // the source where the destination is transparent
+	CompositeDstOut                     // the destination where the source is transparent
+	CompositeAtop                       // the source over the destination, only where the destination is opaque
+	CompositeDstAtop                    // the destination over the source, only where the source is opaque
+	CompositeXor                        // the source and the destination where they don't overlap
+	CompositePlus                       // the sum of the source and the destination, e.g. to join disjoint coverages
+)
+
+// factors returns the Porter-Duff coefficients of the source and the destination for their alphas in [0,1]
+func (op CompositeOp) factors(srcA, dstA float64) (float64, float64) {
+	switch op {
+	case CompositeClear:
+		return 0, 0
+	case CompositeSrc:
+		return 1, 0
+	case CompositeDst:
+		return 0, 1
+	case CompositeOver:
+		return 1, 1 - srcA
+	case CompositeDstOver:
+		return 1 - dstA, 1
+	case CompositeIn:
+		return dstA, 0
+	case CompositeDstIn:
+		return 0, srcA
+	case CompositeOut:
+		return 1 - dstA, 0
+	case CompositeDstOut:
+		return 0, 1 - srcA
+	case CompositeAtop:
+		return dstA, 1 - srcA
+	case CompositeDstAtop:
+		return 1 - dstA, srcA
+	case CompositeXor:
+		return 1 - dstA, 1 - srcA
+	case CompositePlus:
+		return 1, 1
+	}
+	return 1, 1 - srcA
+}
+
+// CompositeColor combines the src color with the dst color using op.
+// Colors are combined premultiplied with 16 bits per channel, so partially transparent pixels
+// (anti-aliased or feathered edges) don't leave dark or bright fringes.
+func CompositeColor(dst, src color.Color, op CompositeOp) color.RGBA64 {
+	sr, sg, sb, sa := src.RGBA()
+	dr, dg, db, da := dst.RGBA()
+	fs, fd := op.factors(float64(sa)/0xffff, float64(da)/0xffff)
+
+	mix := func(s, d uint32) uint16 {
+		return uint16(math.Round(math.Min(0xffff, float64(s)*fs+float64(d)*fd)))
+	}
+	return color.RGBA64{R: mix(sr, dr), G: mix(sg, dg), B: mix(sb, db), A: mix(sa, da)}
+}
+
+// Composite draws the r rectangle of dst with src (starting at sp) using op, like draw.Draw with every Porter-Duff operator.
+// The destination keeps its own color model, e.g. the non premultiplied image.NRGBA or the 16-bit image.NRGBA64.
+func Composite(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, op CompositeOp) {
+	// the standard library already implements these two operators premultiplied, with fast paths
+	switch op {
+	case CompositeSrc:
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	case CompositeOver:
+		draw.Draw(dst, r, src, sp, draw.Over)
+		return
+	}
+
+	r = r.Intersect(dst.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sx, sy := sp.X+x-r.Min.X, sp.Y+y-r.Min.Y
+			var s color.Color = color.Transparent
+			if (image.Point{sx, sy}).In(src.Bounds()) {
+				s = src.At(sx, sy)
+			}
+			dst.Set(x, y, CompositeColor(dst.At(x, y), s, op))
+		}
+	}
+}
+
+// FillRect composites a solid color over the r rectangle of dst using op
+func FillRect(dst draw.Image, r image.Rectangle, c color.Color, op CompositeOp) {
+	Composite(dst, r, &image.Uniform{C: c}, image.Point{}, op)
+}
+
+// ScaleAlpha multiplies the opacity of a color by coverage in [0,1] (e.g. the anti-aliasing coverage of an edge pixel).
+// Every channel is scaled since the color is premultiplied, scaling only the alpha would brighten the pixel.
+func ScaleAlpha(c color.Color, coverage float64) color.RGBA64 {
+	coverage = math.Max(0, math.Min(1, coverage))
+	r, g, b, a := c.RGBA()
+
+	scale := func(v uint32) uint16 {
+		return uint16(math.Round(float64(v) * coverage))
+	}
+	return color.RGBA64{R: scale(r), G: scale(g), B: scale(b), A: scale(a)}
+}
+
+// MixColors linearly interpolates from c1 (weight 0) to c2 (weight 1).
+// The colors are mixed premultiplied, so mixing with a transparent color fades it out instead of darkening it.
+func MixColors(c1, c2 color.Color, weight float64) color.RGBA64 {
+	weight = math.Max(0, math.Min(1, weight))
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+
+	mix := func(v1, v2 uint32) uint16 {
+		return uint16(math.Round((1-weight)*float64(v1) + weight*float64(v2)))
+	}
+	return color.RGBA64{R: mix(r1, r2), G: mix(g1, g2), B: mix(b1, b2), A: mix(a1, a2)}
+}
@@ -23,9 +23,10 @@ type CompressionProcessor struct {
 
 // Options with the functional options pattern so you can pick options and set defaults
 type CompressionOptions struct {
-	Quality  int
-	Speed    int
-	Strategy string // Name of the backend to use
+	Quality   int
+	Speed     int
+	Strategy  string // Name of the backend to use
+	BinaryDir string // Folder external backends (pngquant) are looked up in and downloaded to
 }
 type CompressionOption func(*CompressionOptions)
 
@@ -47,6 +48,12 @@ func WithStrategy(strategy string) CompressionOption {
 	}
 }
 
+func WithBinaryDir(dir string) CompressionOption {
+	return func(co *CompressionOptions) {
+		co.BinaryDir = dir
+	}
+}
+
 // NewCompressionProcessor creates a new compression processor with default strategies
 func NewCompressionProcessor(opts ...CompressionOption) *CompressionProcessor {
 	// Default options
@@ -89,7 +96,7 @@ func (p *CompressionProcessor) GetStrategies() map[string]func(quality int, spee
 	// the -<format> part is used to filter strategies by format and is required.
 	var strategies = map[string]func(quality int, speed int) (CompressionStrategy, error){
 		"pngquant-png": func(quality int, speed int) (CompressionStrategy, error) {
-			return png.NewPngquantStrategy(quality, speed)
+			return png.NewPngquantStrategy(quality, speed, p.options.BinaryDir)
 		},
 		"losslesspng-png": func(quality int, speed int) (CompressionStrategy, error) {
 			return png.NewLosslessPngStrategy()
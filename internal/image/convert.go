@@ -9,93 +9,192 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
-	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
 	cpkg "github.com/Achno/gowall/internal/backends/color"
 	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
+	"github.com/Achno/gowall/internal/backends/dither"
+	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 )
 
 var clutMutex sync.Mutex
 
-type ThemeConverter struct{}
+// BackendNearestNeighbour is the ThemeConverter backend replacing every pixel by the nearest color of the theme
+const BackendNearestNeighbour = "nn"
 
-func (themeConv *ThemeConverter) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
-	level := 8
+// ThemeConverter converts images to the colors of a theme through a HaldCLUT or with the nearest neighbour backend
+type ThemeConverter struct {
+	Backend  string         // BackendNearestNeighbour, anything else is the spec of the palette mapper of the HaldCLUT (haldclut.ParseMapper)
+	CLUTDir  string         // folder the HaldCLUT of each theme is cached in, "" builds it in memory for every image
+	Level    int            // level of the HaldCLUT, 0 = haldclut.DefaultLevel
+	Distance cpkg.Metric    // how the nearest neighbour backend compares colors, the zero value is rgb
+	Dither   dither.Options // dithering of the nearest neighbour backend, the zero value does not dither
+}
 
-	selectedTheme, err := SelectTheme(theme)
-	if err != nil {
-		return nil, types.ImageMetadata{}, fmt.Errorf("%w %s", err, theme)
-	}
+// NewThemeConverter returns a ThemeConverter with the backend that caches its HaldCLUTs of the level in clutDir,
+// distance is only used by the nearest neighbour backend
+func NewThemeConverter(backend string, clutDir string, level int, distance cpkg.Metric) *ThemeConverter {
+	return &ThemeConverter{Backend: backend, CLUTDir: clutDir, Level: level, Distance: distance}
+}
 
-	// NearestNeighbour backend if specified in the config
-	if config.GowallConfig.ColorCorrectionBackend == "nn" {
-		newimg, err := NearestNeighbour(img, selectedTheme)
+func (themeConv *ThemeConverter) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	if themeConv.Backend == BackendNearestNeighbour {
+		selectedTheme, err := SelectTheme(theme)
+		if err != nil {
+			return nil, types.ImageMetadata{}, fmt.Errorf("%w %s", err, theme)
+		}
+		newimg, err := NearestNeighbour(img, selectedTheme, themeConv.Distance, themeConv.Dither)
 		if err != nil {
 			return nil, types.ImageMetadata{}, err
 		}
 		return newimg, types.ImageMetadata{}, nil
 	}
 
+	clut, level, err := themeConv.CLUT(theme)
+	if err != nil {
+		return nil, types.ImageMetadata{}, err
+	}
+	return ApplyThemeCLUT(img, clut, level), types.ImageMetadata{}, nil
+}
+
+// CLUT returns the HaldCLUT of the theme and its level, e.g. to export it with haldclut.WriteCube
+func (themeConv *ThemeConverter) CLUT(theme string) (*image.RGBA, int, error) {
+	if themeConv.Backend == BackendNearestNeighbour {
+		return nil, 0, fmt.Errorf("the %s backend does not use a CLUT, choose a mapper: %s", BackendNearestNeighbour, strings.Join(haldclut.MapperNames, ", "))
+	}
+	mapper, err := haldclut.ParseMapper(themeConv.Backend)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	level := themeConv.Level
+	if level == 0 {
+		level = haldclut.DefaultLevel
+	}
+	if err := haldclut.ValidateLevel(level); err != nil {
+		return nil, 0, err
+	}
+
+	selectedTheme, err := SelectTheme(theme)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w %s", err, theme)
+	}
+	clut, err := themeConv.clut(theme, selectedTheme, level, mapper)
+	if err != nil {
+		return nil, 0, err
+	}
+	return clut, level, nil
+}
+
+// clut returns the HaldCLUT of the theme, cached in CLUTDir when it is set
+func (themeConv *ThemeConverter) clut(theme string, selectedTheme Theme, level int, mapper haldclut.Mapperfunc) (*image.RGBA, error) {
+	if themeConv.CLUTDir == "" {
+		return BuildThemeCLUT(selectedTheme, level, mapper)
+	}
+
 	// hash colors to know if anything in the custom themes have changed
 	clrs, err := GetThemeColors(theme)
 	if err != nil {
-		return nil, types.ImageMetadata{}, err
+		return nil, err
 	}
 	hash := cpkg.HashPalette(clrs)
-	clutPath := fmt.Sprintf("%s_%s.png", theme, hash)
+	mapperKey := strings.NewReplacer(":", "-", "=", "-", ",", "-").Replace(fmt.Sprint(mapper))
+	clutPath := filepath.Join(themeConv.CLUTDir, fmt.Sprintf("%s_%s_level%d_%s.png", theme, mapperKey, level, hash))
 
 	clutMutex.Lock()
 	// if clut exists skip to save time
-	_, err = os.Stat(filepath.Join(config.GowallConfig.OutputFolder, "cluts", clutPath))
+	_, err = os.Stat(clutPath)
 	if os.IsNotExist(err) {
-
-		identityClut, err := haldclut.GenerateIdentityCLUT(level)
+		modifiedClut, err := BuildThemeCLUT(selectedTheme, level, mapper)
 		if err != nil {
 			clutMutex.Unlock()
-			return nil, types.ImageMetadata{}, fmt.Errorf("could not generate Identity CLUT")
+			return nil, err
 		}
-		mapper := &haldclut.RBFMapper{}
-		palette, err := cpkg.ToRGBA(selectedTheme.Colors)
+		err = haldclut.SaveHaldCLUT(modifiedClut, clutPath)
 		if err != nil {
 			clutMutex.Unlock()
-			return nil, types.ImageMetadata{}, fmt.Errorf("could not parse colors to RGBA")
+			return nil, fmt.Errorf("while saving the CLUT: %w", err)
 		}
-		modifiedClut := haldclut.InterpolateCLUT(identityClut, palette, level, mapper)
-		err = haldclut.SaveHaldCLUT(modifiedClut, filepath.Join(config.GowallConfig.OutputFolder, "cluts", clutPath))
-		if err != nil {
-			clutMutex.Unlock()
-			return nil, types.ImageMetadata{}, fmt.Errorf("while saving the CLUT: %w", err)
-		}
-
 	}
 	clutMutex.Unlock()
 
-	clut, err := haldclut.LoadHaldCLUT(filepath.Join(config.GowallConfig.OutputFolder, "cluts", clutPath))
+	clut, err := haldclut.LoadHaldCLUT(clutPath)
 	if err != nil {
-		return nil, types.ImageMetadata{}, fmt.Errorf("while loading CLUT: %w", err)
+		return nil, fmt.Errorf("while loading CLUT: %w", err)
 	}
 	if clut == nil {
-		return nil, types.ImageMetadata{}, fmt.Errorf("CLUT is nil even though is was loaded")
+		return nil, fmt.Errorf("CLUT is nil even though is was loaded")
+	}
+	return clut, nil
+}
+
+// BuildThemeCLUT maps every color of an identity HaldCLUT of the level to the palette of the theme, a nil mapper uses the default rbf one
+func BuildThemeCLUT(theme Theme, level int, mapper haldclut.Mapperfunc) (*image.RGBA, error) {
+	if mapper == nil {
+		mapper = haldclut.NewRBFMapper(haldclut.DefaultRBFMapperOptions())
+	}
+	identityClut, err := haldclut.GenerateIdentityCLUT(level)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate Identity CLUT")
+	}
+	palette, err := cpkg.ToRGBA(theme.Colors)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse colors to RGBA")
 	}
+	return haldclut.InterpolateCLUT(identityClut, palette, level, mapper), nil
+}
+
+// ApplyThemeCLUT looks up every pixel of the image in the CLUT built by BuildThemeCLUT or loaded by haldclut.LoadLUT
+func ApplyThemeCLUT(img image.Image, clut *image.RGBA, level int) image.Image {
+	// 16-bit images look up the CLUT with their full precision and keep it in the output
+	if imageio.IsHighBitDepth(img) {
+		return haldclut.ApplyCLUT64(imageio.ToNRGBA64(img), clut, level)
+	}
+
 	bounds := img.Bounds()
-	rgba := image.NewRGBA(bounds)
-	draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
-	newImg := haldclut.ApplyCLUT(rgba, clut, level)
+	nrgba := image.NewNRGBA(bounds)
+	draw.Draw(nrgba, bounds, img, bounds.Min, draw.Src)
+	return haldclut.ApplyCLUT(nrgba, clut, level)
+}
 
-	return newImg, types.ImageMetadata{}, nil
+// ProcessSVG replaces every color of an SVG document by the nearest color of the theme, the vectors are kept
+func (themeConv *ThemeConverter) ProcessSVG(data []byte, theme string) ([]byte, error) {
+	selectedTheme, err := SelectTheme(theme)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s", err, theme)
+	}
+	palette := newNearestPalette(selectedTheme, themeConv.Distance)
+	return svg.Recolor(data, palette.nearest), nil
 }
 
-func NearestNeighbour(img image.Image, theme Theme) (image.Image, error) {
+// NearestNeighbour replaces every pixel by the color of the theme that is the closest according to the metric,
+// with dithering the quantisation error is spread so gradients become patterns of the theme colors
+func NearestNeighbour(img image.Image, theme Theme, metric cpkg.Metric, dithering dither.Options) (image.Image, error) {
 	bounds := img.Bounds()
-	newImg := image.NewRGBA(bounds)
+	newImg := imageio.NewCanvas(img, bounds)
+	palette := newNearestPalette(theme, metric)
+
+	if dithering.Enabled() {
+		if len(theme.Colors) > 256 {
+			return nil, fmt.Errorf("dithering supports themes of up to 256 colors, %s has %d", theme.Name, len(theme.Colors))
+		}
+		paletted := dither.Paletted(img, theme.Colors, palette.index, dithering)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				newImg.Set(x, y, theme.Colors[paletted.ColorIndexAt(x, y)])
+			}
+		}
+		return newImg, nil
+	}
 
 	// replace each pixel with the selected theme's nearest color
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			originalColor := img.At(x, y)
-			newColor := nearestColor(originalColor, theme)
+			newColor := palette.nearest(originalColor)
 			newImg.Set(x, y, newColor)
 		}
 	}
@@ -107,29 +206,55 @@ func NearestNeighbour(img image.Image, theme Theme) (image.Image, error) {
 	return newImg, nil
 }
 
-func nearestColor(clr color.Color, theme Theme) color.Color {
+// nearestPalette finds the nearest color of a theme, the theme colors are converted once for the metric
+// and the result of every color is cached since images repeat colors a lot. It is not safe for concurrent use.
+type nearestPalette struct {
+	metric cpkg.Metric
+	colors []color.Color
+	points []cpkg.Point
+	cache  map[color.RGBA]int
+}
+
+func newNearestPalette(theme Theme, metric cpkg.Metric) *nearestPalette {
+	p := &nearestPalette{
+		metric: metric,
+		colors: theme.Colors,
+		points: make([]cpkg.Point, len(theme.Colors)),
+		cache:  make(map[color.RGBA]int),
+	}
+	for i, themeColor := range theme.Colors {
+		p.points[i] = metric.Point(themeColor)
+	}
+	return p
+}
+
+func (p *nearestPalette) nearest(clr color.Color) color.Color {
 	r, g, b, _ := clr.RGBA()
 
 	// Convert from 16-bit to 8-bit
-	r, g, b = r>>8, g>>8, b>>8
-
-	minDist := math.MaxFloat64
+	return p.colors[p.index(color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8)})]
+}
 
-	var nearestClr color.Color
+// index returns the index of the theme color nearest to c, the alpha of c is ignored
+func (p *nearestPalette) index(c color.RGBA) int {
+	key := color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+	if nearest, ok := p.cache[key]; ok {
+		return nearest
+	}
 
-	for _, themeColor := range theme.Colors {
-		tr, tg, tb, _ := themeColor.RGBA()
-		// Convert from 16-bit to 8-bit
-		tr, tg, tb = tr>>8, tg>>8, tb>>8
+	point := p.metric.Point(key)
+	minDist := math.MaxFloat64
+	nearest := 0
 
-		distance := cpkg.ColorDistance(tr, tg, tb, r, g, b)
+	for i, themePoint := range p.points {
+		distance := p.metric.Compare(point, themePoint)
 
 		if distance < minDist {
 			minDist = distance
-			nearestClr = themeColor
+			nearest = i
 		}
-
 	}
 
-	return nearestClr
+	p.cache[key] = nearest
+	return nearest
 }
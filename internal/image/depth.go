@@ -0,0 +1,106 @@
+package image
+
+import (
+	"image"
+	"math"
+
+	imageio "github.com/Achno/gowall/internal/image_io"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// adjust64 applies fn to the color channels of every pixel in [0,1] with 16 bits of precision, the alpha channel is left untouched.
+// It is the high bit depth counterpart of imaging.AdjustFunc, which truncates images to 8 bits.
+func adjust64(img image.Image, fn func(r, g, b float64) (float64, float64, float64)) *image.NRGBA64 {
+	src := imageio.ToNRGBA64(img)
+	bounds := src.Bounds()
+	dst := image.NewNRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := range bounds.Dy() {
+		si := src.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		di := dst.PixOffset(0, y)
+		for x := 0; x < bounds.Dx(); x++ {
+			s := src.Pix[si+x*8 : si+x*8+8]
+			d := dst.Pix[di+x*8 : di+x*8+8]
+
+			r, g, b := fn(channel16(s[0:2]), channel16(s[2:4]), channel16(s[4:6]))
+			putChannel16(d[0:2], r)
+			putChannel16(d[2:4], g)
+			putChannel16(d[4:6], b)
+			copy(d[6:8], s[6:8])
+		}
+	}
+	return dst
+}
+
+// adjustLUT64 applies the same function to the 3 color channels, see adjust64
+func adjustLUT64(img image.Image, fn func(v float64) float64) *image.NRGBA64 {
+	return adjust64(img, func(r, g, b float64) (float64, float64, float64) {
+		return fn(r), fn(g), fn(b)
+	})
+}
+
+// channel16 reads a big endian 16 bit channel as a value in [0,1]
+func channel16(b []uint8) float64 {
+	return float64(uint16(b[0])<<8|uint16(b[1])) / 65535
+}
+
+func putChannel16(b []uint8, v float64) {
+	c := uint16(math.Round(math.Max(0, math.Min(1, v)) * 65535))
+	b[0] = uint8(c >> 8)
+	b[1] = uint8(c)
+}
+
+// The adjustments below match the ones of the imaging package, evaluated in floating point instead of an 8 bit lookup table
+
+func contrast64(img image.Image, percentage float64) *image.NRGBA64 {
+	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
+	v := (100.0 + percentage) / 100.0
+
+	return adjustLUT64(img, func(x float64) float64 {
+		switch {
+		case 0 <= v && v <= 1:
+			return 0.5 + (x-0.5)*v
+		case 1 < v && v < 2:
+			return 0.5 + (x-0.5)*(1/(2.0-v))
+		default:
+			return math.Floor(x + 0.5)
+		}
+	})
+}
+
+func sigmoid64(img image.Image, midpoint, factor float64) *image.NRGBA64 {
+	a := math.Min(math.Max(midpoint, 0.0), 1.0)
+	b := math.Abs(factor)
+	sigmoid := func(x float64) float64 { return 1 / (1 + math.Exp(b*(a-x))) }
+	sig0, sig1 := sigmoid(0), sigmoid(1)
+	e := 1.0e-6
+
+	return adjustLUT64(img, func(x float64) float64 {
+		if factor == 0 {
+			return x
+		}
+		if factor > 0 {
+			return (sigmoid(x) - sig0) / (sig1 - sig0)
+		}
+		arg := math.Min(math.Max((sig1-sig0)*x+sig0, e), 1.0-e)
+		return a - math.Log(1.0/arg-1.0)/b
+	})
+}
+
+func gamma64(img image.Image, gamma float64) *image.NRGBA64 {
+	e := 1.0 / math.Max(gamma, 0.0001)
+	return adjustLUT64(img, func(x float64) float64 {
+		return math.Pow(x, e)
+	})
+}
+
+func saturation64(img image.Image, percentage float64) *image.NRGBA64 {
+	percentage = math.Min(math.Max(percentage, -100), 100)
+	multiplier := 1 + percentage/100
+
+	return adjust64(img, func(r, g, b float64) (float64, float64, float64) {
+		h, s, l := colorful.Color{R: r, G: g, B: b}.Hsl()
+		c := colorful.Hsl(h, math.Min(s*multiplier, 1), l)
+		return c.R, c.G, c.B
+	})
+}
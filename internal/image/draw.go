@@ -7,6 +7,7 @@ import (
 	"math"
 
 	clr "github.com/Achno/gowall/internal/backends/color"
+	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 	"github.com/Achno/gowall/utils"
 )
@@ -27,24 +28,16 @@ func drawBorder(img image.Image, borderThickness int, borderColor color.Color, c
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	newImg := image.NewRGBA(bounds)
-	draw.Draw(newImg, bounds, img, image.Point{0, 0}, draw.Src)
+	newImg := imageio.NewCanvas(img, image.Rect(0, 0, width, height))
+	Composite(newImg, newImg.Bounds(), img, bounds.Min, CompositeSrc)
 
 	// Top and bottom borders
-	for x := range width {
-		for t := range borderThickness {
-			newImg.Set(x, t, borderColor)
-			newImg.Set(x, height-borderThickness+t, borderColor)
-		}
-	}
+	FillRect(newImg, image.Rect(0, 0, width, borderThickness), borderColor, CompositeSrc)
+	FillRect(newImg, image.Rect(0, height-borderThickness, width, height), borderColor, CompositeSrc)
 
 	// Left and right borders
-	for y := range height {
-		for t := range borderThickness {
-			newImg.Set(t, y, borderColor)
-			newImg.Set(width-borderThickness+t, y, borderColor)
-		}
-	}
+	FillRect(newImg, image.Rect(0, 0, borderThickness, height), borderColor, CompositeSrc)
+	FillRect(newImg, image.Rect(width-borderThickness, 0, width, height), borderColor, CompositeSrc)
 
 	if cornerRadius > 0 {
 		newImg = roundImageCorners(newImg, cornerRadius, borderThickness, borderColor)
@@ -56,10 +49,10 @@ func drawBorder(img image.Image, borderThickness int, borderColor color.Color, c
 // roundImageCorners rounds the corners of an image with optional border support and anti-aliasing.
 // If borderThickness is 0, no border is applied (simple rounding only).
 // If borderThickness > 0, a border is drawn around the rounded corners.
-func roundImageCorners(img image.Image, radius float64, borderThickness int, borderColor color.Color) *image.RGBA {
+func roundImageCorners(img image.Image, radius float64, borderThickness int, borderColor color.Color) draw.Image {
 	bounds := img.Bounds()
-	dst := image.NewRGBA(bounds)
-	draw.Draw(dst, bounds, img, image.Point{}, draw.Src)
+	dst := imageio.NewCanvas(img, image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	Composite(dst, dst.Bounds(), img, bounds.Min, CompositeSrc)
 
 	w, h := float64(bounds.Dx()), float64(bounds.Dy())
 
@@ -84,49 +77,40 @@ func roundImageCorners(img image.Image, radius float64, borderThickness int, bor
 				inCorner = true
 			}
 
-			if inCorner {
-				dist := math.Sqrt(dx*dx + dy*dy)
+			if !inCorner {
+				continue
+			}
+
+			// fraction of the pixel inside the outer edge and inside the inner edge of the border
+			outer := circleCoverage(dx, dy, radius)
+			inner := outer
+			if borderThickness > 0 {
+				inner = circleCoverage(dx, dy, radius-float64(borderThickness))
+			}
 
-				if dist > radius {
+			switch {
+			case outer == 0:
+				// Fully outside
+				dst.Set(x, y, color.Transparent)
+			case inner == 1 && borderThickness > 0:
+				// Handle border color cleanup for non-corner border pixels
+				currentPixel := dst.At(x, y)
+				bcR, bcG, bcB, bcA := borderColor.RGBA()
+				cpR, cpG, cpB, cpA := currentPixel.RGBA()
+				if cpR == bcR && cpG == bcG && cpB == bcB && cpA == bcA {
 					dst.Set(x, y, color.Transparent)
-				} else if borderThickness > 0 && dist > radius-float64(borderThickness) {
-					dst.Set(x, y, borderColor)
-				} else if dist > radius-4 {
-					samples := 0
-					totalSamples := 8
-					subPixelOffsets := []float64{-0.375, -0.125, 0.125, 0.375}
-
-					for _, offsetX := range subPixelOffsets {
-						for _, offsetY := range subPixelOffsets {
-							subDx := dx + offsetX
-							subDy := dy + offsetY
-							subDist := math.Sqrt(subDx*subDx + subDy*subDy)
-
-							if subDist <= radius && (borderThickness == 0 || subDist < radius-float64(borderThickness)) {
-								samples++
-							}
-						}
-					}
-
-					if samples > 0 && samples < totalSamples {
-						// Partially covered - apply anti-aliasing
-						coverage := float64(samples) / float64(totalSamples)
-						c := dst.RGBAAt(x, y)
-						c.A = uint8(float64(c.A) * coverage)
-						dst.SetRGBA(x, y, c)
-					} else if samples == 0 {
-						// Fully outside
-						dst.Set(x, y, color.Transparent)
-					}
-					// If samples == totalSamples, pixel is fully inside, keep as-is
-				} else if borderThickness > 0 {
-					// Handle border color cleanup for non-corner border pixels
-					currentPixel := dst.RGBAAt(x, y)
-					bcR, bcG, bcB, bcA := borderColor.RGBA()
-					cpR, cpG, cpB, cpA := currentPixel.RGBA()
-					if cpR == bcR && cpG == bcG && cpB == bcB && cpA == bcA {
-						dst.Set(x, y, color.Transparent)
-					}
+				}
+			case inner == 1:
+				// Fully inside, keep as-is
+			default:
+				// Partially covered, anti-aliasing: the image covers the pixel inside the inner edge and the border
+				// the ring between both edges, both are premultiplied so they can simply be added
+				edge := ScaleAlpha(dst.At(x, y), inner)
+				if borderThickness > 0 {
+					ring := ScaleAlpha(borderColor, outer-inner)
+					dst.Set(x, y, CompositeColor(ring, edge, CompositePlus))
+				} else {
+					dst.Set(x, y, edge)
 				}
 			}
 		}
@@ -135,6 +119,23 @@ func roundImageCorners(img image.Image, radius float64, borderThickness int, bor
 	return dst
 }
 
+// circleCoverage returns the fraction of the pixel at dx,dy from the center of a circle that is inside it, with 4x4 supersampling
+func circleCoverage(dx, dy, radius float64) float64 {
+	subPixelOffsets := []float64{-0.375, -0.125, 0.125, 0.375}
+	samples := 0
+
+	for _, offsetX := range subPixelOffsets {
+		for _, offsetY := range subPixelOffsets {
+			subDx := dx + offsetX
+			subDy := dy + offsetY
+			if math.Sqrt(subDx*subDx+subDy*subDy) <= radius {
+				samples++
+			}
+		}
+	}
+	return float64(samples) / float64(len(subPixelOffsets)*len(subPixelOffsets))
+}
+
 type RoundProcessor struct {
 	CornerRadius float64
 }
@@ -208,26 +209,17 @@ func (g *GridProcessor) Process(img image.Image, theme string, format string) (i
 
 func applyGridToImage(img image.Image, options *GridOptions) (image.Image, error) {
 	bounds := img.Bounds()
-	newImg := image.NewRGBA(bounds)
+	newImg := imageio.NewCanvas(img, bounds)
 
-	draw.Draw(newImg, bounds, img, bounds.Min, draw.Src)
+	Composite(newImg, bounds, img, bounds.Min, CompositeSrc)
 
 	// optionally use the input image as a mask, and apply the grid only to the transparent areas.
 	if options.MaskOnly {
 		gridImg := image.NewRGBA(bounds)
 		drawGridOnImage(gridImg, options)
 
-		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-			for x := bounds.Min.X; x < bounds.Max.X; x++ {
-				_, _, _, srcAlpha := img.At(x, y).RGBA()
-
-				// (low alpha)
-				if srcAlpha < 0x8000 {
-					gridColor := gridImg.At(x, y)
-					newImg.Set(x, y, gridColor)
-				}
-			}
-		}
+		// the grid goes behind the image, it shows through the transparent and partially transparent areas
+		Composite(newImg, bounds, gridImg, bounds.Min, CompositeDstOver)
 	} else {
 		drawGridOnImage(newImg, options)
 	}
@@ -236,30 +228,16 @@ func applyGridToImage(img image.Image, options *GridOptions) (image.Image, error
 }
 
 // drawGrid draws a grid on the image with the given thickness,color and grid size
-func drawGridOnImage(img *image.RGBA, c *GridOptions) {
+func drawGridOnImage(img draw.Image, c *GridOptions) {
 	bounds := img.Bounds()
 
 	for x := bounds.Min.X; x < bounds.Max.X; x += c.GridSize {
-		for thickness := 0; thickness < c.GridThickness; thickness++ {
-			if x+thickness >= bounds.Max.X {
-				break
-			}
-
-			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-				img.Set(x+thickness, y, c.GridColor)
-			}
-		}
+		line := image.Rect(x, bounds.Min.Y, x+c.GridThickness, bounds.Max.Y)
+		FillRect(img, line, c.GridColor, CompositeOver)
 	}
 
 	for y := bounds.Min.Y; y < bounds.Max.Y; y += c.GridSize {
-		for thickness := 0; thickness < c.GridThickness; thickness++ {
-			if y+thickness >= bounds.Max.Y {
-				break
-			}
-
-			for x := bounds.Min.X; x < bounds.Max.X; x++ {
-				img.Set(x, y+thickness, c.GridColor)
-			}
-		}
+		line := image.Rect(bounds.Min.X, y, bounds.Max.X, y+c.GridThickness)
+		FillRect(img, line, c.GridColor, CompositeOver)
 	}
 }
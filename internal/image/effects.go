@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"math"
 	"strings"
 
 	cpkg "github.com/Achno/gowall/internal/backends/color"
+	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 	"github.com/disintegration/imaging"
 )
@@ -19,7 +19,7 @@ func (p *FlipProcessor) Process(img image.Image, theme string, format string) (i
 
 	bounds := img.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
-	newImg := image.NewRGBA(bounds)
+	newImg := imageio.NewCanvas(img, bounds)
 
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
@@ -36,7 +36,7 @@ func (p *MirrorProcessor) Process(img image.Image, theme string, format string)
 
 	bounds := img.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
-	newImg := image.NewRGBA(bounds)
+	newImg := imageio.NewCanvas(img, bounds)
 
 	// Copy the original left half
 	for y := 0; y < height; y++ {
@@ -61,6 +61,9 @@ type GrayScaleProcessor struct{}
 func (p *GrayScaleProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
 
 	bounds := img.Bounds()
+	if imageio.IsHighBitDepth(img) {
+		return grayScale16(img), types.ImageMetadata{}, nil
+	}
 	grayImg := image.NewGray(bounds)
 
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
@@ -77,12 +80,30 @@ func (p *GrayScaleProcessor) Process(img image.Image, theme string, format strin
 	return grayImg, types.ImageMetadata{}, nil
 }
 
+func grayScale16(img image.Image) *image.Gray16 {
+	bounds := img.Bounds()
+	grayImg := image.NewGray16(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			grayValue := uint16(0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b))
+			grayImg.SetGray16(x, y, color.Gray16{Y: grayValue})
+		}
+	}
+	return grayImg
+}
+
 type BrightnessProcessor struct {
 	Factor float64
 }
 
 func (p *BrightnessProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
 
+	if imageio.IsHighBitDepth(img) {
+		return adjustLUT64(img, func(v float64) float64 { return v * p.Factor }), types.ImageMetadata{}, nil
+	}
+
 	bounds := img.Bounds()
 	newImg := image.NewRGBA(bounds)
 
@@ -115,10 +136,18 @@ const (
 )
 
 func (p *ContrastProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	highBitDepth := imageio.IsHighBitDepth(img)
+
 	switch strings.ToLower(p.Mode) {
 	case ContrastModeNormal:
+		if highBitDepth {
+			return contrast64(img, p.Factor), types.ImageMetadata{}, nil
+		}
 		return imaging.AdjustContrast(img, p.Factor), types.ImageMetadata{}, nil
 	case ContrastModeSigmoid:
+		if highBitDepth {
+			return sigmoid64(img, p.Midpoint, p.SigmoidFactor), types.ImageMetadata{}, nil
+		}
 		return imaging.AdjustSigmoid(img, p.Midpoint, p.SigmoidFactor), types.ImageMetadata{}, nil
 	default:
 		return nil, types.ImageMetadata{}, fmt.Errorf("invalid contrast mode: %s", p.Mode)
@@ -130,6 +159,9 @@ type GammaProcessor struct {
 }
 
 func (p *GammaProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	if imageio.IsHighBitDepth(img) {
+		return gamma64(img, p.Gamma), types.ImageMetadata{}, nil
+	}
 	return imaging.AdjustGamma(img, p.Gamma), types.ImageMetadata{}, nil
 }
 
@@ -138,6 +170,9 @@ type SaturationProcessor struct {
 }
 
 func (p *SaturationProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	if imageio.IsHighBitDepth(img) {
+		return saturation64(img, p.Percentage), types.ImageMetadata{}, nil
+	}
 	return imaging.AdjustSaturation(img, p.Percentage), types.ImageMetadata{}, nil
 }
 
@@ -251,7 +286,7 @@ func (p *TiltProcessor) Process(img image.Image, theme string, format string) (i
 	targetX := (outW - contentBounds.Dx()) / 2
 	targetY := (outH - contentBounds.Dy()) / 2
 
-	draw.Draw(final, image.Rect(targetX, targetY, targetX+contentBounds.Dx(), targetY+contentBounds.Dy()), trimmed, image.Point{}, draw.Over)
+	Composite(final, image.Rect(targetX, targetY, targetX+contentBounds.Dx(), targetY+contentBounds.Dy()), trimmed, image.Point{}, CompositeOver)
 
 	return final, types.ImageMetadata{}, nil
 }
@@ -272,7 +307,7 @@ func (p *TiltProcessor) drawBackgroundImage(final *image.RGBA, outW, outH int) {
 	bgImg := p.Preset.BackgroundImage
 	// Scale/crop the background image to fill the output canvas (cover mode)
 	resized := imaging.Fill(bgImg, outW, outH, imaging.Center, imaging.Lanczos)
-	draw.Draw(final, final.Bounds(), resized, image.Point{}, draw.Src)
+	Composite(final, final.Bounds(), resized, image.Point{}, CompositeSrc)
 }
 
 func rotate2D(src image.Image, angleDegrees float64) *image.RGBA {
@@ -382,8 +417,8 @@ func apply3DTransform(src image.Image, tiltX, tiltY, scale float64) *image.RGBA
 	return dst
 }
 
-// Sub-pixel sampling for smooth edges
-func getPixelBilinear(img image.Image, x, y float64) color.RGBA {
+// Sub-pixel sampling for smooth edges, the premultiplied colors are interpolated so transparent neighbours don't darken the edge
+func getPixelBilinear(img image.Image, x, y float64) color.RGBA64 {
 	x0 := int(math.Floor(x))
 	y0 := int(math.Floor(y))
 	x1, y1 := x0+1, y0+1
@@ -393,10 +428,10 @@ func getPixelBilinear(img image.Image, x, y float64) color.RGBA {
 		return img.At(ix, iy).RGBA()
 	}
 
-	interp := func(v00, v10, v01, v11 uint32) uint8 {
+	interp := func(v00, v10, v01, v11 uint32) uint16 {
 		top := float64(v00)*(1-wx) + float64(v10)*wx
 		bot := float64(v01)*(1-wx) + float64(v11)*wx
-		return uint8((top*(1-wy) + bot*wy) / 256)
+		return uint16(math.Round(top*(1-wy) + bot*wy))
 	}
 
 	r00, g00, b00, a00 := get(x0, y0)
@@ -404,7 +439,7 @@ func getPixelBilinear(img image.Image, x, y float64) color.RGBA {
 	r01, g01, b01, a01 := get(x0, y1)
 	r11, g11, b11, a11 := get(x1, y1)
 
-	return color.RGBA{
+	return color.RGBA64{
 		interp(r00, r10, r01, r11),
 		interp(g00, g10, g01, g11),
 		interp(b00, b10, b01, b11),
@@ -446,7 +481,7 @@ func trimContent(img image.Image) (*image.RGBA, image.Rectangle) {
 
 	trimmedRect := image.Rect(minX, minY, maxX+1, maxY+1)
 	trimmed := image.NewRGBA(image.Rect(0, 0, trimmedRect.Dx(), trimmedRect.Dy()))
-	draw.Draw(trimmed, trimmed.Bounds(), img, trimmedRect.Min, draw.Src)
+	Composite(trimmed, trimmed.Bounds(), img, trimmedRect.Min, CompositeSrc)
 
 	return trimmed, trimmedRect
 }
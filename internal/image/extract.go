@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"io"
+	"strings"
 
 	cpkg "github.com/Achno/gowall/internal/backends/color"
 	"github.com/Achno/gowall/internal/backends/colorthief"
@@ -14,20 +16,36 @@ type ExtractProcessor struct {
 	NumOfColors int
 }
 
+// Process returns no image, the palette is written to the output of the operation as one hex code per line
 func (e *ExtractProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
-	clr, err := colorthief.GetPalette(img, e.NumOfColors)
+	palette, err := e.Palette(img)
 	if err != nil {
 		return nil, types.ImageMetadata{}, err
 	}
 
+	encoder := func(w io.Writer, _ image.Image) error {
+		_, err := io.WriteString(w, strings.Join(palette, "\n")+"\n")
+		return err
+	}
+	return nil, types.ImageMetadata{EncoderFunction: encoder}, nil
+}
+
+// Palette returns the dominant colors of the image as hex codes
+func (e *ExtractProcessor) Palette(img image.Image) ([]string, error) {
+	clr, err := colorthief.GetPalette(img, e.NumOfColors)
+	if err != nil {
+		return nil, err
+	}
+
+	palette := make([]string, 0, len(clr))
 	for _, c := range clr {
 		rgba, ok := c.(color.RGBA)
 
 		if !ok {
-			return nil, types.ImageMetadata{}, fmt.Errorf("while RGB casting")
+			return nil, fmt.Errorf("while RGB casting")
 		}
-		fmt.Println(cpkg.RGBtoHex(rgba))
+		palette = append(palette, cpkg.RGBtoHex(rgba))
 	}
 
-	return nil, types.ImageMetadata{}, nil
+	return palette, nil
 }
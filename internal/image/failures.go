@@ -0,0 +1,105 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/Achno/gowall/utils"
+)
+
+// Failure is an input of a batch that could not be processed
+type Failure struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Err    error  `json:"-"`
+}
+
+// BatchError is returned by ProcessImgs when some of its inputs failed or the batch got interrupted
+type BatchError struct {
+	Failures    []Failure
+	Total       int   // number of inputs of the batch
+	Interrupted error // non nil when the context got cancelled before every input was processed
+}
+
+func (e *BatchError) Error() string {
+	var errs []error
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+	if e.Interrupted != nil {
+		errs = append(errs, e.Interrupted)
+	}
+	return utils.FormatErrors(errs)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Interrupted
+}
+
+// AllFailed reports whether not a single input of the batch was processed
+func (e *BatchError) AllFailed() bool {
+	return len(e.Failures) == e.Total
+}
+
+// WriteFailureReport writes every failure of the batch to path (--failure-report),
+// as a json array when path ends in .json and as "input: error" lines otherwise
+func WriteFailureReport(path string, failures []Failure) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		type entry struct {
+			Failure
+			Error string `json:"error"`
+		}
+		entries := make([]entry, 0, len(failures))
+		for _, failure := range failures {
+			entries = append(entries, entry{Failure: failure, Error: failure.Err.Error()})
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(data, '\n'), 0644)
+	}
+
+	var report strings.Builder
+	for _, failure := range failures {
+		fmt.Fprintf(&report, "%s: %v\n", failure.Input, failure.Err)
+	}
+	return os.WriteFile(path, []byte(report.String()), 0644)
+}
+
+// withRetries calls fn until it succeeds, fails with an error that is not transient or ran out of retries.
+// delay doubles after every attempt and waiting stops early when ctx gets cancelled, returning the last error.
+func withRetries(ctx context.Context, retries int, delay time.Duration, input string, fn func() (bool, error)) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		saved, err := fn()
+		if err == nil || !utils.IsTransient(err) || attempt > retries || ctx.Err() != nil {
+			return saved, err
+		}
+		logger.Warnf("::: Retrying %s (%d/%d): %v :::", input, attempt, retries, err)
+
+		select {
+		case <-ctx.Done():
+			return false, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// AsBatchError returns err as a *BatchError if it is one
+func AsBatchError(err error) (*BatchError, bool) {
+	var batchErr *BatchError
+	ok := errors.As(err, &batchErr)
+	return batchErr, ok
+}
@@ -1,6 +1,7 @@
 package image
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
@@ -10,8 +11,10 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
 	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/Achno/gowall/internal/logger"
 	types "github.com/Achno/gowall/internal/types"
@@ -26,6 +29,20 @@ type ImageProcessor interface {
 	Process(image.Image, string, string) (image.Image, types.ImageMetadata, error)
 }
 
+// ContextImageProcessor is an ImageProcessor that can stop early when the context is cancelled (e.g. Ctrl-C).
+// ProcessImgs prefers ProcessContext over Process when a processor implements it.
+type ContextImageProcessor interface {
+	ImageProcessor
+	ProcessContext(context.Context, image.Image, string, string) (image.Image, types.ImageMetadata, error)
+}
+
+// VectorImageProcessor is an ImageProcessor that can also process SVG documents without rasterising them.
+// ProcessImgs uses ProcessSVG when an SVG input is saved as svg (e.g. convert --theme).
+type VectorImageProcessor interface {
+	ImageProcessor
+	ProcessSVG(data []byte, theme string) ([]byte, error)
+}
+
 // MultiImageProcessor accepts multiple inputs and processes them into a single output (e.g.,gif)
 type MultiImageProcessor interface {
 	Composite([]image.Image, string, string) (image.Image, types.ImageMetadata, error)
@@ -43,9 +60,21 @@ func (p *NoOpImageProcessor) Process(img image.Image, options string, format str
 	return img, types.ImageMetadata{}, nil
 }
 
+// ProcessSVG returns the SVG document as is
+func (p *NoOpImageProcessor) ProcessSVG(data []byte, theme string) ([]byte, error) {
+	return data, nil
+}
+
+// PreviewOptions controls how OpenImageInViewer and OpenGifInViewer show an image
+type PreviewOptions struct {
+	Enabled bool   // nothing is shown when false
+	Backend string // "chafa" renders images with chafa, anything else picks a viewer for the terminal
+	Inline  bool   // render in konsole and ghostty with the kitty graphics protocol instead of `kitty icat`
+}
+
 // OpenGifInViewer currently supports GIF preview only in Kitty via `kitty icat`.
-func OpenGifInViewer(filePath string) error {
-	if !config.GowallConfig.EnableImagePreviewing {
+func OpenGifInViewer(filePath string, opts PreviewOptions) error {
+	if !opts.Enabled {
 		return nil
 	}
 
@@ -61,13 +90,13 @@ func OpenGifInViewer(filePath string) error {
 
 // Opens the image on the default viewing application of every operating system.
 // or in the terminal for kitty,wezterm,ghostty and konsole
-func OpenImageInViewer(filePath string) error {
-	if !config.GowallConfig.EnableImagePreviewing {
+func OpenImageInViewer(filePath string, opts PreviewOptions) error {
+	if !opts.Enabled {
 		return nil
 	}
 	var cmd *exec.Cmd
 
-	if config.GowallConfig.ImagePreviewBackend == "chafa" {
+	if opts.Backend == "chafa" {
 		if ok := terminal.HasChafa(); !ok {
 			return fmt.Errorf("you specified `chafa` in ImagePreviewBackend but gowall could not find chafa in your $PATH,ensure chafa is installed")
 		}
@@ -87,14 +116,14 @@ func OpenImageInViewer(filePath string) error {
 
 	isKonsoleOrGhostty := terminal.IsKonsoleTerminalRunning() || terminal.IsGhosttyTerminalRunning()
 
-	if isKonsoleOrGhostty && terminal.HasIcat() && !config.GowallConfig.InlineImagePreview {
+	if isKonsoleOrGhostty && terminal.HasIcat() && !opts.Inline {
 		cmd = exec.Command("kitty", "icat", filePath)
 		cmd.Stdout = os.Stdout
 
 		return cmd.Run()
 	}
 
-	if isKonsoleOrGhostty && config.GowallConfig.InlineImagePreview {
+	if isKonsoleOrGhostty && opts.Inline {
 		return terminal.RenderKittyImg(filePath)
 	}
 
@@ -126,73 +155,250 @@ type CompletionFunc func(outputPath string, remaining int)
 
 // ProcessOptions contains options for ProcessImgs
 type ProcessOptions struct {
-	Theme      string
-	OnComplete CompletionFunc // nil = default behavior
+	Theme       string
+	OnComplete  CompletionFunc // nil = default behavior
+	Concurrency int            // 0 = config.GowallConfig.Concurrency, falling back to the number of CPUs
+	Retries     int            // extra attempts for inputs failing with a transient error (utils.Transient)
+	RetryDelay  time.Duration  // wait before the first retry, doubled after each one, 0 = DefaultRetryDelay
+}
+
+// DefaultRetryDelay is the wait before the first retry of ProcessOptions
+const DefaultRetryDelay = time.Second
+
+// resolveConcurrency returns the number of images that can be processed at the same time.
+func resolveConcurrency(requested int, numOfOps int) int {
+	workers := requested
+	if workers <= 0 {
+		workers = config.GowallConfig.Concurrency
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return max(1, min(workers, numOfOps))
+}
+
+// acquire blocks until a worker slot is free, it returns false if the context got cancelled in the meantime
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case sem <- struct{}{}:
+		if ctx.Err() != nil {
+			<-sem
+			return false
+		}
+		return true
+	}
+}
+
+// processImage calls ProcessContext if the processor supports cancellation, Process otherwise
+func processImage(ctx context.Context, processor ImageProcessor, img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	if ctxProcessor, ok := processor.(ContextImageProcessor); ok {
+		return ctxProcessor.ProcessContext(ctx, img, theme, format)
+	}
+	return processor.Process(img, theme, format)
+}
+
+// processRasterImage loads, processes and saves a single operation, saved is false when ctx got cancelled before saving
+func processRasterImage(ctx context.Context, processor ImageProcessor, op imageio.ImageIO, theme string) (saved bool, err error) {
+	// Load the image, animated inputs keep all of their frames (--frames animate)
+	anim, source, err := op.LoadAnimation()
+	if err != nil {
+		return false, fmt.Errorf("while loading image: %w", err)
+	}
+	if ctx.Err() != nil {
+		return false, nil
+	}
+	// Process the image, processors that return no image (e.g. extract) only get the first frame of animated inputs
+	newImg, metadata, err := processImage(ctx, processor, anim.Frames[0], theme, op.Format)
+	animated := err == nil && newImg != nil && anim.Animated()
+	if animated {
+		anim.Frames[0] = newImg
+		err = processAnimation(ctx, processor, anim, theme, op.Format)
+	}
+	if ctx.Err() != nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("while processing image: %w", err)
+	}
+
+	// Save the image
+	if animated {
+		err = op.SaveAnimation(anim, source)
+	} else {
+		err = op.Save(newImg, metadata, source)
+	}
+	if err != nil {
+		return false, fmt.Errorf("while saving image: %w in %s", err, op.ImageOutput)
+	}
+	return true, nil
+}
+
+// processVectorImage recolors an SVG input without rasterising it, the processor has to implement VectorImageProcessor
+func processVectorImage(processor ImageProcessor, op imageio.ImageIO, theme string) (saved bool, err error) {
+	vectorProcessor, ok := processor.(VectorImageProcessor)
+	if !ok {
+		return false, fmt.Errorf("svg output is not supported by this command, use --format png")
+	}
+
+	data, err := imageio.LoadFileBytes(op.ImageInput)
+	if err != nil {
+		return false, fmt.Errorf("while loading image: %w", err)
+	}
+	if !svg.IsSVG(data) {
+		return false, fmt.Errorf("while loading image: %s is not an SVG, only SVG inputs can be saved as svg", op.ImageInput)
+	}
+
+	newData, err := vectorProcessor.ProcessSVG(data, theme)
+	if err != nil {
+		return false, fmt.Errorf("while processing image: %w", err)
+	}
+	if err := op.SaveSVG(newData); err != nil {
+		return false, fmt.Errorf("while saving image: %w in %s", err, op.ImageOutput)
+	}
+	return true, nil
+}
+
+// processAnimation applies the processor to the frames after the first one of an animated input, the frames are replaced
+// in place and their delays, disposal and loop count are kept for SaveAnimation.
+func processAnimation(ctx context.Context, processor ImageProcessor, anim *imageio.Animation, theme string, format string) error {
+	for i, frame := range anim.Frames {
+		if i == 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		newFrame, _, err := processImage(ctx, processor, frame, theme, format)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		if newFrame == nil {
+			return fmt.Errorf("frame %d: this command does not return an image for every frame, use --frames first", i+1)
+		}
+		anim.Frames[i] = newFrame
+	}
+	return nil
+}
+
+// interruptedError reports how far a batch got before its context was cancelled
+func interruptedError(ctx context.Context, imageOps []imageio.ImageIO, completed []bool, errs []error) error {
+	var skipped []string
+	numCompleted := 0
+	for i, imageOp := range imageOps {
+		switch {
+		case completed[i]:
+			numCompleted++
+		case errs[i] == nil:
+			skipped = append(skipped, imageOp.ImageInput.String())
+		}
+	}
+
+	for _, input := range skipped {
+		logger.Warnf("::: Skipped %s :::", input)
+	}
+
+	return fmt.Errorf("interrupted, %d of %d images completed and %d were skipped: %w", numCompleted, len(imageOps), len(skipped), ctx.Err())
 }
 
 // Processes the image depending on a processor that impliments the "ImageProcessor" interface.
-func ProcessImgs(processor ImageProcessor, imageOps []imageio.ImageIO, opts ProcessOptions) ([]string, error) {
+// At most opts.Concurrency images are in memory at once and the returned paths keep the order of imageOps.
+// When ctx is cancelled no new images are started, in-flight images are dropped before saving and the error summarises what was skipped.
+// A failing input does not stop the others, the returned *BatchError lists every failure next to the paths that were saved.
+func ProcessImgs(ctx context.Context, processor ImageProcessor, imageOps []imageio.ImageIO, opts ProcessOptions) ([]string, error) {
 	var wg sync.WaitGroup
 	remaining := int32(len(imageOps))
-	errChan := make(chan error, len(imageOps))
-	var processedImagesFilePaths []string
+	processedImagesFilePaths := make([]string, len(imageOps))
+	completed := make([]bool, len(imageOps))
+	errs := make([]error, len(imageOps))
+	sem := make(chan struct{}, resolveConcurrency(opts.Concurrency, len(imageOps)))
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = DefaultRetryDelay
+	}
+
+	// optionally specify a temporary theme via json file in runtime
+	theme := opts.Theme
+	if strings.HasSuffix(theme, ".json") {
+		var err error
+		theme, err = LoadThemeFromJson(theme)
+		if err != nil {
+			return nil, fmt.Errorf("file %s : %w", opts.Theme, err)
+		}
+	}
 
-	// Load the image
 	for index, imageOp := range imageOps {
+		if !acquire(ctx, sem) {
+			break
+		}
 		wg.Add(1)
 		go func(i int, imgProcessor ImageProcessor, currentImgOp imageio.ImageIO) {
 			defer wg.Done()
-			theme := opts.Theme
-			img, err := imageio.LoadImage(currentImgOp.ImageInput)
-			if err != nil {
-				errChan <- fmt.Errorf("while loading image: %w", err)
-				return
-			}
-			// optionally specify a temporary theme via json file in runtime
-			if strings.HasSuffix(theme, ".json") {
-				theme, err = LoadThemeFromJson(theme)
-				if err != nil {
-					errChan <- fmt.Errorf("file %s : %w", currentImgOp.ImageInput, err)
-					return
+			defer func() { <-sem }()
+
+			// --json: one event per input, unless it was skipped by a cancellation
+			start := time.Now()
+			defer func() {
+				if completed[i] || errs[i] != nil {
+					logger.File([]string{currentImgOp.ImageInput.String()}, currentImgOp.ImageOutput.String(), time.Since(start), errs[i])
 				}
-			}
-			// Process the image
-			newImg, metadata, err := imgProcessor.Process(img, theme, currentImgOp.Format)
+			}()
+
+			// SVG outputs keep the vectors of SVG inputs (convert --theme), everything else is rasterised
+			saved, err := withRetries(ctx, opts.Retries, retryDelay, currentImgOp.ImageInput.String(), func() (bool, error) {
+				if strings.EqualFold(currentImgOp.Format, imageio.FormatSVG) {
+					return processVectorImage(imgProcessor, currentImgOp, theme)
+				}
+				return processRasterImage(ctx, imgProcessor, currentImgOp, theme)
+			})
 			if err != nil {
-				errChan <- fmt.Errorf("while processing image: %w", err)
+				errs[i] = err
 				return
 			}
-
-			// Save the image
-			err = imageio.SaveImage(newImg, currentImgOp.ImageOutput, currentImgOp.Format, metadata)
-			if err != nil {
-				errChan <- fmt.Errorf("while saving image: %w in %s", err, currentImgOp.ImageOutput)
+			if !saved {
 				return
 			}
 			remainingCount := atomic.AddInt32(&remaining, -1)
 			if opts.OnComplete != nil {
 				opts.OnComplete(currentImgOp.ImageOutput.String(), int(remainingCount))
-			} else {
+			} else if !logger.JSON() {
 				// Default completion message
 				logger.Printf("::: Image completed & saved in %s, %d Images left :::\n", currentImgOp.ImageOutput.String(), remainingCount)
 			}
-			processedImagesFilePaths = append(processedImagesFilePaths, currentImgOp.ImageOutput.String())
-		}(index, processor, imageOp)
+			currentImgOp.Manifest.Record(currentImgOp.ImageOutput)
+			processedImagesFilePaths[i] = currentImgOp.ImageOutput.String()
+			completed[i] = true
+		}(index, processor, imageOp.WithContext(ctx))
 	}
 	wg.Wait()
-	close(errChan)
 
-	if len(errChan) > 0 {
-		// return <-errChan
-		var errs []error
+	// --incremental: remember the saved outputs, even when interrupted, so they are skipped next time
+	if err := imageio.SaveManifests(imageOps); err != nil {
+		logger.Warnf("::: Could not save the incremental manifest: %v :::", err)
+	}
 
-		for err := range errChan {
-			errs = append(errs, err)
+	// Keep only the successful outputs, in the same order as imageOps
+	var paths []string
+	batchErr := &BatchError{Total: len(imageOps)}
+	for i, imageOp := range imageOps {
+		if errs[i] != nil {
+			batchErr.Failures = append(batchErr.Failures, Failure{Input: imageOp.ImageInput.String(), Output: imageOp.ImageOutput.String(), Err: errs[i]})
+			continue
 		}
+		if completed[i] {
+			paths = append(paths, processedImagesFilePaths[i])
+		}
+	}
+
+	if ctx.Err() != nil {
+		batchErr.Interrupted = interruptedError(ctx, imageOps, completed, errs)
+	}
 
-		return processedImagesFilePaths, errors.New(utils.FormatErrors(errs))
+	if len(batchErr.Failures) > 0 || batchErr.Interrupted != nil {
+		return paths, batchErr
 	}
-	return processedImagesFilePaths, nil
+	return paths, nil
 }
 
 // MultiCompletionFunc is called after multi-image processing is complete
@@ -200,22 +406,35 @@ type MultiCompletionFunc func(outputPath string, numInputs int)
 
 // MultiProcessOptions contains options for MultiProcessImgs
 type MultiProcessOptions struct {
-	Theme      string
-	OnComplete MultiCompletionFunc // nil = default behavior
+	Theme       string
+	OnComplete  MultiCompletionFunc // nil = default behavior
+	Concurrency int                 // 0 = config.GowallConfig.Concurrency, falling back to the number of CPUs
 }
 
 // MultiProcessImgs loads multiple images, processes them together via Composite, and saves single output (N:1)
-func MultiProcessImgs(processor MultiImageProcessor, imageOps []imageio.ImageIO, opts MultiProcessOptions) (string, error) {
+// Cancelling ctx stops loading the remaining inputs and nothing is saved.
+func MultiProcessImgs(ctx context.Context, processor MultiImageProcessor, imageOps []imageio.ImageIO, opts MultiProcessOptions) (string, error) {
+
+	start := time.Now()
+	inputs := make([]string, len(imageOps))
+	for i, imageOp := range imageOps {
+		inputs[i] = imageOp.ImageInput.String()
+	}
 
 	var wg sync.WaitGroup
 	images := make([]image.Image, len(imageOps))
 	errChan := make(chan error, len(imageOps))
+	sem := make(chan struct{}, resolveConcurrency(opts.Concurrency, len(imageOps)))
 
 	for i, imageOp := range imageOps {
+		if !acquire(ctx, sem) {
+			break
+		}
 		wg.Add(1)
 		go func(index int, currentImgOp imageio.ImageIO) {
 			defer wg.Done()
-			img, err := imageio.LoadImage(currentImgOp.ImageInput)
+			defer func() { <-sem }()
+			img, _, err := currentImgOp.Load()
 			if err != nil {
 				errChan <- fmt.Errorf("while loading image %s: %w", currentImgOp.ImageInput.String(), err)
 				return
@@ -234,6 +453,9 @@ func MultiProcessImgs(processor MultiImageProcessor, imageOps []imageio.ImageIO,
 		}
 		return "", errors.New(utils.FormatErrors(errs))
 	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("interrupted while loading images, nothing was saved: %w", ctx.Err())
+	}
 
 	// All imageOps have the same output and format for multi-input commands
 	output := imageOps[0].ImageOutput
@@ -243,15 +465,19 @@ func MultiProcessImgs(processor MultiImageProcessor, imageOps []imageio.ImageIO,
 	if err != nil {
 		return "", fmt.Errorf("while compositing images: %w", err)
 	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("interrupted before saving %s: %w", output.String(), ctx.Err())
+	}
 
-	err = imageio.SaveImage(resultImg, output, format, metadata)
+	err = imageOps[0].Save(resultImg, metadata, nil)
+	logger.File(inputs, output.String(), time.Since(start), err)
 	if err != nil {
 		return "", fmt.Errorf("while saving image: %w", err)
 	}
 
 	if opts.OnComplete != nil {
 		opts.OnComplete(output.String(), len(images))
-	} else {
+	} else if !logger.JSON() {
 		// Default completion message
 		logger.Printf("::: Multi-image processing completed & saved in %s :::\n", output.String())
 	}
@@ -0,0 +1,74 @@
+package image
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	imageio "github.com/Achno/gowall/internal/image_io"
+)
+
+// writeAnimatedGIF writes a gif whose frames are filled with the colors, one frame per color
+func writeAnimatedGIF(t *testing.T, path string, colors ...color.Color) {
+	t.Helper()
+	palette := color.Palette(colors)
+	g := &gif.GIF{}
+	for i := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, 16, 16), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i)
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := gif.EncodeAll(file, g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessImgsExtractAnimated(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "anim.gif")
+	writeAnimatedGIF(t, input, color.RGBA{R: 0xff, A: 0xff}, color.RGBA{B: 0xff, A: 0xff})
+
+	output := filepath.Join(dir, "palette.txt")
+	ops := []imageio.ImageIO{{
+		ImageInput:  imageio.FileReader{Path: input},
+		ImageOutput: imageio.FileWriter{Path: output},
+		Format:      "gif",
+		Frames:      imageio.FramesAnimate,
+	}}
+
+	// extract returns no image, the palette of the first frame is written instead of failing on the animation
+	_, err := ProcessImgs(context.Background(), &ExtractProcessor{NumOfColors: 2}, ops, ProcessOptions{
+		OnComplete: func(outputPath string, remaining int) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	palette := strings.Fields(string(data))
+	if len(palette) == 0 {
+		t.Fatal("no palette was written")
+	}
+	for _, hex := range palette {
+		if hex == "#0000FF" {
+			t.Errorf("palette %v has the color of the second frame", palette)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"image"
 
+	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 	"github.com/disintegration/imaging"
 )
@@ -23,6 +24,9 @@ func (Invrt *Inverter) Process(img image.Image, theme string, format string) (im
 }
 
 func invertImage(img image.Image) (image.Image, error) {
+	if imageio.IsHighBitDepth(img) {
+		return adjustLUT64(img, func(v float64) float64 { return 1 - v }), nil
+	}
 	newImg := imaging.Invert(img)
 	if newImg == nil {
 		return nil, errors.New("error while inverting the image")
@@ -0,0 +1,36 @@
+package image
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
+	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
+	types "github.com/Achno/gowall/internal/types"
+)
+
+// LUTProcessor grades images with an external 3D LUT, a .cube file or a Hald PNG (convert --lut)
+type LUTProcessor struct {
+	CLUT  *image.RGBA
+	Level int
+}
+
+// NewLUTProcessor loads the .cube or Hald PNG LUT at path
+func NewLUTProcessor(path string) (*LUTProcessor, error) {
+	clut, level, err := haldclut.LoadLUT(path)
+	if err != nil {
+		return nil, err
+	}
+	return &LUTProcessor{CLUT: clut, Level: level}, nil
+}
+
+func (p *LUTProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	return ApplyThemeCLUT(img, p.CLUT, p.Level), types.ImageMetadata{}, nil
+}
+
+// ProcessSVG maps every color of an SVG document through the LUT, the vectors are kept
+func (p *LUTProcessor) ProcessSVG(data []byte, theme string) ([]byte, error) {
+	return svg.Recolor(data, func(c color.Color) color.Color {
+		return haldclut.MapColor(p.CLUT, p.Level, color.NRGBAModel.Convert(c).(color.NRGBA))
+	}), nil
+}
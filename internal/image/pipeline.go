@@ -0,0 +1,508 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
+	"github.com/Achno/gowall/internal/backends/dither"
+	types "github.com/Achno/gowall/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Recipe describes a chain of processors that are applied in memory one after the other.
+//
+//	name: nord-framed
+//	theme: nord
+//	steps:
+//	  - processor: convert
+//	  - processor: br
+//	    options:
+//	      factor: 1.2
+//	  - processor: border
+//	    options:
+//	      color: "#5D3FD3"
+//	      thickness: 5
+type Recipe struct {
+	Name  string       `yaml:"name"`
+	Theme string       `yaml:"theme"` // default theme for steps that do not set their own
+	Steps []RecipeStep `yaml:"steps"`
+}
+
+type RecipeStep struct {
+	Processor string      `yaml:"processor"`
+	Theme     string      `yaml:"theme"`
+	Options   StepOptions `yaml:"options"`
+}
+
+// StepOptions holds the per-step options, values can either come from yaml or from the command line as strings.
+type StepOptions map[string]any
+
+func (o StepOptions) String(key string, def string) string {
+	v, ok := o[key]
+	if !ok || v == nil {
+		return def
+	}
+	return fmt.Sprint(v)
+}
+
+func (o StepOptions) Float(key string, def float64) (float64, error) {
+	v, ok := o[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case int:
+		return float64(val), nil
+	default:
+		f, err := strconv.ParseFloat(fmt.Sprint(val), 64)
+		if err != nil {
+			return 0, fmt.Errorf("option %q must be a number, got: %v", key, v)
+		}
+		return f, nil
+	}
+}
+
+func (o StepOptions) Int(key string, def int) (int, error) {
+	v, ok := o[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch val := v.(type) {
+	case int:
+		return val, nil
+	default:
+		i, err := strconv.Atoi(fmt.Sprint(val))
+		if err != nil {
+			return 0, fmt.Errorf("option %q must be an integer, got: %v", key, v)
+		}
+		return i, nil
+	}
+}
+
+func (o StepOptions) Bool(key string, def bool) (bool, error) {
+	v, ok := o[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	default:
+		b, err := strconv.ParseBool(fmt.Sprint(val))
+		if err != nil {
+			return false, fmt.Errorf("option %q must be true or false, got: %v", key, v)
+		}
+		return b, nil
+	}
+}
+
+// LoadRecipe reads a yaml recipe file.
+func LoadRecipe(path string) (Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("while reading recipe: %w", err)
+	}
+
+	var recipe Recipe
+	if err := yaml.Unmarshal(data, &recipe); err != nil {
+		return Recipe{}, fmt.Errorf("while parsing recipe %s: %w", path, err)
+	}
+	if len(recipe.Steps) == 0 {
+		return Recipe{}, fmt.Errorf("recipe %s does not contain any steps", path)
+	}
+
+	return recipe, nil
+}
+
+// ParseRecipeStep parses an inline step in the form of name:key=value,key=value (e.g. "border:color=#5D3FD3,thickness=5")
+func ParseRecipeStep(step string) (RecipeStep, error) {
+	name, rawOpts, _ := strings.Cut(strings.TrimSpace(step), ":")
+	if name == "" {
+		return RecipeStep{}, fmt.Errorf("invalid step %q, expected name:key=value,key=value", step)
+	}
+
+	parsed := RecipeStep{Processor: name, Options: StepOptions{}}
+	if rawOpts == "" {
+		return parsed, nil
+	}
+
+	for pair := range strings.SplitSeq(rawOpts, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return RecipeStep{}, fmt.Errorf("invalid option %q in step %q, expected key=value", pair, step)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key == "theme" {
+			parsed.Theme = value
+			continue
+		}
+		parsed.Options[key] = value
+	}
+
+	return parsed, nil
+}
+
+// StepConfig is the configuration of the steps that depend on the environment rather than on the recipe,
+// the cli fills it from config.yml
+type StepConfig struct {
+	ColorCorrectionBackend string // default Backend of the convert steps, nn or a palette mapper
+	CLUTDir                string // folder the convert steps cache their HaldCLUTs in
+	CLUTLevel              int    // default level of the HaldCLUTs of the convert steps, 0 = haldclut.DefaultLevel
+	ColorDistance          string // default distance metric of the nn convert steps and the replace steps, "" = rgb
+	UpscalerDir            string // folder of the binary of the upscale steps
+	PngquantDir            string // folder of the pngquant binary of the compress steps
+}
+
+// getStepFactories returns the processors that can be used as a step in a recipe
+func getStepFactories(cfg StepConfig) map[string]func(opts StepOptions) (ImageProcessor, error) {
+
+	//? Here is where recipe steps are registered, the keys match the cli command names.
+	return map[string]func(opts StepOptions) (ImageProcessor, error){
+		"convert": func(opts StepOptions) (ImageProcessor, error) {
+			level, err := opts.Int("level", cfg.CLUTLevel)
+			if err != nil {
+				return nil, err
+			}
+			if level != 0 {
+				if err := haldclut.ValidateLevel(level); err != nil {
+					return nil, err
+				}
+			}
+			backend := opts.String("mapper", cfg.ColorCorrectionBackend)
+			if backend != BackendNearestNeighbour {
+				if _, err := haldclut.ParseMapper(backend); err != nil {
+					return nil, err
+				}
+			}
+			distance, err := cpkg.ParseMetric(opts.String("distance", cfg.ColorDistance))
+			if err != nil {
+				return nil, err
+			}
+			strength, err := opts.Float("dither-strength", 0)
+			if err != nil {
+				return nil, err
+			}
+			dithering := dither.Options{Mode: opts.String("dither", ""), Strength: strength}
+			if err := dithering.Validate(); err != nil {
+				return nil, err
+			}
+			if backend != BackendNearestNeighbour {
+				if _, ok := opts["distance"]; ok {
+					return nil, fmt.Errorf("distance only works with mapper=%s", BackendNearestNeighbour)
+				}
+				if dithering.Enabled() {
+					return nil, fmt.Errorf("dither only works with mapper=%s", BackendNearestNeighbour)
+				}
+			}
+			converter := NewThemeConverter(backend, cfg.CLUTDir, level, distance)
+			converter.Dither = dithering
+			return converter, nil
+		},
+		"replace": func(opts StepOptions) (ImageProcessor, error) {
+			threshold, err := opts.Float("threshold", 8.5)
+			if err != nil {
+				return nil, err
+			}
+			distance, err := cpkg.ParseMetric(opts.String("distance", cfg.ColorDistance))
+			if err != nil {
+				return nil, err
+			}
+			p := &ReplaceProcessor{
+				FromColor: opts.String("from", ""),
+				ToColor:   opts.String("to", ""),
+				Threshold: threshold,
+				Distance:  distance,
+			}
+			if p.FromColor == "" || p.ToColor == "" {
+				return nil, fmt.Errorf("specify both the from and to colors")
+			}
+			return p, nil
+		},
+		"invert": func(opts StepOptions) (ImageProcessor, error) {
+			return &Inverter{}, nil
+		},
+		"flip": func(opts StepOptions) (ImageProcessor, error) {
+			return &FlipProcessor{}, nil
+		},
+		"mirror": func(opts StepOptions) (ImageProcessor, error) {
+			return &MirrorProcessor{}, nil
+		},
+		"grayscale": func(opts StepOptions) (ImageProcessor, error) {
+			return &GrayScaleProcessor{}, nil
+		},
+		"br": func(opts StepOptions) (ImageProcessor, error) {
+			factor, err := opts.Float("factor", 1.1)
+			if err != nil {
+				return nil, err
+			}
+			if factor <= 0.0 || factor > 10.0 {
+				return nil, fmt.Errorf("factor must be in range (0.0, 10.0], got: %.2f", factor)
+			}
+			return &BrightnessProcessor{Factor: factor}, nil
+		},
+		"contrast": func(opts StepOptions) (ImageProcessor, error) {
+			factor, err := opts.Float("factor", 0.0)
+			if err != nil {
+				return nil, err
+			}
+			midpoint, err := opts.Float("midpoint", 0.5)
+			if err != nil {
+				return nil, err
+			}
+			sigmoidFactor, err := opts.Float("sigmoid-factor", 0.0)
+			if err != nil {
+				return nil, err
+			}
+			mode := strings.ToLower(opts.String("mode", ContrastModeNormal))
+			if mode != ContrastModeNormal && mode != ContrastModeSigmoid {
+				return nil, fmt.Errorf("invalid mode '%s', valid modes: %s, %s", mode, ContrastModeNormal, ContrastModeSigmoid)
+			}
+			return &ContrastProcessor{Mode: mode, Factor: factor, Midpoint: midpoint, SigmoidFactor: sigmoidFactor}, nil
+		},
+		"gamma": func(opts StepOptions) (ImageProcessor, error) {
+			gamma, err := opts.Float("gamma", 1.0)
+			if err != nil {
+				return nil, err
+			}
+			if gamma <= 0.0 {
+				return nil, fmt.Errorf("gamma must be > 0.0, got: %.2f", gamma)
+			}
+			return &GammaProcessor{Gamma: gamma}, nil
+		},
+		"saturation": func(opts StepOptions) (ImageProcessor, error) {
+			percentage, err := opts.Float("percentage", 0.0)
+			if err != nil {
+				return nil, err
+			}
+			if percentage < -100.0 || percentage > 100.0 {
+				return nil, fmt.Errorf("percentage must be in range [-100.0, 100.0], got: %.2f", percentage)
+			}
+			return &SaturationProcessor{Percentage: percentage}, nil
+		},
+		"tilt": func(opts StepOptions) (ImageProcessor, error) {
+			preset, err := GetTiltPreset(opts.String("preset", ""))
+			if err != nil {
+				return nil, err
+			}
+			return &TiltProcessor{Preset: preset}, nil
+		},
+		"border": func(opts StepOptions) (ImageProcessor, error) {
+			hex, err := cpkg.ParseColorToHex(opts.String("color", ""))
+			if err != nil {
+				return nil, err
+			}
+			clr, err := cpkg.HexToRGBA(hex)
+			if err != nil {
+				return nil, err
+			}
+			thickness, err := opts.Int("thickness", 5)
+			if err != nil {
+				return nil, err
+			}
+			radius, err := opts.Float("radius", 0)
+			if err != nil {
+				return nil, err
+			}
+			return &BorderProcessor{Color: clr, BorderThickness: thickness, CornerRadius: radius}, nil
+		},
+		"round": func(opts StepOptions) (ImageProcessor, error) {
+			radius, err := opts.Float("radius", 30)
+			if err != nil {
+				return nil, err
+			}
+			if radius <= 0 {
+				return nil, fmt.Errorf("corner radius must be greater than 0")
+			}
+			return &RoundProcessor{CornerRadius: radius}, nil
+		},
+		"grid": func(opts StepOptions) (ImageProcessor, error) {
+			size, err := opts.Int("size", 80)
+			if err != nil {
+				return nil, err
+			}
+			thickness, err := opts.Int("thickness", 1)
+			if err != nil {
+				return nil, err
+			}
+			mask, err := opts.Bool("mask", false)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := cpkg.HexToRGBA(opts.String("color", "#5D3FD3")); err != nil {
+				return nil, err
+			}
+			p := &GridProcessor{}
+			p.SetGridOptions(
+				WithGridSize(size),
+				WithGridColor(opts.String("color", "#5D3FD3")),
+				WithGridThickness(thickness),
+				WithMaskonly(mask),
+			)
+			return p, nil
+		},
+		"resize": func(opts StepOptions) (ImageProcessor, error) {
+			width, err := opts.Int("width", 0)
+			if err != nil {
+				return nil, err
+			}
+			height, err := opts.Int("height", 0)
+			if err != nil {
+				return nil, err
+			}
+			method := opts.String("method", "lanczos")
+			if _, err := mapMethodNameToFilter(method); err != nil {
+				return nil, err
+			}
+			p := &ResizeProcessor{}
+			p.SetOptions(WithWidth(width), WithHeight(height), WithMethod(method))
+			return p, nil
+		},
+		"pixelate": func(opts StepOptions) (ImageProcessor, error) {
+			scale, err := opts.Float("scale", 15)
+			if err != nil {
+				return nil, err
+			}
+			if scale < 1 || scale > 25 {
+				return nil, fmt.Errorf("scale must be between 1 and 25, got: %.2f", scale)
+			}
+			return &PixelateProcessor{Scale: scale}, nil
+		},
+		"compress": func(opts StepOptions) (ImageProcessor, error) {
+			quality, err := opts.Int("quality", 80)
+			if err != nil {
+				return nil, err
+			}
+			speed, err := opts.Int("speed", 4)
+			if err != nil {
+				return nil, err
+			}
+			return NewCompressionProcessor(
+				WithStrategy(opts.String("method", "")),
+				WithQuality(quality),
+				WithSpeed(speed),
+				WithBinaryDir(cfg.PngquantDir),
+			), nil
+		},
+		"upscale": func(opts StepOptions) (ImageProcessor, error) {
+			scale, err := opts.Int("scale", 2)
+			if err != nil {
+				return nil, err
+			}
+			if scale < 2 || scale > 4 {
+				return nil, fmt.Errorf("scale must be 2, 3, or 4, got: %d", scale)
+			}
+			return NewUpscaleProcessor(scale, opts.String("model", "realesr-animevideov3"), cfg.UpscalerDir), nil
+		},
+	}
+}
+
+// GetRecipeStepNames returns the names of the processors that can be used in a recipe
+func GetRecipeStepNames() []string {
+	factories := getStepFactories(StepConfig{})
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type pipelineStep struct {
+	name      string
+	theme     string
+	processor ImageProcessor
+}
+
+// PipelineProcessor implements the ImageProcessor interface by chaining other ImageProcessors in memory.
+type PipelineProcessor struct {
+	steps []pipelineStep
+}
+
+// NewPipelineProcessor builds every step of the recipe with cfg, failing early on unknown processors or invalid options.
+func NewPipelineProcessor(recipe Recipe, cfg StepConfig) (*PipelineProcessor, error) {
+	if len(recipe.Steps) == 0 {
+		return nil, fmt.Errorf("a pipeline needs at least one step")
+	}
+
+	factories := getStepFactories(cfg)
+	p := &PipelineProcessor{}
+
+	for i, step := range recipe.Steps {
+		name := strings.ToLower(strings.TrimSpace(step.Processor))
+		factory, ok := factories[name]
+		if !ok {
+			return nil, fmt.Errorf("step %d: unknown processor %q, available: %s", i+1, step.Processor, strings.Join(GetRecipeStepNames(), ", "))
+		}
+
+		processor, err := factory(step.Options)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, name, err)
+		}
+
+		theme := step.Theme
+		if theme == "" {
+			theme = recipe.Theme
+		}
+		if name == "convert" && theme == "" {
+			return nil, fmt.Errorf("step %d (convert): no theme given, set it on the step, the recipe or via --theme", i+1)
+		}
+		// optionally specify a temporary theme via json file in runtime
+		if strings.HasSuffix(theme, ".json") {
+			theme, err = LoadThemeFromJson(theme)
+			if err != nil {
+				return nil, fmt.Errorf("step %d (%s): %w", i+1, name, err)
+			}
+		}
+
+		p.steps = append(p.steps, pipelineStep{name: name, theme: theme, processor: processor})
+	}
+
+	return p, nil
+}
+
+// Process runs every step on the output of the previous one, the theme argument is used for steps without their own theme.
+func (p *PipelineProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	return p.ProcessContext(context.Background(), img, theme, format)
+}
+
+// ProcessContext runs the steps like Process but stops between steps once ctx is cancelled.
+func (p *PipelineProcessor) ProcessContext(ctx context.Context, img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	var metadata types.ImageMetadata
+
+	for i, step := range p.steps {
+		if err := ctx.Err(); err != nil {
+			return nil, types.ImageMetadata{}, fmt.Errorf("before step %d (%s): %w", i+1, step.name, err)
+		}
+
+		stepTheme := step.theme
+		if stepTheme == "" {
+			stepTheme = theme
+		}
+
+		newImg, stepMetadata, err := processImage(ctx, step.processor, img, stepTheme, format)
+		if err != nil {
+			return nil, types.ImageMetadata{}, fmt.Errorf("step %d (%s): %w", i+1, step.name, err)
+		}
+		if newImg == nil {
+			return nil, types.ImageMetadata{}, fmt.Errorf("step %d (%s) did not produce an image", i+1, step.name)
+		}
+
+		// keep the custom encoder of a step (e.g. compression) so it is used when saving the final image
+		if stepMetadata.EncoderFunction != nil {
+			metadata.EncoderFunction = stepMetadata.EncoderFunction
+		}
+		img = newImg
+	}
+
+	return img, metadata, nil
+}
@@ -4,6 +4,7 @@ import (
 	"image"
 	"math"
 
+	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 )
 
@@ -34,7 +35,7 @@ func downscale(img image.Image, scale float64) image.Image {
 	width := int(math.Round(float64(bounds.Dx()) * scale))
 	height := int(math.Round(float64(bounds.Dy()) * scale))
 
-	newImage := image.NewRGBA(image.Rect(0, 0, width, height))
+	newImage := imageio.NewCanvas(img, image.Rect(0, 0, width, height))
 
 	// pick the nearest pixel for the new scaled image
 	for y := 0; y < height; y++ {
@@ -50,7 +51,7 @@ func downscale(img image.Image, scale float64) image.Image {
 
 func upscale(img image.Image, originalWidth, originalHeight int) image.Image {
 
-	newImage := image.NewRGBA(image.Rect(0, 0, originalWidth, originalHeight))
+	newImage := imageio.NewCanvas(img, image.Rect(0, 0, originalWidth, originalHeight))
 
 	bounds := img.Bounds()
 	width := bounds.Dx()
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"slices"
 
 	bgremoval "github.com/Achno/gowall/internal/backends/bgRemoval"
@@ -37,8 +36,8 @@ func (p *BackgroundProcessor) Process(img image.Image, theme string, format stri
 	if p.backgroundClr != nil {
 		bounds := newImg.Bounds()
 		dst := image.NewNRGBA(bounds)
-		draw.Draw(dst, bounds, &image.Uniform{C: p.backgroundClr}, image.Point{}, draw.Src)
-		draw.Draw(dst, bounds, newImg, bounds.Min, draw.Over)
+		FillRect(dst, bounds, p.backgroundClr, CompositeSrc)
+		Composite(dst, bounds, newImg, bounds.Min, CompositeOver)
 		newImg = dst
 	}
 
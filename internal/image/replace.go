@@ -5,7 +5,9 @@ import (
 	"image"
 	"image/color"
 
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
 	cpkg "github.com/Achno/gowall/internal/backends/color"
+	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 )
 
@@ -13,6 +15,7 @@ type ReplaceProcessor struct {
 	FromColor string
 	ToColor   string
 	Threshold float64
+	Distance  cpkg.Metric // how close a pixel is to FromColor, the zero value is rgb
 }
 
 func (r *ReplaceProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
@@ -28,7 +31,7 @@ func (r *ReplaceProcessor) Process(img image.Image, theme string, format string)
 	if err != nil {
 		return nil, types.ImageMetadata{}, err
 	}
-	newimage, err := replaceColor(img, from, to, r.Threshold)
+	newimage, err := replaceColor(img, from, to, r.Threshold, r.Distance)
 
 	if err != nil {
 		return nil, types.ImageMetadata{}, fmt.Errorf("replacing color failed : %w", err)
@@ -37,19 +40,49 @@ func (r *ReplaceProcessor) Process(img image.Image, theme string, format string)
 	return newimage, types.ImageMetadata{}, nil
 }
 
-// replaces every pixel from the "from" color over to the "to" color in the image
-func replaceColor(img image.Image, from, to color.Color, threshold float64) (image.Image, error) {
+// ProcessSVG replaces the "from" color in the fills, strokes and gradients of an SVG document, the vectors are kept
+func (r *ReplaceProcessor) ProcessSVG(data []byte, theme string) ([]byte, error) {
+	from, err := cpkg.HexToRGBA(r.FromColor)
+	if err != nil {
+		return nil, err
+	}
+	to, err := cpkg.HexToRGBA(r.ToColor)
+	if err != nil {
+		return nil, err
+	}
+
+	replacementMade := false
+	fromPoint := r.Distance.Point(from)
+	newData := svg.Recolor(data, func(c color.Color) color.Color {
+		blendWeight := r.Distance.PointSimilarityWeight(r.Distance.Point(c), fromPoint, r.Threshold)
+		if blendWeight <= 0 {
+			return c
+		}
+		replacementMade = true
+		return MixColors(c, to, blendWeight)
+	})
+
+	if !replacementMade {
+		return nil, fmt.Errorf("the color : %s was not found in the image, nothing to replace", cpkg.RGBtoHex(from))
+	}
+	return newData, nil
+}
+
+// replaces every pixel from the "from" color over to the "to" color in the image, similar colors according to the metric are blended
+func replaceColor(img image.Image, from, to color.Color, threshold float64, metric cpkg.Metric) (image.Image, error) {
 	bounds := img.Bounds()
-	newImg := image.NewRGBA(bounds)
+	newImg := imageio.NewCanvas(img, bounds)
 
 	replacementMade := false
+	// from is converted once, with perceptual metrics the conversion costs as much as the comparison
+	fromPoint := metric.Point(from)
 
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			originalColor := img.At(x, y)
-			blendWeight := cpkg.ColorSimilarityWeight(originalColor, from, threshold)
+			blendWeight := metric.PointSimilarityWeight(metric.Point(originalColor), fromPoint, threshold)
 			if blendWeight > 0 {
-				newImg.Set(x, y, cpkg.BlendColor(originalColor, to, blendWeight))
+				newImg.Set(x, y, MixColors(originalColor, to, blendWeight))
 				replacementMade = true
 			} else {
 				newImg.Set(x, y, originalColor)
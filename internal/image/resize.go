@@ -121,7 +121,7 @@ func ResizeWithPadding(img image.Image, width, height int) image.Image {
 		centered := image.NewRGBA(image.Rect(0, 0, width, height))
 		offsetX := (width - newWidth) / 2
 		offsetY := (height - newHeight) / 2
-		draw.Draw(centered, centered.Bounds(), dst, image.Point{-offsetX, -offsetY}, draw.Over)
+		Composite(centered, centered.Bounds(), dst, image.Point{-offsetX, -offsetY}, CompositeOver)
 		return centered
 	}
 
@@ -53,7 +53,7 @@ func (p *StackProcessor) Composite(images []image.Image, theme string, format st
 	stacked := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
 
 	if p.BorderThickness > 0 {
-		draw.Draw(stacked, stacked.Bounds(), &image.Uniform{C: p.BorderColor}, image.Point{}, draw.Src)
+		FillRect(stacked, stacked.Bounds(), p.BorderColor, CompositeSrc)
 	}
 
 	// Clear all cell interiors so empty cells remain transparent.
@@ -62,7 +62,7 @@ func (p *StackProcessor) Composite(images []image.Image, theme string, format st
 			x := p.BorderThickness + col*(cellWidth+p.BorderThickness)
 			y := p.BorderThickness + row*(cellHeight+p.BorderThickness)
 			cellRect := image.Rect(x, y, x+cellWidth, y+cellHeight)
-			draw.Draw(stacked, cellRect, image.Transparent, image.Point{}, draw.Src)
+			FillRect(stacked, cellRect, color.Transparent, CompositeClear)
 		}
 	}
 
@@ -136,7 +136,7 @@ func stackCellDimensions(images []image.Image, resizeMode string) (int, int) {
 	return maxWidth, maxHeight
 }
 
-func (p *StackProcessor) drawImageInCell(dst *image.RGBA, src image.Image, index, cols, cellWidth, cellHeight int) {
+func (p *StackProcessor) drawImageInCell(dst draw.Image, src image.Image, index, cols, cellWidth, cellHeight int) {
 	row := index / cols
 	col := index % cols
 
@@ -151,5 +151,5 @@ func (p *StackProcessor) drawImageInCell(dst *image.RGBA, src image.Image, index
 	offsetY := y + (cellHeight-srcHeight)/2
 	dstRect := image.Rect(offsetX, offsetY, offsetX+srcWidth, offsetY+srcHeight)
 
-	draw.Draw(dst, dstRect, src, bounds.Min, draw.Over)
+	Composite(dst, dstRect, src, bounds.Min, CompositeOver)
 }
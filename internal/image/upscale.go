@@ -1,13 +1,13 @@
 package image
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"os"
 	"os/exec"
 	"path/filepath"
 
-	"github.com/Achno/gowall/config"
 	imageio "github.com/Achno/gowall/internal/image_io"
 	types "github.com/Achno/gowall/internal/types"
 	"github.com/Achno/gowall/internal/upscaler"
@@ -17,10 +17,28 @@ import (
 type UpscaleProcessor struct {
 	Scale     int
 	ModelName string
+	BinaryDir string // folder of the realesrgan-ncnn-vulkan binary, it is set up there on first use
+}
+
+// NewUpscaleProcessor returns an UpscaleProcessor that keeps the upscaler binary in binaryDir
+func NewUpscaleProcessor(scale int, modelName string, binaryDir string) *UpscaleProcessor {
+	return &UpscaleProcessor{
+		Scale:     scale,
+		ModelName: modelName,
+		BinaryDir: binaryDir,
+	}
 }
 
 func (p *UpscaleProcessor) Process(img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
-	destFolder := filepath.Join(config.GowallConfig.OutputFolder, "upscaler")
+	return p.ProcessContext(context.Background(), img, theme, format)
+}
+
+// ProcessContext upscales the image like Process, cancelling ctx kills the upscaler process.
+func (p *UpscaleProcessor) ProcessContext(ctx context.Context, img image.Image, theme string, format string) (image.Image, types.ImageMetadata, error) {
+	if p.BinaryDir == "" {
+		return nil, types.ImageMetadata{}, fmt.Errorf("no folder was given for the upscaler binary")
+	}
+	destFolder := p.BinaryDir
 	// setup upscaler if it has not been already
 	if _, err := os.Stat(destFolder); os.IsNotExist(err) {
 
@@ -28,7 +46,9 @@ func (p *UpscaleProcessor) Process(img image.Image, theme string, format string)
 		if !ok {
 			return nil, types.ImageMetadata{}, fmt.Errorf("the upscaler has not been setup")
 		}
-		upscaler.SetupUpscaler()
+		if err := upscaler.SetupUpscaler(destFolder); err != nil {
+			return nil, types.ImageMetadata{}, err
+		}
 	}
 
 	binaryNames := map[string]string{
@@ -54,17 +74,21 @@ func (p *UpscaleProcessor) Process(img image.Image, theme string, format string)
 		return nil, types.ImageMetadata{}, fmt.Errorf("failed to save temp input image: %w", err)
 	}
 
-	cmd := exec.Command(binary, "-i", inputPath, "-o", outputPath, "-s", fmt.Sprintf("%d", p.Scale))
+	cmd := exec.CommandContext(ctx, binary, "-i", inputPath, "-o", outputPath, "-s", fmt.Sprintf("%d", p.Scale))
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, types.ImageMetadata{}, fmt.Errorf("upscaling interrupted: %w", ctx.Err())
+	}
 	if err != nil {
 		exitError, ok := err.(*exec.ExitError)
 		if ok && exitError.ExitCode() == 255 {
 			return nil, types.ImageMetadata{}, nil
 		}
-		return nil, types.ImageMetadata{}, fmt.Errorf("command failed: %w", err)
+		// the upscaler crashing (e.g. out of VRAM) is worth another try
+		return nil, types.ImageMetadata{}, utils.Transient(fmt.Errorf("command failed: %w", err))
 	}
 	imgUpscaled, err := imageio.LoadImage(imageio.FileReader{Path: outputPath})
 	if err != nil {
@@ -0,0 +1,334 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
+	"github.com/Achno/gowall/internal/backends/dither"
+	"github.com/Achno/gowall/internal/logger"
+	types "github.com/Achno/gowall/internal/types"
+	webp "github.com/chai2010/webp"
+)
+
+const (
+	vp8xAnimation = 0x02
+	anmfDispose   = 0x01 // the frame area is cleared to transparent before the next frame
+	anmfNoBlend   = 0x02 // the frame replaces the canvas instead of being drawn over it
+)
+
+var errInvalidAnimation = errors.New("invalid animated webp")
+
+// Animation is an animated input (GIF or WebP) decoded to complete frames, with the timing needed to encode it again
+type Animation struct {
+	Frames    []image.Image
+	Delays    []int  // delay after every frame in 100ths of a second
+	Disposal  []byte // gif disposal method of every frame, missing entries are gif.DisposalNone
+	LoopCount int    // 0 loops forever, -1 plays once, anything else LoopCount+1 times like gif.GIF
+
+	// Dither is how EncodeGIF quantises the frames to its palette, an empty Mode uses draw.FloydSteinberg
+	Dither dither.Options
+}
+
+// Animated reports whether the animation has more than one frame
+func (a *Animation) Animated() bool {
+	return len(a.Frames) > 1
+}
+
+func singleFrame(img image.Image) *Animation {
+	return &Animation{Frames: []image.Image{img}}
+}
+
+// decodeAnimation decodes an animated GIF or WebP, other images and animations with a single frame return nil
+func decodeAnimation(data []byte) (*Animation, error) {
+	var anim *Animation
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		anim, err = decodeGIFAnimation(data)
+	case isAnimatedWebP(data):
+		anim, err = decodeWebPAnimation(data)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !anim.Animated() {
+		return nil, nil
+	}
+	return anim, nil
+}
+
+func decodeGIFAnimation(data []byte) (*Animation, error) {
+	frames, g, err := DecodeGIFFrames(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Animation{Frames: frames, Delays: g.Delay, Disposal: g.Disposal, LoopCount: g.LoopCount}, nil
+}
+
+func isAnimatedWebP(data []byte) bool {
+	return len(data) >= 21 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP" &&
+		string(data[12:16]) == "VP8X" && data[20]&vp8xAnimation != 0
+}
+
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// decodeWebPAnimation decodes the ANMF frames of an animated webp and draws them on the canvas
+// according to their blending and disposal methods to get complete images.
+func decodeWebPAnimation(data []byte) (*Animation, error) {
+	chunks := riffChunks(data)
+	if len(chunks) == 0 || len(chunks[0].data) < 10 {
+		return nil, errInvalidAnimation
+	}
+	vp8x := chunks[0].data
+	canvas := image.NewNRGBA(image.Rect(0, 0, uint24(vp8x[4:])+1, uint24(vp8x[7:])+1))
+
+	anim := &Animation{}
+	for _, chunk := range chunks[1:] {
+		switch chunk.fourCC {
+		case "ANIM":
+			if len(chunk.data) < 6 {
+				return nil, errInvalidAnimation
+			}
+			// webp counts how many times the animation is played, gif how many times it is restarted
+			switch loops := int(binary.LittleEndian.Uint16(chunk.data[4:6])); loops {
+			case 0:
+				anim.LoopCount = 0
+			case 1:
+				anim.LoopCount = -1
+			default:
+				anim.LoopCount = loops - 1
+			}
+
+		case "ANMF":
+			if len(chunk.data) < 16 {
+				return nil, errInvalidAnimation
+			}
+			x, y := 2*uint24(chunk.data[0:]), 2*uint24(chunk.data[3:])
+			width, height := uint24(chunk.data[6:])+1, uint24(chunk.data[9:])+1
+			duration := uint24(chunk.data[12:])
+			flags := chunk.data[15]
+
+			frame, err := decodeWebPFrame(chunk.data[16:], width, height)
+			if err != nil {
+				return nil, fmt.Errorf("frame %d: %w", len(anim.Frames)+1, err)
+			}
+
+			r := image.Rect(x, y, x+width, y+height)
+			op := draw.Over
+			if flags&anmfNoBlend != 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, r, frame, frame.Bounds().Min, op)
+
+			complete := image.NewNRGBA(canvas.Bounds())
+			copy(complete.Pix, canvas.Pix)
+			anim.Frames = append(anim.Frames, complete)
+			anim.Delays = append(anim.Delays, (duration+5)/10)
+
+			disposal := byte(gif.DisposalNone)
+			if flags&anmfDispose != 0 {
+				disposal = gif.DisposalBackground
+				draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+			}
+			anim.Disposal = append(anim.Disposal, disposal)
+		}
+	}
+	return anim, nil
+}
+
+// decodeWebPFrame decodes the bitstream of an ANMF chunk by wrapping it in a webp file of its own
+func decodeWebPFrame(payload []byte, width, height int) (image.Image, error) {
+	var alpha, bitstream *riffChunk
+	for _, chunk := range parseChunks(payload, 0) {
+		switch chunk.fourCC {
+		case "ALPH":
+			alpha = &chunk
+		case "VP8 ", "VP8L":
+			bitstream = &chunk
+		}
+	}
+	if bitstream == nil {
+		return nil, errInvalidAnimation
+	}
+
+	chunks := []riffChunk{*bitstream}
+	// lossy frames store their transparency in a separate chunk, which needs the extended format
+	if alpha != nil && bitstream.fourCC == "VP8 " {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		vp8x[4], vp8x[5], vp8x[6] = byte(width-1), byte((width-1)>>8), byte((width-1)>>16)
+		vp8x[7], vp8x[8], vp8x[9] = byte(height-1), byte((height-1)>>8), byte((height-1)>>16)
+		chunks = []riffChunk{{fourCC: "VP8X", data: vp8x}, *alpha, *bitstream}
+	}
+	return webp.Decode(bytes.NewReader(encodeRIFF(chunks)))
+}
+
+// loadAnimation reads the input once and decodes it as an animation, other inputs are returned as a single frame
+func loadAnimation(imgSrc ImageReader, svgOpts svg.Options) (*Animation, *types.SourceMetadata, error) {
+	switch imgSrc.(type) {
+	case NoInput, FrameReader:
+		img, source, err := loadImage(imgSrc, svgOpts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return singleFrame(img), source, nil
+	}
+
+	data, err := LoadFileBytes(imgSrc)
+	if err != nil {
+		return nil, nil, err
+	}
+	anim, err := decodeAnimation(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", imgSrc.String(), err)
+	}
+	if anim == nil {
+		img, source, err := decodeImage(imgSrc, data, svgOpts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return singleFrame(img), source, nil
+	}
+	return anim, readMetadata(data), nil
+}
+
+// LoadAnimation decodes the input like Load. With --frames animate every frame of animated GIF and WebP inputs
+// is kept when the output is a gif, other formats get the first frame since they can't hold an animation.
+func (op ImageIO) LoadAnimation() (*Animation, *types.SourceMetadata, error) {
+	if op.Frames != FramesAnimate {
+		img, source, err := op.Load()
+		if err != nil {
+			return nil, nil, err
+		}
+		return singleFrame(img), source, nil
+	}
+
+	anim, source, err := loadAnimation(op.ImageInput, op.SVG)
+	if err != nil {
+		return nil, nil, err
+	}
+	if anim.Animated() && !strings.EqualFold(op.Format, "gif") {
+		logger.Warnf("::: %s is animated, only its first frame is saved as %s, use --format gif to keep the animation :::", op.ImageInput, op.Format)
+		anim = singleFrame(anim.Frames[0])
+	}
+
+	for i, frame := range anim.Frames {
+		anim.Frames[i], err = op.ColorManagement.toWorkingSpace(frame, source)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return anim, source, nil
+}
+
+// SaveAnimation encodes the processed frames to the output of the operation as an animated GIF, see EncodeGIF.
+// GIF has no color profile, with --output-profile convert the frames are converted back to the profile of the input.
+func (op ImageIO) SaveAnimation(anim *Animation, source *types.SourceMetadata) error {
+	output := *anim
+	output.Frames = make([]image.Image, len(anim.Frames))
+	for i, frame := range anim.Frames {
+		img, _, err := op.ColorManagement.toOutput(frame, source)
+		if err != nil {
+			return err
+		}
+		output.Frames[i] = img
+	}
+
+	return writeOutput(op.ImageOutput, func(w io.Writer) error {
+		return EncodeGIF(w, &output)
+	})
+}
+
+// EncodeGIF writes the animation as an animated GIF, every frame is reduced to the web safe palette with the dithering of anim.Dither.
+// Frames are complete images, so if any of them has transparent pixels every frame is disposed to the background
+// instead of leaving the previous frame visible through them.
+func EncodeGIF(w io.Writer, anim *Animation) error {
+	if len(anim.Frames) == 0 {
+		return errors.New("the animation has no frames")
+	}
+
+	var bounds image.Rectangle
+	transparent := false
+	for _, frame := range anim.Frames {
+		bounds = bounds.Union(frame.Bounds())
+		if !isOpaque(frame) {
+			transparent = true
+		}
+	}
+
+	g := &gif.GIF{
+		Image:     palettedFrames(anim.Frames, anim.Dither),
+		Delay:     make([]int, len(anim.Frames)),
+		Disposal:  make([]byte, len(anim.Frames)),
+		LoopCount: anim.LoopCount,
+		Config:    image.Config{Width: bounds.Max.X, Height: bounds.Max.Y},
+	}
+	for i := range anim.Frames {
+		if i < len(anim.Delays) {
+			g.Delay[i] = anim.Delays[i]
+		}
+		switch {
+		case transparent:
+			g.Disposal[i] = gif.DisposalBackground
+		case i < len(anim.Disposal):
+			g.Disposal[i] = anim.Disposal[i]
+		default:
+			g.Disposal[i] = gif.DisposalNone
+		}
+	}
+	return gif.EncodeAll(w, g)
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// palettedFrames converts the frames to paletted images concurrently, the order of the frames is kept
+func palettedFrames(frames []image.Image, dithering dither.Options) []*image.Paletted {
+	paletted := make([]*image.Paletted, len(frames))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+
+	for i, frame := range frames {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p := color.Palette(palette.WebSafe)
+			if !isOpaque(frame) {
+				p = append(slices.Clone(p), color.Transparent)
+			}
+			if dithering.Mode != "" {
+				paletted[i] = dither.Paletted(frame, p, nil, dithering)
+				return
+			}
+			bounds := frame.Bounds()
+			paletted[i] = image.NewPaletted(bounds, p)
+			draw.FloydSteinberg.Draw(paletted[i], bounds, frame, bounds.Min)
+		}()
+	}
+	wg.Wait()
+	return paletted
+}
@@ -0,0 +1,168 @@
+package imageio
+
+import (
+	"image"
+	"strings"
+	"sync"
+
+	"github.com/Achno/gowall/internal/backends/icc"
+	"github.com/Achno/gowall/internal/logger"
+	types "github.com/Achno/gowall/internal/types"
+)
+
+// How the profile of the output is handled (--output-profile)
+const (
+	OutputProfileTag     = "tag"     // the output stays in the working space and is tagged with its profile
+	OutputProfileConvert = "convert" // the output is converted back to the profile of the input and tagged with it
+	OutputProfileNone    = "none"    // the output stays in the working space without a profile
+)
+
+// ColorManagementNone disables color management, pixels are processed as they are stored
+const ColorManagementNone = "none"
+
+// ColorManagement controls the conversion of inputs with an embedded ICC profile (e.g. Display P3, Adobe RGB)
+// to a working space before processing, and the profile of the output.
+type ColorManagement struct {
+	WorkingSpace string // srgb, display-p3, adobe-rgb, prophoto or none
+	Output       string // tag, convert or none
+}
+
+func (cm ColorManagement) enabled() bool {
+	return cm.WorkingSpace != "" && cm.WorkingSpace != ColorManagementNone
+}
+
+var (
+	// building a transform samples its curves, batches usually share the same few profiles
+	transformCache sync.Map // map[string]*icc.Transform
+	profileCache   sync.Map // map[string]*icc.Profile, nil when the profile is unsupported
+	// warn only once for every unsupported profile
+	unsupportedProfiles sync.Map
+)
+
+// sourceProfile returns the profile embedded in the input, untagged inputs are sRGB
+func sourceProfile(source *types.SourceMetadata) *icc.Profile {
+	if source == nil || len(source.ICC) == 0 {
+		return icc.SRGB()
+	}
+
+	key := string(source.ICC)
+	if cached, ok := profileCache.Load(key); ok {
+		if cached.(*icc.Profile) == nil {
+			return icc.SRGB()
+		}
+		return cached.(*icc.Profile)
+	}
+
+	profile, err := icc.Parse(source.ICC)
+	if err != nil {
+		if _, warned := unsupportedProfiles.LoadOrStore(key, true); !warned {
+			logger.Warnf("::: %v, the image is treated as sRGB :::", err)
+		}
+		profile = nil
+	}
+	profileCache.Store(key, profile)
+	if profile == nil {
+		return icc.SRGB()
+	}
+	return profile
+}
+
+// convert applies the transform between two profiles, the images are returned as is if both are the same color space
+func convert(img image.Image, src *icc.Profile, dst *icc.Profile, key string) image.Image {
+	if src.Equal(dst) {
+		return img
+	}
+
+	transform, ok := transformCache.Load(key)
+	if !ok {
+		transform, _ = transformCache.LoadOrStore(key, icc.NewTransform(src, dst))
+	}
+	return transform.(*icc.Transform).Apply(img)
+}
+
+// toWorkingSpace converts the decoded input from its embedded profile to the working space
+func (cm ColorManagement) toWorkingSpace(img image.Image, source *types.SourceMetadata) (image.Image, error) {
+	if !cm.enabled() {
+		return img, nil
+	}
+	working, err := icc.WorkingSpace(cm.WorkingSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	key := "in:" + cm.WorkingSpace + ":"
+	if source != nil {
+		key += string(source.ICC)
+	}
+	return convert(img, sourceProfile(source), working, key), nil
+}
+
+// toOutput applies --output-profile, it returns the pixels to encode and the metadata with the profile to embed
+func (cm ColorManagement) toOutput(img image.Image, source *types.SourceMetadata) (image.Image, *types.SourceMetadata, error) {
+	if !cm.enabled() {
+		return img, source, nil
+	}
+	working, err := icc.WorkingSpace(cm.WorkingSpace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var metadata types.SourceMetadata
+	if source != nil {
+		metadata = *source
+	}
+	hasProfile := len(metadata.ICC) > 0
+
+	switch strings.ToLower(cm.Output) {
+	case OutputProfileNone:
+		metadata.ICC = nil
+
+	case OutputProfileConvert:
+		// back to the profile of the input, untagged inputs go back to sRGB and stay untagged
+		if img != nil {
+			img = convert(img, working, sourceProfile(source), "out:"+cm.WorkingSpace+":"+string(metadata.ICC))
+		}
+
+	default:
+		// untagged inputs processed in sRGB stay untagged
+		if hasProfile || !working.Equal(icc.SRGB()) {
+			metadata.ICC = encodedWorkingSpace(cm.WorkingSpace, working)
+		}
+	}
+	return img, &metadata, nil
+}
+
+var encodedProfiles sync.Map // map[string][]byte
+
+func encodedWorkingSpace(name string, profile *icc.Profile) []byte {
+	if data, ok := encodedProfiles.Load(name); ok {
+		return data.([]byte)
+	}
+	data, _ := encodedProfiles.LoadOrStore(name, profile.Encode())
+	return data.([]byte)
+}
+
+// Load decodes the input of the operation, rotates it upright and converts it to the working space.
+// The returned metadata is the one of the input, it is handed back to Save.
+func (op ImageIO) Load() (image.Image, *types.SourceMetadata, error) {
+	img, source, err := loadImage(op.ImageInput, op.SVG)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err = op.ColorManagement.toWorkingSpace(img, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, source, nil
+}
+
+// Save encodes the processed image to the output of the operation,
+// the profile and metadata of the input are written according to --output-profile and --keep-metadata/--strip-metadata.
+func (op ImageIO) Save(img image.Image, metadata types.ImageMetadata, source *types.SourceMetadata) error {
+	img, source, err := op.ColorManagement.toOutput(img, source)
+	if err != nil {
+		return err
+	}
+	metadata.Source = op.Metadata.Filter(source)
+	return SaveImage(img, op.ImageOutput, op.Format, metadata)
+}
@@ -0,0 +1,36 @@
+package imageio
+
+import (
+	"image"
+	"image/draw"
+)
+
+// IsHighBitDepth reports whether the image stores more than 8 bits per channel (e.g. 16-bit PNGs and TIFFs).
+// Such images are processed as image.NRGBA64 and only quantised by the encoder when the output format requires it.
+func IsHighBitDepth(img image.Image) bool {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// NewCanvas returns an empty image with the given bounds and the precision of img,
+// an *image.NRGBA64 for high bit depth images and an *image.RGBA otherwise.
+func NewCanvas(img image.Image, bounds image.Rectangle) draw.Image {
+	if IsHighBitDepth(img) {
+		return image.NewNRGBA64(bounds)
+	}
+	return image.NewRGBA(bounds)
+}
+
+// ToNRGBA64 returns the image as an *image.NRGBA64, it is copied unless it already is one
+func ToNRGBA64(img image.Image) *image.NRGBA64 {
+	if nrgba, ok := img.(*image.NRGBA64); ok {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA64(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,40 @@
+package imageio
+
+import (
+	"github.com/Achno/gowall/internal/logger"
+)
+
+// PlannedOperation is an ImageIO as printed by --dry-run
+type PlannedOperation struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Format string `json:"format"`
+}
+
+type dryRunDocument struct {
+	DryRun     bool               `json:"dry_run"`
+	Operations []PlannedOperation `json:"operations"`
+}
+
+// PrintPlan prints what the operations would read and write, nothing is loaded, processed or saved.
+// Inputs that were skipped while determining the operations have already been warned about.
+func PrintPlan(ops []ImageIO) {
+	planned := make([]PlannedOperation, 0, len(ops))
+	for _, op := range ops {
+		planned = append(planned, PlannedOperation{
+			Input:  op.ImageInput.String(),
+			Output: op.ImageOutput.String(),
+			Format: op.Format,
+		})
+	}
+
+	if logger.JSON() {
+		logger.Document(dryRunDocument{DryRun: true, Operations: planned})
+		return
+	}
+
+	logger.Printf("::: Dry run, %d operations planned, nothing is read or written :::", len(planned))
+	for _, op := range planned {
+		logger.Printf("%s -> %s (%s)", op.Input, op.Output, op.Format)
+	}
+}
@@ -0,0 +1,154 @@
+package imageio
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// How the frames of an animated input are decoded (--frames)
+const (
+	FramesAnimate = "animate" // every frame is processed and saved as an animated gif, see ImageIO.LoadAnimation
+	FramesFirst   = "first"   // only the first frame is processed
+	FramesAll     = "all"     // every frame is processed to its own output, <name>_frame001.<ext>...
+)
+
+// FrameReader reads a single frame of an animated image (GIF or WebP), the frame is composited
+// with the previous ones so it looks like it does when the animation is played.
+type FrameReader struct {
+	Source ImageReader
+	Index  int
+
+	frames *sharedFrames // the decoded animation shared by the frames of Source, nil decodes Source again
+}
+
+func (fr FrameReader) Open() (io.ReadCloser, error) {
+	return fr.Source.Open()
+}
+
+func (fr FrameReader) String() string {
+	return fmt.Sprintf("%s[frame %d]", fr.Source.String(), fr.Index+1)
+}
+
+// DecodeGIFFrames decodes every frame of a GIF. Frames only store the area that changed, so each one is drawn
+// over the canvas left by the previous frame according to its disposal method to get complete images.
+func DecodeGIFFrames(r io.Reader) ([]image.Image, *gif.GIF, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewNRGBA(bounds)
+
+	frames := make([]image.Image, 0, len(g.Image))
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		complete := image.NewNRGBA(bounds)
+		copy(complete.Pix, canvas.Pix)
+		frames = append(frames, complete)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, g, nil
+}
+
+// sharedFrames decodes the animation of a source once for all of its FrameReaders
+type sharedFrames struct {
+	source ImageReader
+	once   sync.Once
+	frames []image.Image
+	err    error
+}
+
+func newSharedFrames(source ImageReader) *sharedFrames {
+	return &sharedFrames{source: source}
+}
+
+// load decodes the animation on the first call, later calls return the same frames
+func (sf *sharedFrames) load() ([]image.Image, error) {
+	sf.once.Do(func() {
+		data, err := LoadFileBytes(sf.source)
+		if err != nil {
+			sf.err = err
+			return
+		}
+		anim, err := decodeAnimation(data)
+		switch {
+		case err != nil:
+			sf.err = err
+		case anim == nil:
+			sf.err = fmt.Errorf("the image is not animated")
+		default:
+			sf.frames = anim.Frames
+		}
+	})
+	return sf.frames, sf.err
+}
+
+// frame returns the frame of the FrameReader, decoding the animation only if no other frame of it did
+func (fr FrameReader) frame() (image.Image, error) {
+	frames := fr.frames
+	if frames == nil {
+		frames = newSharedFrames(fr.Source)
+	}
+	all, err := frames.load()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fr.String(), err)
+	}
+	if fr.Index < 0 || fr.Index >= len(all) {
+		return nil, fmt.Errorf("%s: frame %d out of range, the image has %d frames", fr.String(), fr.Index+1, len(all))
+	}
+	return all[fr.Index], nil
+}
+
+// expandFrames replaces every operation on an animated GIF or WebP by one operation per frame, their outputs get a _frameNNN suffix.
+// Each source is decoded once, its frame operations reuse the frames decoded here.
+func expandFrames(ops []ImageIO) ([]ImageIO, error) {
+	var expanded []ImageIO
+	for _, op := range ops {
+		shared := newSharedFrames(op.ImageInput)
+		frames, err := shared.load()
+		if err != nil {
+			expanded = append(expanded, op)
+			continue
+		}
+
+		output, ok := op.ImageOutput.(FileWriter)
+		if !ok {
+			return nil, fmt.Errorf("--frames %s writes one file per frame and needs a file output, not %s", FramesAll, op.ImageOutput)
+		}
+		ext := filepath.Ext(output.Path)
+		for i := range frames {
+			frameOp := op
+			frameOp.ImageInput = FrameReader{Source: op.ImageInput, Index: i, frames: shared}
+			frameOp.ImageOutput = FileWriter{Path: fmt.Sprintf("%s_frame%03d%s", strings.TrimSuffix(output.Path, ext), i+1, ext)}
+			expanded = append(expanded, frameOp)
+		}
+	}
+	return expanded, nil
+}
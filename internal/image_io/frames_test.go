@@ -0,0 +1,65 @@
+package imageio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"testing"
+)
+
+// countingReader is an in-memory input that counts how often it is opened
+type countingReader struct {
+	data  []byte
+	opens int
+}
+
+func (cr *countingReader) Open() (io.ReadCloser, error) {
+	cr.opens++
+	return io.NopCloser(bytes.NewReader(cr.data)), nil
+}
+
+func (cr *countingReader) String() string {
+	return "in.gif"
+}
+
+func TestExpandFramesDecodesOnce(t *testing.T) {
+	palette := color.Palette{color.Black, color.White, color.RGBA{R: 0xff, A: 0xff}}
+	g := &gif.GIF{}
+	for i := range 3 {
+		frame := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+		frame.SetColorIndex(i, i, uint8(i%len(palette)))
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	input := &countingReader{data: buf.Bytes()}
+	ops, err := expandFrames([]ImageIO{{ImageInput: input, ImageOutput: FileWriter{Path: "out/in.png"}, Format: "png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 {
+		t.Fatalf("got %d operations, want 3", len(ops))
+	}
+	if want := "out/in_frame002.png"; ops[1].ImageOutput.(FileWriter).Path != want {
+		t.Errorf("output %q, want %q", ops[1].ImageOutput.(FileWriter).Path, want)
+	}
+
+	for i, op := range ops {
+		img, err := LoadImage(op.ImageInput)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i+1, err)
+		}
+		if got := color.RGBAModel.Convert(img.At(i, i)); got != color.RGBAModel.Convert(palette[i]) {
+			t.Errorf("frame %d: pixel %v, want %v", i+1, got, palette[i])
+		}
+	}
+	if input.opens != 1 {
+		t.Errorf("input opened %d times, want 1", input.opens)
+	}
+}
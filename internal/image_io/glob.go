@@ -0,0 +1,63 @@
+package imageio
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the slash separated relPath matches pattern, like a .gitignore line.
+// "**" matches any number of directories (e.g. "raw/**", "**/dark/*.png"), a pattern without a "/"
+// is matched against the file name only (e.g. "*.png"). Other patterns match at any depth
+// ("raw/**" also skips "sub/raw/c.png") unless they start with "/", which anchors them at the root.
+func matchGlob(pattern string, relPath string) (bool, error) {
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	if !strings.Contains(pattern, "/") && !anchored {
+		return path.Match(pattern, path.Base(relPath))
+	}
+	segments := strings.Split(pattern, "/")
+	if !anchored && segments[0] != "**" {
+		segments = append([]string{"**"}, segments...)
+	}
+	return matchSegments(segments, strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern []string, segments []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**" consumes zero or more segments
+			for i := 0; i <= len(segments); i++ {
+				ok, err := matchSegments(pattern[1:], segments[i:])
+				if ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(segments) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], segments[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0, nil
+}
+
+// matchAnyGlob reports whether relPath matches one of the patterns
+func matchAnyGlob(patterns []string, relPath string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := matchGlob(pattern, relPath)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,53 @@
+package imageio
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		relPath string
+		want    bool
+	}{
+		// without a "/" only the name is matched, at any depth
+		{"*.png", "a.png", true},
+		{"*.png", "sub/deep/a.png", true},
+		{"*.png", "a.jpg", false},
+		{"raw", "sub/raw", true},
+
+		// patterns with a "/" match at any depth like .gitignore
+		{"raw/**", "raw/c.png", true},
+		{"raw/**", "raw", true},
+		{"raw/**", "in/sub/raw/c.png", true},
+		{"raw/**", "rawfiles/c.png", false},
+		{"dark/*.png", "dark/a.png", true},
+		{"dark/*.png", "walls/dark/a.png", true},
+		{"dark/*.png", "dark/sub/a.png", false},
+		{"**/dark/*.png", "a/b/dark/c.png", true},
+		{"a/**/b.png", "a/b.png", true},
+		{"a/**/b.png", "a/x/y/b.png", true},
+		{"a/**/b.png", "x/a/y/b.png", true},
+
+		// a leading "/" anchors the pattern at the root of --dir
+		{"/raw/**", "raw/c.png", true},
+		{"/raw/**", "sub/raw/c.png", false},
+		{"/*.png", "a.png", true},
+		{"/*.png", "sub/a.png", false},
+	}
+
+	for _, tt := range tests {
+		got, err := matchGlob(tt.pattern, tt.relPath)
+		if err != nil {
+			t.Errorf("matchGlob(%q, %q): %v", tt.pattern, tt.relPath, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.relPath, got, tt.want)
+		}
+	}
+}
+
+func TestMatchGlobInvalidPattern(t *testing.T) {
+	if _, err := matchAnyGlob([]string{"[a-"}, "a.png"); err == nil {
+		t.Error("matchAnyGlob accepted the invalid pattern [a-")
+	}
+}
@@ -1,7 +1,9 @@
 package imageio
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -9,6 +11,8 @@ import (
 	"time"
 
 	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
+	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/utils"
 	"github.com/spf13/cobra"
 )
@@ -17,17 +21,31 @@ type ImageIO struct {
 	ImageInput  ImageReader
 	ImageOutput ImageWriter
 	Format      string
+	Manifest    *Manifest    // set by --incremental, the output is recorded in it once saved
+	Metadata    MetadataMode // which metadata of the input is written to the output
+	Frames      string       // --frames, animated inputs are processed frame by frame with FramesAnimate
+
+	ColorManagement ColorManagement // conversion of the input profile to the working space and profile of the output
+	SVG             svg.Options     // size SVG inputs are rasterised at (--width, --height, --dpi)
+}
+
+// WithContext returns a copy of op whose url input stops downloading when ctx is cancelled
+func (op ImageIO) WithContext(ctx context.Context) ImageIO {
+	if urlInput, ok := op.ImageInput.(URLReader); ok {
+		op.ImageInput = urlInput.WithContext(ctx)
+	}
+	return op
 }
 
 // Input image abstraction
 type ImageReader interface {
-	Open() (*os.File, error)
+	Open() (io.ReadCloser, error)
 	String() string
 }
 
 // Ouput image abstraction
 type ImageWriter interface {
-	Create() (*os.File, error)
+	Create() (io.WriteCloser, error)
 	String() string
 }
 
@@ -45,7 +63,7 @@ type (
 	NoInput struct{} // For commands that generate images without input
 )
 
-func (fr FileReader) Open() (*os.File, error) {
+func (fr FileReader) Open() (io.ReadCloser, error) {
 	f, err := os.Open(fr.Path)
 	if err != nil {
 		return nil, err
@@ -58,7 +76,9 @@ func (fr FileReader) String() string {
 	return filePath
 }
 
-func (fw FileWriter) Create() (*os.File, error) {
+// Create returns a temporary file next to fw.Path that is renamed over fw.Path on Close,
+// so an interrupted or failed write never leaves a half written output behind.
+func (fw FileWriter) Create() (io.WriteCloser, error) {
 	dir := filepath.Dir(fw.Path)
 
 	// Create all necessary parent directories
@@ -66,11 +86,16 @@ func (fw FileWriter) Create() (*os.File, error) {
 		return nil, fmt.Errorf("failed to create directories: %w", err)
 	}
 
-	f, err := os.Create(fw.Path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(fw.Path)+".*.tmp")
 	if err != nil {
 		return nil, err
 	}
-	return f, nil
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &atomicFile{File: f, path: fw.Path}, nil
 }
 
 func (fw FileWriter) String() string {
@@ -78,7 +103,31 @@ func (fw FileWriter) String() string {
 	return filePath
 }
 
-func (ss Stdin) Open() (*os.File, error) {
+// atomicFile is a temporary file that replaces the destination path once it is closed
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func (af *atomicFile) Close() error {
+	if err := af.File.Close(); err != nil {
+		os.Remove(af.Name())
+		return err
+	}
+	if err := os.Rename(af.Name(), af.path); err != nil {
+		os.Remove(af.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temporary file and leaves the destination untouched
+func (af *atomicFile) Abort() error {
+	af.File.Close()
+	return os.Remove(af.Name())
+}
+
+func (ss Stdin) Open() (io.ReadCloser, error) {
 	return os.Stdin, nil
 }
 
@@ -86,7 +135,7 @@ func (ss Stdin) String() string {
 	return "/dev/stdin"
 }
 
-func (so Stdout) Create() (*os.File, error) {
+func (so Stdout) Create() (io.WriteCloser, error) {
 	return os.Stdout, nil
 }
 
@@ -94,7 +143,7 @@ func (so Stdout) String() string {
 	return "/dev/stdout"
 }
 
-func (ni NoInput) Open() (*os.File, error) {
+func (ni NoInput) Open() (io.ReadCloser, error) {
 	// Return nil - no actual file to open for generated images
 	return nil, nil
 }
@@ -113,7 +162,49 @@ func IsMultiInputSingleOutputCommand(cmdName string) bool {
 }
 
 // DetermineImageOperations generates ImageIO structs based on program flags and command io arguments.
-func DetermineImageOperations(flags config.GlobalSubCommandFlags, args []string, cmd *cobra.Command) ([]ImageIO, error) {
+// With --incremental the operations whose output is already up to date are dropped, processorConfig describes
+// the configuration the processor resolves from config.yml so changing it also invalidates the outputs.
+func DetermineImageOperations(flags config.GlobalSubCommandFlags, args []string, cmd *cobra.Command, processorConfig string) ([]ImageIO, error) {
+	ops, err := determineImageOperations(flags, args, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// --dry-run reads no input, so animated inputs are not split into frames and up to date outputs are not skipped
+	if flags.Frames == FramesAll && !flags.DryRun && !IsMultiInputSingleOutputCommand(cmd.Name()) {
+		ops, err = expandFrames(ops)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mode := metadataMode(flags)
+	colorManagement := ColorManagement{WorkingSpace: flags.ColorSpace, Output: flags.OutputProfile}
+	svgOpts := svg.Options{Width: flags.RasterWidth, Height: flags.RasterHeight, DPI: flags.RasterDPI}
+	for i := range ops {
+		ops[i].Metadata = mode
+		ops[i].ColorManagement = colorManagement
+		ops[i].Frames = flags.Frames
+		ops[i].SVG = svgOpts
+	}
+
+	ops, err = rasterSVGOutputs(ops, flags, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// --incremental expects the outputs of the previous run to exist, only outputs written twice by this run collide
+	if !IsMultiInputSingleOutputCommand(cmd.Name()) {
+		ops = resolveCollisions(ops, flags.OnCollision, !flags.Incremental)
+	}
+
+	if flags.Incremental && !flags.DryRun && !IsMultiInputSingleOutputCommand(cmd.Name()) {
+		return filterUpToDate(ops, cmd, processorConfig)
+	}
+	return ops, nil
+}
+
+func determineImageOperations(flags config.GlobalSubCommandFlags, args []string, cmd *cobra.Command) ([]ImageIO, error) {
 	// Check if this is a multi-input-single-output command
 	isMultiInputSingleOutput := IsMultiInputSingleOutputCommand(cmd.Name())
 
@@ -124,6 +215,10 @@ func DetermineImageOperations(flags config.GlobalSubCommandFlags, args []string,
 		return zeroInputIO(flags, args, cmd)
 	}
 
+	if isMultiInputSingleOutput && len(args) > 0 && args[0] == "-" {
+		return stdinIOMulti(flags, cmd)
+	}
+
 	// Process by priority: directory > batch files > single file/stdin
 	if flags.InputDir != "" {
 		if isMultiInputSingleOutput {
@@ -150,6 +245,18 @@ func DetermineImageOperations(flags config.GlobalSubCommandFlags, args []string,
 	return imgIO, nil
 }
 
+// metadataMode resolves --keep-metadata and --strip-metadata
+func metadataMode(flags config.GlobalSubCommandFlags) MetadataMode {
+	switch {
+	case flags.StripMetadata:
+		return MetadataStrip
+	case flags.KeepMetadata:
+		return MetadataKeep
+	default:
+		return MetadataColorProfile
+	}
+}
+
 // SingleIO handles both file and STDIN input cases
 func SingleIO(flags config.GlobalSubCommandFlags, args []string, cmd *cobra.Command) ([]ImageIO, error) {
 	input := determineInput(args)
@@ -193,7 +300,7 @@ func directoryIO(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([]Imag
 		return config.SupportedImageExtensions[ext]
 	}
 
-	inputFiles, err := GetFilesFromDirectory(flags.InputDir, filter)
+	inputFiles, err := GetFilesFromDirectory(flags.InputDir, newWalkOptions(flags), filter)
 	if err != nil {
 		return nil, err
 	}
@@ -210,12 +317,24 @@ func directoryIO(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([]Imag
 	}
 
 	for _, inputFile := range inputFiles {
-		baseName := filepath.Base(inputFile.Path)
 		ext, err := determineFileExt(flags, inputFile, nil, cmd)
 		if err != nil {
+			warnSkipped(inputFile.String(), err)
 			continue
 		}
-		outputPath := filepath.Join(dir, replaceExt(baseName, ext))
+		// mirror the relative directory structure of --dir, (only top level files without --recursive)
+		relPath, err := filepath.Rel(flags.InputDir, inputFile.Path)
+		if err != nil {
+			relPath = filepath.Base(inputFile.Path)
+		}
+		outputPath := filepath.Join(dir, replaceExt(relPath, ext))
+		if flags.NameTemplate != "" {
+			outputPath, ext, err = templateOutput(flags, cmd, inputFile, dir, relPath, ext)
+			if err != nil {
+				warnSkipped(inputFile.String(), err)
+				continue
+			}
+		}
 		operations = append(operations, ImageIO{
 			ImageInput:  inputFile,
 			ImageOutput: FileWriter{Path: outputPath},
@@ -233,7 +352,7 @@ func directoryIOMulti(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([
 		return config.SupportedImageExtensions[ext]
 	}
 
-	inputFiles, err := GetFilesFromDirectory(flags.InputDir, filter)
+	inputFiles, err := GetFilesFromDirectory(flags.InputDir, newWalkOptions(flags), filter)
 	if err != nil {
 		return nil, err
 	}
@@ -256,6 +375,31 @@ func directoryIOMulti(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([
 	return operations, nil
 }
 
+// stdinIOMulti handles multi-input-single-output commands reading a stream of images or paths from stdin (e.g., gowall gif -)
+func stdinIOMulti(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([]ImageIO, error) {
+	inputs, err := ReadStreamInputs(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate single output for all inputs
+	output, ext, err := generateSingleOutput(flags, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []ImageIO
+	for _, input := range inputs {
+		operations = append(operations, ImageIO{
+			ImageInput:  input,
+			ImageOutput: output,
+			Format:      ext,
+		})
+	}
+
+	return operations, nil
+}
+
 // batchIOMulti handles batch files for multi-input-single-output commands (e.g., gif)
 func batchIOMulti(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([]ImageIO, error) {
 	// Generate single output for all inputs
@@ -269,12 +413,12 @@ func batchIOMulti(flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([]Ima
 
 	var operations []ImageIO
 	for _, path := range files {
-		absolutePath, err := filepath.Abs(path)
+		input, _, err := batchInput(path)
 		if err != nil {
+			warnSkipped(path, err)
 			continue
 		}
 
-		input := FileReader{Path: absolutePath}
 		operations = append(operations, ImageIO{
 			ImageInput:  input,
 			ImageOutput: output,
@@ -356,19 +500,26 @@ func batchIO(flags config.GlobalSubCommandFlags, cmd *cobra.Command) []ImageIO {
 	files := config.ExpandTilde(flags.InputFiles)
 
 	for _, path := range files {
-		absolutePath, err := filepath.Abs(path)
+		input, baseName, err := batchInput(path)
 		if err != nil {
+			warnSkipped(path, err)
 			continue
 		}
 
-		input := FileReader{Path: absolutePath}
-		baseName := filepath.Base(absolutePath)
 		ext, err := determineFileExt(flags, input, nil, cmd)
 		if err != nil {
+			warnSkipped(input.String(), err)
 			continue
 		}
 
 		outputPath := filepath.Join(dir, replaceExt(baseName, ext))
+		if flags.NameTemplate != "" {
+			outputPath, ext, err = templateOutput(flags, cmd, input, dir, baseName, ext)
+			if err != nil {
+				warnSkipped(input.String(), err)
+				continue
+			}
+		}
 		operations = append(operations, ImageIO{
 			ImageInput:  input,
 			ImageOutput: FileWriter{Path: outputPath},
@@ -379,13 +530,44 @@ func batchIO(flags config.GlobalSubCommandFlags, cmd *cobra.Command) []ImageIO {
 	return operations
 }
 
-// determineInput resolves the input source (file or stdin)
+// warnSkipped reports an input that is left out of the operations
+func warnSkipped(input string, err error) {
+	logger.Warnf("::: Skipping %s: %v :::", input, err)
+}
+
+// batchInput resolves a --batch entry to a file or an http(s) url and returns the name its output is based on
+func batchInput(path string) (ImageReader, string, error) {
+	if IsURL(path) {
+		input := newURLReader(path)
+		return input, urlBaseName(input), nil
+	}
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return FileReader{Path: absolutePath}, filepath.Base(absolutePath), nil
+}
+
+// urlBaseName is the name of the output of a url input, urls without a file name get a timestamp
+func urlBaseName(input URLReader) string {
+	if name := input.Name(); name != "" {
+		return name
+	}
+	return "img-" + time.Now().Format("20060102-150405")
+}
+
+// determineInput resolves the input source (file, http(s) url or stdin)
 func determineInput(args []string) ImageReader {
 	// If the first arg is "-", use stdin
 	if len(args) > 0 && args[0] == "-" {
 		return Stdin{}
 	}
 
+	if len(args) > 0 && IsURL(args[0]) {
+		return newURLReader(args[0])
+	}
+
 	// Otherwise file
 	f := config.ExpandTilde(args)
 	return FileReader{Path: f[0]}
@@ -467,6 +649,15 @@ func generateFileName(flags config.GlobalSubCommandFlags, args []string, input I
 		return filepath.Join(filename + "." + ext), nil
 	}
 
+	// For url input, base output on the file name of the url
+	if urlInput, ok := input.(URLReader); ok {
+		ext, err := determineFileExt(flags, input, nil, cmd)
+		if err != nil {
+			return "", err
+		}
+		return replaceExt(urlBaseName(urlInput), ext), nil
+	}
+
 	// For file input, base output on input filename
 	inputPath := config.ExpandTilde([]string{args[0]})[0]
 	absInput, err := filepath.Abs(inputPath)
@@ -521,6 +712,14 @@ func determineFileExt(flags config.GlobalSubCommandFlags, input ImageReader, out
 		}
 	}
 
+	// Ext from the file name of a url, urls without an image extension are encoded as png
+	if urlInput, ok := input.(URLReader); ok {
+		if ext := strings.ToLower(filepath.Ext(urlInput.Name())); config.SupportedImageExtensions[ext] {
+			return strings.ReplaceAll(ext, ".", ""), nil
+		}
+		return "png", nil
+	}
+
 	//? If there is a file in stdin assume its a png, so it gets encoded later
 	if _, ok := input.(Stdin); ok {
 		return "png", nil
@@ -531,7 +730,7 @@ func determineFileExt(flags config.GlobalSubCommandFlags, input ImageReader, out
 		return "png", nil
 	}
 
-	return "", fmt.Errorf("extension not found")
+	return "", fmt.Errorf("extension not found, use --format to choose the output format")
 }
 
 // replaceExt replaces the file extension of inputName with ext
@@ -540,26 +739,88 @@ func replaceExt(inputName string, ext string) string {
 	return strings.TrimSuffix(inputName, oldExt) + "." + strings.TrimPrefix(ext, ".")
 }
 
-func GetFilesFromDirectory(path string, filter func(string, fs.DirEntry) bool) ([]FileReader, error) {
+// WalkOptions controls which files of a directory GetFilesFromDirectory returns
+type WalkOptions struct {
+	Recursive bool     // descend into subdirectories
+	Include   []string // glob patterns relative to the directory, a file must match one of them if any are given
+	Exclude   []string // glob patterns relative to the directory, matching files and directories are skipped
+}
+
+func newWalkOptions(flags config.GlobalSubCommandFlags) WalkOptions {
+	return WalkOptions{
+		Recursive: flags.Recursive,
+		Include:   flags.Include,
+		Exclude:   flags.Exclude,
+	}
+}
+
+// GetFilesFromDirectory returns the files of the directory that match opts and the filter, in lexical order.
+// Without opts.Recursive subdirectories are not walked, a warning lists how many were skipped.
+func GetFilesFromDirectory(root string, opts WalkOptions, filter func(string, fs.DirEntry) bool) ([]FileReader, error) {
 	var files []FileReader
-	err := filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+	skippedDirs := 0
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		excluded, err := matchAnyGlob(opts.Exclude, relPath)
 		if err != nil {
 			return err
 		}
+
 		if entry.IsDir() {
+			if excluded {
+				return filepath.SkipDir
+			}
+			if !opts.Recursive {
+				skippedDirs++
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		if filter(path, entry) {
-			files = append(files, FileReader{Path: path})
+		if excluded {
+			return nil
 		}
+
+		if len(opts.Include) > 0 {
+			included, err := matchAnyGlob(opts.Include, relPath)
+			if err != nil {
+				return err
+			}
+			if !included {
+				return nil
+			}
+		}
+
+		if !filter(path, entry) {
+			return nil
+		}
+		files = append(files, FileReader{Path: path})
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error walking directory: %w", err)
 	}
 
+	if skippedDirs > 0 {
+		logger.Warnf("::: Skipped %d subdirectories of %s, use --recursive to process their images :::", skippedDirs, root)
+	}
+
 	if len(files) == 0 {
-		return nil, fmt.Errorf("no files found in directory or subdirectories")
+		if opts.Recursive {
+			return nil, fmt.Errorf("no files found in directory or subdirectories")
+		}
+		return nil, fmt.Errorf("no files found in directory, use --recursive to include subdirectories")
 	}
 
 	return files, nil
@@ -0,0 +1,80 @@
+package imageio
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAtomicFileRenameFails(t *testing.T) {
+	dir := t.TempDir()
+	// a non-empty directory at the destination makes the rename fail
+	dest := filepath.Join(dir, "out.png")
+	if err := os.MkdirAll(filepath.Join(dest, "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := FileWriter{Path: dest}.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Fatal("Close() succeeded, want the rename error")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "out.png" {
+			t.Errorf("temporary file %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestGetFilesFromDirectory(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.png", "b.jpg", "sub/c.png", "sub/deep/d.png", "raw/e.png"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all := func(string, fs.DirEntry) bool { return true }
+
+	tests := []struct {
+		name string
+		opts WalkOptions
+		want []string
+	}{
+		{"top level only", WalkOptions{}, []string{"a.png", "b.jpg"}},
+		{"recursive", WalkOptions{Recursive: true}, []string{"a.png", "b.jpg", "raw/e.png", "sub/c.png", "sub/deep/d.png"}},
+		{"exclude directory", WalkOptions{Recursive: true, Exclude: []string{"raw"}}, []string{"a.png", "b.jpg", "sub/c.png", "sub/deep/d.png"}},
+		{"include", WalkOptions{Recursive: true, Include: []string{"*.png"}, Exclude: []string{"deep/**"}}, []string{"a.png", "raw/e.png", "sub/c.png"}},
+	}
+
+	for _, tt := range tests {
+		files, err := GetFilesFromDirectory(root, tt.opts, all)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, file := range files {
+			relPath, _ := filepath.Rel(root, file.Path)
+			got = append(got, filepath.ToSlash(relPath))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
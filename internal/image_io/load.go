@@ -4,58 +4,162 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
-	"os"
+	"slices"
+	"strings"
 
+	"github.com/Achno/gowall/internal/backends/codecs/pnm"
+	"github.com/Achno/gowall/internal/backends/codecs/qoi"
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
+	types "github.com/Achno/gowall/internal/types"
 	webp "github.com/chai2010/webp"
 	avif "github.com/gen2brain/avif"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
 // Available formats to Encode an image in
-var encoders = map[string]func(file *os.File, img image.Image) error{
-	"png": func(file *os.File, img image.Image) error {
+var encoders = map[string]func(w io.Writer, img image.Image) error{
+	"png": func(w io.Writer, img image.Image) error {
 		png := &png.Encoder{
 			CompressionLevel: png.BestSpeed,
 		}
-		return png.Encode(file, img)
+		return png.Encode(w, img)
 	},
-	"jpg": func(file *os.File, img image.Image) error {
-		return jpeg.Encode(file, img, nil)
+	"jpg": func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, nil)
 	},
-	"jpeg": func(file *os.File, img image.Image) error {
-		return jpeg.Encode(file, img, nil)
+	"jpeg": func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, nil)
 	},
-	"webp": func(file *os.File, img image.Image) error {
-		return webp.Encode(file, img, nil)
+	"webp": func(w io.Writer, img image.Image) error {
+		return webp.Encode(w, img, nil)
 	},
-	"avif": func(file *os.File, img image.Image) error {
-		return avif.Encode(file, img)
+	"avif": func(w io.Writer, img image.Image) error {
+		return avif.Encode(w, img)
 	},
+	"tiff": encodeTiff,
+	"tif":  encodeTiff,
+	"bmp": func(w io.Writer, img image.Image) error {
+		return bmp.Encode(w, img)
+	},
+	"qoi": func(w io.Writer, img image.Image) error {
+		return qoi.Encode(w, img)
+	},
+	"ppm": func(w io.Writer, img image.Image) error {
+		return pnm.Encode(w, img, pnm.FormatPPM)
+	},
+	"pgm": func(w io.Writer, img image.Image) error {
+		return pnm.Encode(w, img, pnm.FormatPGM)
+	},
+	"pnm": func(w io.Writer, img image.Image) error {
+		return pnm.Encode(w, img, pnm.FormatPNM)
+	},
+	// a single frame, the palette is reduced to 256 colors
+	"gif": func(w io.Writer, img image.Image) error {
+		return gif.Encode(w, img, nil)
+	},
+}
+
+// tiff keeps 16-bit images and is compressed losslessly
+func encodeTiff(w io.Writer, img image.Image) error {
+	return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
 }
 
+// SupportedFormats returns the formats images can be encoded to (--format), in alphabetical order
+func SupportedFormats() []string {
+	formats := make([]string, 0, len(encoders))
+	for format := range encoders {
+		formats = append(formats, format)
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+// IsSupportedFormat reports whether images can be encoded to the format
+func IsSupportedFormat(format string) bool {
+	_, ok := encoders[strings.ToLower(format)]
+	return ok
+}
+
+// LoadImage decodes the image, it is rotated upright according to its EXIF orientation
 func LoadImage(imgSrc ImageReader) (image.Image, error) {
+	img, _, err := LoadImageWithMetadata(imgSrc)
+	return img, err
+}
+
+// LoadImageWithMetadata decodes the image like LoadImage and also returns its EXIF and ICC profile, nil if it has none.
+// SVG inputs are rasterised at their intrinsic size, see ImageIO.SVG to choose it.
+func LoadImageWithMetadata(imgSrc ImageReader) (image.Image, *types.SourceMetadata, error) {
+	return loadImage(imgSrc, svg.Options{})
+}
+
+func loadImage(imgSrc ImageReader, svgOpts svg.Options) (image.Image, *types.SourceMetadata, error) {
 	// For NoInput, return a placeholder image (won't be used by generators)
 	if _, ok := imgSrc.(NoInput); ok {
-		return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil
+		return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil, nil
+	}
+	// the frames of an animation are decoded once for all of its FrameReaders, see --frames
+	if frame, ok := imgSrc.(FrameReader); ok {
+		img, err := frame.frame()
+		return img, nil, err
 	}
 
 	reader, err := imgSrc.Open()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer reader.Close()
 	imgData, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return decodeImage(imgSrc, imgData, svgOpts)
+}
+
+// decodeImage decodes the data read from imgSrc, see LoadImageWithMetadata
+func decodeImage(imgSrc ImageReader, imgData []byte, svgOpts svg.Options) (image.Image, *types.SourceMetadata, error) {
+	// a single frame of an animated image, see --frames
+	if frame, ok := imgSrc.(FrameReader); ok {
+		img, err := frame.frame()
+		return img, nil, err
+	}
+
+	if svg.IsSVG(imgData) {
+		img, err := svg.Rasterise(imgData, svgOpts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", imgSrc.String(), err)
+		}
+		return img, nil, nil
+	}
+
+	// image.Decode doesn't support animated webp, the first frame is used
+	if isAnimatedWebP(imgData) {
+		anim, err := decodeWebPAnimation(imgData)
+		if err == nil && len(anim.Frames) == 0 {
+			err = errInvalidAnimation
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", imgSrc.String(), err)
+		}
+		return anim.Frames[0], readMetadata(imgData), nil
 	}
+
 	img, _, err := image.Decode(bytes.NewReader(imgData))
 	if err != nil {
-		return nil, fmt.Errorf("unknown format : %s", imgSrc.String())
+		return nil, nil, fmt.Errorf("unknown format : %s", imgSrc.String())
+	}
+
+	metadata := readMetadata(imgData)
+	if metadata != nil && metadata.Orientation > 1 {
+		img = applyOrientation(img, metadata.Orientation)
+		metadata.EXIF = resetExifOrientation(metadata.EXIF)
 	}
-	return img, nil
+	return img, metadata, nil
 }
 
 func LoadFileBytes(src ImageReader) ([]byte, error) {
@@ -0,0 +1,262 @@
+package imageio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ManifestFileName is the file kept in the output folder by --incremental to remember what produced each output
+const ManifestFileName = ".gowall-manifest.json"
+
+// flags that only affect which inputs are picked, where outputs go or how gowall runs and reports, not the content of the outputs
+var manifestIgnoredFlags = map[string]bool{
+	"batch":             true,
+	"dir":               true,
+	"output":            true,
+	"preview":           true,
+	"yes":               true,
+	"jobs":              true,
+	"incremental":       true,
+	"name":              true,
+	"on-collision":      true,
+	"continue-on-error": true,
+	"failure-report":    true,
+	"retries":           true,
+	"retry-delay":       true,
+	"recursive":         true,
+	"include":           true,
+	"exclude":           true,
+	"dry-run":           true,
+	"json":              true,
+}
+
+type ManifestEntry struct {
+	Input     string `json:"input"`
+	InputHash string `json:"input_hash"`
+	Processor string `json:"processor"`
+	Options   string `json:"options"`
+	Output    string `json:"output"`
+}
+
+// Manifest records the input hash, processor and options of every output in a folder,
+// so a later --incremental run can skip the outputs that are already up to date.
+type Manifest struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]ManifestEntry // keyed by the absolute output path
+	pending map[string]ManifestEntry // entries of this run, moved to entries once their output is saved
+}
+
+type manifestFile struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// LoadManifest reads the manifest stored in dir, a missing manifest is treated as empty
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{
+		path:    filepath.Join(dir, ManifestFileName),
+		entries: make(map[string]ManifestEntry),
+		pending: make(map[string]ManifestEntry),
+	}
+
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while reading manifest %s: %w", m.path, err)
+	}
+
+	var file manifestFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("while parsing manifest %s: %w", m.path, err)
+	}
+	for _, entry := range file.Entries {
+		m.entries[entry.Output] = entry
+	}
+	return m, nil
+}
+
+// IsUpToDate reports whether the output of entry was already produced from the same input, processor and options
+func (m *Manifest) IsUpToDate(entry ManifestEntry) bool {
+	m.mu.Lock()
+	previous, ok := m.entries[entry.Output]
+	m.mu.Unlock()
+
+	if !ok || previous != entry {
+		return false
+	}
+	_, err := os.Stat(entry.Output)
+	return err == nil
+}
+
+// Track remembers the entry of an operation, it is only written to the manifest after Record is called for its output
+func (m *Manifest) Track(entry ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[entry.Output] = entry
+}
+
+// Record marks the output as successfully saved
+func (m *Manifest) Record(output ImageWriter) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.pending[output.String()]; ok {
+		m.entries[entry.Output] = entry
+		delete(m.pending, entry.Output)
+	}
+}
+
+// Save writes the manifest next to the outputs
+func (m *Manifest) Save() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	file := manifestFile{Entries: make([]ManifestEntry, 0, len(m.entries))}
+	for _, entry := range m.entries {
+		file.Entries = append(file.Entries, entry)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(file.Entries, func(i, j int) bool {
+		return file.Entries[i].Output < file.Entries[j].Output
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(FileWriter{Path: m.path}, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// SaveManifests saves every manifest referenced by the operations once
+func SaveManifests(ops []ImageIO) error {
+	saved := make(map[*Manifest]bool)
+	var errs []error
+	for _, op := range ops {
+		if op.Manifest == nil || saved[op.Manifest] {
+			continue
+		}
+		saved[op.Manifest] = true
+		if err := op.Manifest.Save(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// filterUpToDate drops the operations whose output is already up to date according to the manifest of its output folder,
+// the remaining file operations get a manifest so ProcessImgs can record them once saved.
+func filterUpToDate(ops []ImageIO, cmd *cobra.Command, processorConfig string) ([]ImageIO, error) {
+	processor, options := processorFingerprint(cmd, processorConfig)
+	manifests := make(map[string]*Manifest)
+
+	var remaining []ImageIO
+	for _, op := range ops {
+		input, isFileInput := op.ImageInput.(FileReader)
+		output, isFileOutput := op.ImageOutput.(FileWriter)
+		if !isFileInput || !isFileOutput {
+			remaining = append(remaining, op)
+			continue
+		}
+
+		dir := filepath.Dir(output.String())
+		manifest, ok := manifests[dir]
+		if !ok {
+			var err error
+			manifest, err = LoadManifest(dir)
+			if err != nil {
+				return nil, err
+			}
+			manifests[dir] = manifest
+		}
+
+		hash, err := hashFile(input.Path)
+		if err != nil {
+			return nil, fmt.Errorf("while hashing %s: %w", input.Path, err)
+		}
+
+		entry := ManifestEntry{
+			Input:     input.String(),
+			InputHash: hash,
+			Processor: processor,
+			Options:   options,
+			Output:    output.String(),
+		}
+		if manifest.IsUpToDate(entry) {
+			continue
+		}
+
+		manifest.Track(entry)
+		op.Manifest = manifest
+		remaining = append(remaining, op)
+	}
+
+	if skipped := len(ops) - len(remaining); skipped > 0 {
+		logger.Printf("::: Skipped %d up to date images, %d left to process :::\n", skipped, len(remaining))
+	}
+	return remaining, nil
+}
+
+// processorFingerprint returns the subcommand path (e.g. "effects br") and the flags and processorConfig that affect its output.
+// Flags pointing to files (e.g. a json theme or a recipe) also include the hash of the file, so editing them invalidates the outputs.
+func processorFingerprint(cmd *cobra.Command, processorConfig string) (string, string) {
+	processor := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+
+	var options []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if manifestIgnoredFlags[flag.Name] {
+			return
+		}
+		value := flag.Value.String()
+		path := config.ExpandTilde([]string{value})[0]
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			if hash, err := hashFile(path); err == nil {
+				value += "@" + hash
+			}
+		}
+		options = append(options, flag.Name+"="+value)
+	})
+	sort.Strings(options)
+	if processorConfig != "" {
+		options = append(options, "config:"+processorConfig)
+	}
+
+	return processor, strings.Join(options, " ")
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
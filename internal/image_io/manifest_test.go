@@ -0,0 +1,53 @@
+package imageio
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// fingerprintCmd returns "gowall invert" with a persistent flag of the root and flags of the subcommand set to args
+func fingerprintCmd(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	root := &cobra.Command{Use: "gowall"}
+	root.PersistentFlags().Bool("json", false, "")
+	root.PersistentFlags().Bool("dry-run", false, "")
+	cmd := &cobra.Command{Use: "invert"}
+	cmd.PersistentFlags().String("dir", "", "")
+	cmd.PersistentFlags().Int("jobs", 0, "")
+	cmd.PersistentFlags().Bool("recursive", false, "")
+	cmd.PersistentFlags().StringArray("exclude", nil, "")
+	cmd.PersistentFlags().String("format", "", "")
+	root.AddCommand(cmd)
+
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestProcessorFingerprint(t *testing.T) {
+	processor, options := processorFingerprint(fingerprintCmd(t, "--dir", "walls"), "mapper=rbf")
+	if processor != "invert" {
+		t.Errorf("processor %q, want invert", processor)
+	}
+
+	outputOnly := [][]string{
+		{"--dir", "walls", "--json"},
+		{"--dir", "walls", "--dry-run"},
+		{"--dir", "walls", "--jobs", "4"},
+		{"--dir", "walls", "--recursive", "--exclude", "raw/**"},
+	}
+	for _, args := range outputOnly {
+		if _, got := processorFingerprint(fingerprintCmd(t, args...), "mapper=rbf"); got != options {
+			t.Errorf("%v changed the options to %q, want %q", args, got, options)
+		}
+	}
+
+	if _, got := processorFingerprint(fingerprintCmd(t, "--dir", "walls", "--format", "webp"), "mapper=rbf"); got == options {
+		t.Error("--format should change the options")
+	}
+	if _, got := processorFingerprint(fingerprintCmd(t, "--dir", "walls"), "mapper=nn"); got == options {
+		t.Error("the processor config should change the options")
+	}
+}
@@ -0,0 +1,380 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"io"
+	"sort"
+
+	types "github.com/Achno/gowall/internal/types"
+)
+
+// MetadataMode controls which metadata of the input is written to the output
+type MetadataMode int
+
+const (
+	MetadataColorProfile MetadataMode = iota // default, only the ICC profile is kept, EXIF (camera, GPS...) is dropped
+	MetadataKeep                             // --keep-metadata, EXIF and ICC profile are kept
+	MetadataStrip                            // --strip-metadata, nothing is kept
+)
+
+// Filter returns the part of the source metadata that the mode keeps
+func (m MetadataMode) Filter(src *types.SourceMetadata) *types.SourceMetadata {
+	if src.IsEmpty() {
+		return nil
+	}
+	switch m {
+	case MetadataKeep:
+		return src
+	case MetadataStrip:
+		return nil
+	default:
+		if len(src.ICC) == 0 {
+			return nil
+		}
+		return &types.SourceMetadata{ICC: src.ICC, Orientation: src.Orientation}
+	}
+}
+
+const (
+	exifOrientationTag = 0x0112
+	jpegExifHeader     = "Exif\x00\x00"
+	jpegICCHeader      = "ICC_PROFILE\x00"
+	jpegMaxSegment     = 65533 // max payload of a jpeg marker segment, excluding the 2 length bytes
+)
+
+// readMetadata extracts the EXIF and ICC profile of a jpeg, png or webp image, other formats return nil
+func readMetadata(data []byte) *types.SourceMetadata {
+	var meta *types.SourceMetadata
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		meta = readJpegMetadata(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		meta = readPngMetadata(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		meta = readWebpMetadata(data)
+	}
+	if meta.IsEmpty() {
+		return nil
+	}
+	meta.Orientation, _ = exifOrientation(meta.EXIF)
+	return meta
+}
+
+func readJpegMetadata(data []byte) *types.SourceMetadata {
+	meta := &types.SourceMetadata{}
+	iccChunks := map[int][]byte{}
+
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // metadata is always before the image data
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if pos+2+length > len(data) {
+			break
+		}
+		payload := data[pos+4 : pos+2+length]
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte(jpegExifHeader)):
+			meta.EXIF = payload[len(jpegExifHeader):]
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte(jpegICCHeader)) && len(payload) > len(jpegICCHeader)+2:
+			seq := int(payload[len(jpegICCHeader)])
+			iccChunks[seq] = payload[len(jpegICCHeader)+2:]
+		}
+		pos += 2 + length
+	}
+
+	// the profile can be split over multiple APP2 segments ordered by their sequence number
+	seqs := make([]int, 0, len(iccChunks))
+	for seq := range iccChunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		meta.ICC = append(meta.ICC, iccChunks[seq]...)
+	}
+	return meta
+}
+
+func readPngMetadata(data []byte) *types.SourceMetadata {
+	meta := &types.SourceMetadata{}
+
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		if pos+12+length > len(data) || chunkType == "IDAT" || chunkType == "IEND" {
+			break
+		}
+		chunk := data[pos+8 : pos+8+length]
+
+		switch chunkType {
+		case "eXIf":
+			meta.EXIF = chunk
+		case "iCCP":
+			// profile name, null separator, compression method and the zlib compressed profile
+			if i := bytes.IndexByte(chunk, 0); i != -1 && i+2 <= len(chunk) {
+				if r, err := zlib.NewReader(bytes.NewReader(chunk[i+2:])); err == nil {
+					meta.ICC, _ = io.ReadAll(r)
+					r.Close()
+				}
+			}
+		}
+		pos += 12 + length
+	}
+	return meta
+}
+
+func readWebpMetadata(data []byte) *types.SourceMetadata {
+	meta := &types.SourceMetadata{}
+	for _, chunk := range riffChunks(data) {
+		switch chunk.fourCC {
+		case "ICCP":
+			meta.ICC = chunk.data
+		case "EXIF":
+			meta.EXIF = bytes.TrimPrefix(chunk.data, []byte(jpegExifHeader))
+		}
+	}
+	return meta
+}
+
+// exifOrientation returns the orientation tag of the EXIF payload and the offset of its value
+func exifOrientation(exif []byte) (int, int) {
+	if len(exif) < 8 {
+		return 0, -1
+	}
+
+	var order binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, -1
+	}
+
+	ifd := int(order.Uint32(exif[4:8]))
+	if ifd+2 > len(exif) {
+		return 0, -1
+	}
+	entries := int(order.Uint16(exif[ifd : ifd+2]))
+	for i := range entries {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(exif) {
+			break
+		}
+		if order.Uint16(exif[entry:entry+2]) == exifOrientationTag {
+			return int(order.Uint16(exif[entry+8 : entry+10])), entry + 8
+		}
+	}
+	return 0, -1
+}
+
+// resetExifOrientation returns a copy of the EXIF payload with the orientation set to 1 (upright),
+// since the pixels are rotated on load the viewers must not rotate the output again.
+func resetExifOrientation(exif []byte) []byte {
+	orientation, offset := exifOrientation(exif)
+	if orientation <= 1 {
+		return exif
+	}
+
+	exif = bytes.Clone(exif)
+	if string(exif[:2]) == "II" {
+		binary.LittleEndian.PutUint16(exif[offset:offset+2], 1)
+	} else {
+		binary.BigEndian.PutUint16(exif[offset:offset+2], 1)
+	}
+	return exif
+}
+
+// writeMetadata embeds the metadata into an encoded jpeg, png or webp image, other formats are returned unchanged
+func writeMetadata(data []byte, meta *types.SourceMetadata) []byte {
+	if meta.IsEmpty() {
+		return data
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return writeJpegMetadata(data, meta)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return writePngMetadata(data, meta)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return writeWebpMetadata(data, meta)
+	}
+	return data
+}
+
+func writeJpegMetadata(data []byte, meta *types.SourceMetadata) []byte {
+	var segments bytes.Buffer
+
+	writeSegment := func(marker byte, payload ...[]byte) {
+		length := 2
+		for _, p := range payload {
+			length += len(p)
+		}
+		segments.Write([]byte{0xFF, marker, byte(length >> 8), byte(length)})
+		for _, p := range payload {
+			segments.Write(p)
+		}
+	}
+
+	if exif := meta.EXIF; len(exif) > 0 && len(exif)+len(jpegExifHeader) <= jpegMaxSegment {
+		writeSegment(0xE1, []byte(jpegExifHeader), exif)
+	}
+
+	if icc := meta.ICC; len(icc) > 0 {
+		chunkSize := jpegMaxSegment - len(jpegICCHeader) - 2
+		count := (len(icc) + chunkSize - 1) / chunkSize
+		if count <= 255 {
+			for i := range count {
+				chunk := icc[i*chunkSize : min((i+1)*chunkSize, len(icc))]
+				writeSegment(0xE2, []byte(jpegICCHeader), []byte{byte(i + 1), byte(count)}, chunk)
+			}
+		}
+	}
+
+	// right after SOI
+	out := make([]byte, 0, len(data)+segments.Len())
+	out = append(out, data[:2]...)
+	out = append(out, segments.Bytes()...)
+	return append(out, data[2:]...)
+}
+
+func writePngMetadata(data []byte, meta *types.SourceMetadata) []byte {
+	// IHDR is always the first chunk, iCCP has to be before PLTE and IDAT
+	const ihdrEnd = 8 + 12 + 13
+	if len(data) < ihdrEnd || bytes.Contains(data[:min(len(data), 4096)], []byte("iCCP")) {
+		return data
+	}
+
+	var chunks bytes.Buffer
+	writeChunk := func(chunkType string, payload []byte) {
+		binary.Write(&chunks, binary.BigEndian, uint32(len(payload)))
+		crc := crc32.NewIEEE()
+		crc.Write([]byte(chunkType))
+		crc.Write(payload)
+		chunks.WriteString(chunkType)
+		chunks.Write(payload)
+		binary.Write(&chunks, binary.BigEndian, crc.Sum32())
+	}
+
+	if len(meta.ICC) > 0 {
+		var compressed bytes.Buffer
+		compressed.WriteString("ICC Profile\x00\x00")
+		w := zlib.NewWriter(&compressed)
+		w.Write(meta.ICC)
+		w.Close()
+		writeChunk("iCCP", compressed.Bytes())
+	}
+	if len(meta.EXIF) > 0 {
+		writeChunk("eXIf", meta.EXIF)
+	}
+
+	out := make([]byte, 0, len(data)+chunks.Len())
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunks.Bytes()...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+type riffChunk struct {
+	fourCC string
+	data   []byte
+}
+
+// riffChunks splits the chunks of a webp file
+func riffChunks(data []byte) []riffChunk {
+	return parseChunks(data, 12)
+}
+
+// parseChunks splits the RIFF chunks that follow pos, e.g. the frame data of an ANMF chunk
+func parseChunks(data []byte, pos int) []riffChunk {
+	var chunks []riffChunk
+	for pos+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if pos+8+size > len(data) {
+			break
+		}
+		chunks = append(chunks, riffChunk{fourCC: string(data[pos : pos+4]), data: data[pos+8 : pos+8+size]})
+		pos += 8 + size + size%2
+	}
+	return chunks
+}
+
+// writeWebpMetadata converts simple webp files to the extended format (VP8X), which is required for ICCP and EXIF chunks
+func writeWebpMetadata(data []byte, meta *types.SourceMetadata) []byte {
+	chunks := riffChunks(data)
+	if len(chunks) == 0 {
+		return data
+	}
+
+	const (
+		vp8xICC   = 0x20
+		vp8xAlpha = 0x10
+		vp8xEXIF  = 0x08
+	)
+
+	var vp8x []byte
+	if chunks[0].fourCC == "VP8X" {
+		vp8x = bytes.Clone(chunks[0].data)
+		chunks = chunks[1:]
+	} else {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return data
+		}
+		vp8x = make([]byte, 10)
+		putUint24 := func(b []byte, v int) { b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16) }
+		putUint24(vp8x[4:7], cfg.Width-1)
+		putUint24(vp8x[7:10], cfg.Height-1)
+		// lossless images store whether they use alpha in their header
+		if c := chunks[0]; c.fourCC == "VP8L" && len(c.data) >= 5 && binary.LittleEndian.Uint32(c.data[1:5])>>28&1 == 1 {
+			vp8x[0] |= vp8xAlpha
+		}
+	}
+
+	vp8x[0] &^= vp8xICC | vp8xEXIF
+
+	var out []riffChunk
+	out = append(out, riffChunk{fourCC: "VP8X", data: vp8x})
+	if len(meta.ICC) > 0 {
+		vp8x[0] |= vp8xICC
+		out = append(out, riffChunk{fourCC: "ICCP", data: meta.ICC})
+	}
+	for _, c := range chunks {
+		if c.fourCC != "ICCP" && c.fourCC != "EXIF" {
+			out = append(out, c)
+		}
+	}
+	if len(meta.EXIF) > 0 {
+		vp8x[0] |= vp8xEXIF
+		out = append(out, riffChunk{fourCC: "EXIF", data: meta.EXIF})
+	}
+
+	return encodeRIFF(out)
+}
+
+// encodeRIFF writes the chunks as a webp file
+func encodeRIFF(chunks []riffChunk) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	for _, c := range chunks {
+		body.WriteString(c.fourCC)
+		binary.Write(&body, binary.LittleEndian, uint32(len(c.data)))
+		body.Write(c.data)
+		if len(c.data)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+
+	var riff bytes.Buffer
+	riff.WriteString("RIFF")
+	binary.Write(&riff, binary.LittleEndian, uint32(body.Len()))
+	riff.Write(body.Bytes())
+	return riff.Bytes()
+}
@@ -0,0 +1,198 @@
+package imageio
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/backends/codecs/svg"
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+// Policies for an output that already exists or is written twice by the same run (--on-collision)
+const (
+	CollisionOverwrite = "overwrite"
+	CollisionSkip      = "skip"
+	CollisionSuffix    = "suffix"
+)
+
+// NameVariables are the placeholders of a --name template:
+// {stem} input file name without extension, {ext} output format, {dir} directory of the input relative to --dir,
+// {theme} value of --theme, {w} and {h} size of the input, {date} and {time} of the run, {cmd} name of the command.
+var NameVariables = []string{"stem", "ext", "dir", "theme", "w", "h", "date", "time", "cmd"}
+
+var namePlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// runTime is the time {date} and {time} expand to, shared by every output of the run
+var runTime = time.Now()
+
+// ValidateNameTemplate checks that the template only uses known placeholders
+func ValidateNameTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("--name can not be empty")
+	}
+	if filepath.IsAbs(template) {
+		return fmt.Errorf("--name %q must be relative to the output folder, use --output to choose the folder", template)
+	}
+	for _, match := range namePlaceholder.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(NameVariables, match[1]) {
+			return fmt.Errorf("unknown placeholder {%s} in --name, available: {%s}", match[1], strings.Join(NameVariables, "}, {"))
+		}
+	}
+	return nil
+}
+
+// templateOutput expands --name for an input whose default output would be relPath in dir
+// and returns the output path and its format. Templates without an extension get the one of ext.
+func templateOutput(flags config.GlobalSubCommandFlags, cmd *cobra.Command, input ImageReader, dir string, relPath string, ext string) (string, string, error) {
+	stem := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
+	relDir := filepath.Dir(relPath)
+	if relDir == "." {
+		relDir = ""
+	}
+
+	vars := map[string]string{
+		"stem":  stem,
+		"ext":   ext,
+		"dir":   relDir,
+		"theme": themeName(cmd),
+		"date":  runTime.Format("2006-01-02"),
+		"time":  runTime.Format("150405"),
+		"cmd":   cmd.Name(),
+	}
+	if strings.Contains(flags.NameTemplate, "{w}") || strings.Contains(flags.NameTemplate, "{h}") {
+		// the size needs the input, --dry-run reads nothing and prints the placeholders
+		vars["w"], vars["h"] = "{w}", "{h}"
+		if !flags.DryRun {
+			w, h, err := inputSize(input, svg.Options{Width: flags.RasterWidth, Height: flags.RasterHeight, DPI: flags.RasterDPI})
+			if err != nil {
+				return "", "", err
+			}
+			vars["w"], vars["h"] = strconv.Itoa(w), strconv.Itoa(h)
+		}
+	}
+
+	name := namePlaceholder.ReplaceAllStringFunc(flags.NameTemplate, func(placeholder string) string {
+		return vars[strings.Trim(placeholder, "{}")]
+	})
+	name = filepath.Clean(name)
+	if name == "." || strings.HasPrefix(name, ".."+string(filepath.Separator)) || name == ".." {
+		return "", "", fmt.Errorf("--name expands to %q for %s, which is outside of the output folder", name, input.String())
+	}
+	if filepath.Ext(flags.NameTemplate) == "" {
+		name += "." + ext
+	}
+
+	output := FileWriter{Path: filepath.Join(dir, name)}
+	format, err := determineFileExt(flags, input, output, cmd)
+	if err != nil {
+		return "", "", err
+	}
+	return output.Path, format, nil
+}
+
+// themeName is the value of the --theme flag of the command, json themes are named after their file
+func themeName(cmd *cobra.Command) string {
+	flag := cmd.Flags().Lookup("theme")
+	if flag == nil || flag.Value.String() == "" {
+		return "none"
+	}
+	theme := flag.Value.String()
+	if strings.EqualFold(filepath.Ext(theme), ".json") {
+		return strings.TrimSuffix(filepath.Base(theme), filepath.Ext(theme))
+	}
+	return theme
+}
+
+// inputSize returns the size of the input upright, without decoding all of its pixels when the format allows it
+func inputSize(input ImageReader, svgOpts svg.Options) (int, int, error) {
+	reader, err := input.Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// formats without a header image.DecodeConfig knows, e.g. svg or animated webp
+		img, _, err := decodeImage(input, data, svgOpts)
+		if err != nil {
+			return 0, 0, err
+		}
+		return img.Bounds().Dx(), img.Bounds().Dy(), nil
+	}
+
+	// orientations 5-8 are rotated by 90 degrees when loaded
+	if meta := readMetadata(data); meta != nil && meta.Orientation >= 5 {
+		return cfg.Height, cfg.Width, nil
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// resolveCollisions applies the --on-collision policy to outputs written twice by the operations
+// and, when checkDisk is set, to outputs that already exist.
+func resolveCollisions(ops []ImageIO, policy string, checkDisk bool) []ImageIO {
+	if policy == "" || policy == CollisionOverwrite {
+		return ops
+	}
+
+	taken := make(map[string]bool)
+	exists := func(path string) bool {
+		if taken[path] {
+			return true
+		}
+		if !checkDisk {
+			return false
+		}
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	var resolved []ImageIO
+	for _, op := range ops {
+		output, ok := op.ImageOutput.(FileWriter)
+		if !ok {
+			resolved = append(resolved, op)
+			continue
+		}
+		path := output.String()
+
+		if exists(path) {
+			if policy == CollisionSkip {
+				logger.Warnf("::: Skipping %s, %s already exists :::", op.ImageInput.String(), path)
+				continue
+			}
+			path = suffixedPath(path, exists)
+			op.ImageOutput = FileWriter{Path: path}
+		}
+		taken[path] = true
+		resolved = append(resolved, op)
+	}
+	return resolved
+}
+
+// suffixedPath returns the first of name-1.ext, name-2.ext... that does not exist
+func suffixedPath(path string, exists func(string) bool) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package imageio
+
+import (
+	"image"
+	"image/draw"
+)
+
+// applyOrientation rotates/flips the image according to its EXIF orientation (1-8) so it is upright
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// orientations 5-8 swap the width and the height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	// high bit depth images are rotated as NRGBA64 so they keep their precision
+	var dst image.Image
+	var srcPix, dstPix []uint8
+	var srcStride, dstStride, bytesPerPixel int
+	if IsHighBitDepth(img) {
+		src := image.NewNRGBA64(bounds)
+		draw.Draw(src, bounds, img, img.Bounds().Min, draw.Src)
+		out := image.NewNRGBA64(image.Rect(0, 0, dw, dh))
+		dst, srcPix, dstPix, srcStride, dstStride, bytesPerPixel = out, src.Pix, out.Pix, src.Stride, out.Stride, 8
+	} else {
+		src := image.NewNRGBA(bounds)
+		draw.Draw(src, bounds, img, img.Bounds().Min, draw.Src)
+		out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+		dst, srcPix, dstPix, srcStride, dstStride, bytesPerPixel = out, src.Pix, out.Pix, src.Stride, out.Stride, 4
+	}
+
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // mirrored horizontally and rotated 270 clockwise
+				sx, sy = y, x
+			case 6: // rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // mirrored horizontally and rotated 90 clockwise
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 270 clockwise
+				sx, sy = w-1-y, x
+			}
+			si := sy*srcStride + sx*bytesPerPixel
+			di := y*dstStride + x*bytesPerPixel
+			copy(dstPix[di:di+bytesPerPixel], srcPix[si:si+bytesPerPixel])
+		}
+	}
+	return dst
+}
@@ -1,11 +1,10 @@
 package imageio
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"io"
-	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -20,12 +19,11 @@ func SaveImage(img image.Image, output ImageWriter, format string, metadata type
 	//? This is to bypass the default encoders for compression as well as a very hacky solution
 	//? to allow Composite() to work with gifs and save them.
 	if metadata.EncoderFunction != nil {
-		file, err := output.Create()
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		return metadata.EncoderFunction(file, img)
+		return writeOutput(output, func(w io.Writer) error {
+			return encodeWithMetadata(w, metadata.Source, func(w io.Writer) error {
+				return metadata.EncoderFunction(w, img)
+			})
+		})
 	}
 
 	encoder, ok := encoders[strings.ToLower(format)]
@@ -37,15 +35,62 @@ func SaveImage(img image.Image, output ImageWriter, format string, metadata type
 		return nil
 	}
 
-	file, err := output.Create()
+	return writeOutput(output, func(w io.Writer) error {
+		return encodeWithMetadata(w, metadata.Source, func(w io.Writer) error {
+			return encoder(w, img)
+		})
+	})
+}
+
+// Encode writes the image to w in one of the SupportedFormats
+func Encode(w io.Writer, img image.Image, format string) error {
+	encoder, ok := encoders[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	return encoder(w, img)
+}
+
+// encodeWithMetadata embeds the source metadata into the encoded image when the format supports it (jpeg, png, webp)
+func encodeWithMetadata(w io.Writer, source *types.SourceMetadata, encode func(w io.Writer) error) error {
+	if source.IsEmpty() {
+		return encode(w)
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf); err != nil {
+		return err
+	}
+	_, err := w.Write(writeMetadata(buf.Bytes(), source))
+	return err
+}
+
+// WriteFile writes the file at path through write like the outputs of the commands,
+// the file is replaced at once so readers never see it half written.
+func WriteFile(path string, write func(w io.Writer) error) error {
+	return writeOutput(FileWriter{Path: path}, write)
+}
+
+// writeOutput creates the output and hands it to write, outputs that support it are discarded if write fails.
+func writeOutput(output ImageWriter, write func(w io.Writer) error) error {
+	w, err := output.Create()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	return encoder(file, img)
+	if err := write(w); err != nil {
+		if aborter, ok := w.(interface{ Abort() error }); ok {
+			aborter.Abort()
+		} else {
+			w.Close()
+		}
+		return err
+	}
+
+	return w.Close()
 }
 
+// SaveUrlAsImg downloads the image of the url to the output folder with the limits of URLReader and returns its path
 func SaveUrlAsImg(url string) (string, error) {
 	extension, err := utils.GetFileExtensionFromURL(url)
 	if err != nil {
@@ -57,23 +102,16 @@ func SaveUrlAsImg(url string) (string, error) {
 
 	path := filepath.Join(config.GowallConfig.OutputFolder, fileName)
 
-	file, err := os.Create(path)
-	if err != nil {
-		return "", fmt.Errorf("could not create file: %w", err)
-	}
-	defer file.Close()
-
-	resp, err := http.Get(url)
+	reader, err := URLReader{URL: url}.Open()
 	if err != nil {
-		return "", fmt.Errorf("could not fetch the URL: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch image: status code %d", resp.StatusCode)
+		return "", err
 	}
+	defer reader.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	err = writeOutput(FileWriter{Path: path}, func(w io.Writer) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("could not write to file: %w", err)
 	}
@@ -86,13 +124,10 @@ func SaveText(text string, output ImageWriter) error {
 		return nil
 	}
 
-	file, err := output.Create()
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(text)
+	err := writeOutput(output, func(w io.Writer) error {
+		_, err := io.WriteString(w, text)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to write text to file: %w", err)
 	}
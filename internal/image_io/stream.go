@@ -0,0 +1,206 @@
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Achno/gowall/config"
+)
+
+// MemoryReader is an image that was already read in memory, e.g. a single image of a stream piped to stdin
+type MemoryReader struct {
+	Name string
+	Data []byte
+}
+
+func (mr MemoryReader) Open() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(mr.Data)), nil
+}
+
+func (mr MemoryReader) String() string {
+	return mr.Name
+}
+
+// ReadStreamInputs reads the inputs of multi input commands (gif, stack) piped to stdin.
+// The stream is either images concatenated back to back (png, jpeg, gif, webp, bmp) or a newline separated list of paths.
+func ReadStreamInputs(r io.Reader) ([]ImageReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("while reading stdin: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("no images or paths were piped to stdin")
+	}
+
+	if streamImageLength(data) == -1 {
+		return readPathList(data)
+	}
+
+	var inputs []ImageReader
+	for len(data) > 0 {
+		n := streamImageLength(data)
+		if n == -1 {
+			return nil, fmt.Errorf("image %d of stdin has an unknown format, only png,jpeg,gif,webp and bmp can be concatenated", len(inputs)+1)
+		}
+		// unsupported or truncated images are assumed to span the rest of the stream
+		if n == 0 || n > len(data) {
+			n = len(data)
+		}
+		inputs = append(inputs, MemoryReader{
+			Name: fmt.Sprintf("%s#%d", Stdin{}.String(), len(inputs)+1),
+			Data: data[:n],
+		})
+		data = data[n:]
+
+		// tolerate whitespace between images, e.g. from `echo` or `cat` of text files
+		data = bytes.TrimLeft(data, " \r\n\t")
+	}
+	return inputs, nil
+}
+
+// readPathList parses a newline separated list of image paths or http(s) urls, empty lines are ignored
+func readPathList(data []byte) ([]ImageReader, error) {
+	var inputs []ImageReader
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if IsURL(line) {
+			inputs = append(inputs, newURLReader(line))
+			continue
+		}
+		path, err := filepath.Abs(config.ExpandTilde([]string{line})[0])
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, FileReader{Path: path})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("while reading the paths from stdin: %w", err)
+	}
+	return inputs, nil
+}
+
+// streamImageLength returns the size in bytes of the image at the start of data,
+// 0 if it is an image whose size can not be determined and -1 if data does not start with an image.
+func streamImageLength(data []byte) int {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return pngLength(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return jpegLength(data)
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return gifLength(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		n := 8 + int(binary.LittleEndian.Uint32(data[4:8]))
+		return n + n%2
+	case len(data) >= 6 && string(data[:2]) == "BM":
+		return int(binary.LittleEndian.Uint32(data[2:6]))
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		// avif, the boxes are not walked so it has to be the last image
+		return 0
+	}
+	return -1
+}
+
+// pngLength walks the chunks until IEND
+func pngLength(data []byte) int {
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		pos += 12 + length // length + type + data + crc
+		if chunkType == "IEND" {
+			return pos
+		}
+	}
+	return 0
+}
+
+// jpegLength walks the segments until the EOI marker, skipping over the entropy coded data after SOS
+func jpegLength(data []byte) int {
+	pos := 2
+	for pos+2 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xD9: // EOI
+			return pos + 2
+		case marker == 0xFF: // fill byte
+			pos++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // markers without a payload
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return 0
+		}
+		pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:pos+4]))
+		if marker != 0xDA { // SOS
+			continue
+		}
+
+		// entropy coded data ends at the next marker that is not a stuffed byte or a restart marker
+		for pos+1 < len(data) {
+			if data[pos] == 0xFF && data[pos+1] != 0x00 && (data[pos+1] < 0xD0 || data[pos+1] > 0xD7) {
+				break
+			}
+			pos++
+		}
+	}
+	return 0
+}
+
+// gifLength walks the blocks until the trailer
+func gifLength(data []byte) int {
+	if len(data) < 13 {
+		return 0
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (int(data[10]&0x07) + 1) // global color table
+	}
+
+	skipSubBlocks := func() {
+		for pos < len(data) && data[pos] != 0 {
+			pos += int(data[pos]) + 1
+		}
+		pos++ // block terminator
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B: // trailer
+			return pos + 1
+		case 0x21: // extension
+			pos += 2
+			skipSubBlocks()
+		case 0x2C: // image descriptor
+			if pos+10 > len(data) {
+				return 0
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (int(flags&0x07) + 1) // local color table
+			}
+			pos++ // lzw minimum code size
+			skipSubBlocks()
+		default:
+			return 0
+		}
+	}
+	return 0
+}
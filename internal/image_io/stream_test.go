@@ -0,0 +1,256 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: uint8(x ^ y), A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeGIF writes an animation with a global color table, frames with local color tables and extensions
+func encodeGIF(t *testing.T) []byte {
+	t.Helper()
+	global := color.Palette{color.Black, color.White}
+	local := color.Palette{color.RGBA{R: 0xff, A: 0xff}, color.RGBA{G: 0xff, A: 0xff}, color.RGBA{B: 0xff, A: 0xff}}
+	g := &gif.GIF{LoopCount: 0, Config: image.Config{ColorModel: global, Width: 20, Height: 10}}
+	for i, palette := range []color.Palette{global, local} {
+		frame := image.NewPaletted(image.Rect(0, 0, 20, 10), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8((j + i) % len(palette))
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 5)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeBMP(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := bmp.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// riffWebP is a RIFF container with an odd sized payload, which is padded to an even size
+func riffWebP(payload string) []byte {
+	data := []byte("RIFF")
+	data = binary.LittleEndian.AppendUint32(data, uint32(4+len(payload)))
+	data = append(data, "WEBP"+payload...)
+	if len(payload)%2 == 1 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+func TestReadStreamInputs(t *testing.T) {
+	progressive, err := os.ReadFile(filepath.Join("testdata", "progressive.jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pngData := encodePNG(t, testImage(40, 30))
+	jpegData := encodeJPEG(t, testImage(33, 17))
+	gifData := encodeGIF(t)
+	bmpData := encodeBMP(t, testImage(7, 5))
+	webpData := riffWebP("VP8L\x01\x00\x00\x00\x2f")
+
+	tests := []struct {
+		name   string
+		images [][]byte // concatenated back to back
+		want   [][]byte // the images split from the stream, nil = images
+	}{
+		{name: "png jpeg gif", images: [][]byte{pngData, jpegData, gifData}},
+		{name: "progressive jpeg", images: [][]byte{progressive, pngData, progressive}},
+		{name: "bmp and padded webp", images: [][]byte{bmpData, webpData, bmpData}},
+		{name: "gif after gif", images: [][]byte{gifData, gifData}},
+		{
+			name:   "truncated last image",
+			images: [][]byte{pngData, jpegData[:len(jpegData)/2]},
+		},
+		{
+			name:   "whitespace between images",
+			images: [][]byte{pngData, []byte("\n"), gifData},
+			want:   [][]byte{pngData, gifData},
+		},
+	}
+
+	for _, tt := range tests {
+		inputs, err := ReadStreamInputs(bytes.NewReader(bytes.Join(tt.images, nil)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := tt.want
+		if want == nil {
+			want = tt.images
+		}
+		if len(inputs) != len(want) {
+			t.Errorf("%s: got %d images, want %d", tt.name, len(inputs), len(want))
+			continue
+		}
+		for i, input := range inputs {
+			reader, _ := input.Open()
+			got, _ := io.ReadAll(reader)
+			if !bytes.Equal(got, want[i]) {
+				t.Errorf("%s: image %d has %d bytes, want %d", tt.name, i+1, len(got), len(want[i]))
+			}
+		}
+	}
+}
+
+func TestReadStreamInputsDecodes(t *testing.T) {
+	progressive, err := os.ReadFile(filepath.Join("testdata", "progressive.jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := bytes.Join([][]byte{encodePNG(t, testImage(40, 30)), progressive, encodeGIF(t), encodeJPEG(t, testImage(33, 17))}, nil)
+
+	inputs, err := ReadStreamInputs(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		if _, err := LoadImage(input); err != nil {
+			t.Errorf("%s: %v", input, err)
+		}
+	}
+}
+
+func TestReadStreamInputsPathList(t *testing.T) {
+	inputs, err := ReadStreamInputs(strings.NewReader("a.png\n\n  sub/b.jpg \nhttps://example.com/c.png\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 3 {
+		t.Fatalf("got %d inputs, want 3", len(inputs))
+	}
+	if file, ok := inputs[1].(FileReader); !ok || !filepath.IsAbs(file.Path) || !strings.HasSuffix(file.Path, filepath.Join("sub", "b.jpg")) {
+		t.Errorf("input 2 is %#v, want the absolute path of sub/b.jpg", inputs[1])
+	}
+	if _, ok := inputs[2].(URLReader); !ok {
+		t.Errorf("input 3 is %#v, want a URLReader", inputs[2])
+	}
+}
+
+func TestReadStreamInputsUnknown(t *testing.T) {
+	stream := append(encodePNG(t, testImage(4, 4)), "not an image"...)
+	if _, err := ReadStreamInputs(bytes.NewReader(stream)); err == nil || !strings.Contains(err.Error(), "image 2") {
+		t.Errorf("got %v, want an error about image 2", err)
+	}
+	if _, err := ReadStreamInputs(strings.NewReader(" \n ")); err == nil {
+		t.Error("an empty stream should be an error")
+	}
+}
+
+func TestJPEGLength(t *testing.T) {
+	soi, eoi := []byte{0xFF, 0xD8}, []byte{0xFF, 0xD9}
+	segment := func(marker byte, payload ...byte) []byte {
+		return append([]byte{0xFF, marker, 0, byte(2 + len(payload))}, payload...)
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"segments", join(soi, segment(0xE0, 'J', 'F'), segment(0xDB, 1, 2, 3), eoi), 17},
+		{"fill bytes", join(soi, []byte{0xFF, 0xFF, 0xFF}, segment(0xE0, 1), eoi), 12},
+		{
+			// stuffed 0xFF00 bytes and restart markers are part of the entropy coded data
+			"restart markers",
+			join(soi, segment(0xDD, 0, 4), segment(0xDA, 1, 2, 3), []byte{0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56, 0xFF, 0xD7, 0x78}, eoi),
+			2 + 6 + 7 + 10 + 2,
+		},
+		{
+			// progressive jpegs have several scans with tables in between
+			"several scans",
+			join(soi, segment(0xDA, 1), []byte{0xAA, 0xFF, 0x00}, segment(0xC4, 9, 9), segment(0xDA, 2), []byte{0xBB}, eoi),
+			2 + 5 + 3 + 6 + 5 + 1 + 2,
+		},
+		{"truncated", join(soi, segment(0xDA, 1), []byte{0xAA, 0xBB}), 0},
+		{"truncated segment", join(soi, []byte{0xFF, 0xE0, 0x00}), 0},
+	}
+
+	for _, tt := range tests {
+		if got := jpegLength(tt.data); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+		// trailing data after the image is not part of it
+		if tt.want > 0 {
+			if got := jpegLength(append(tt.data, soi...)); got != tt.want {
+				t.Errorf("%s followed by another image: got %d, want %d", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestStreamImageLengthTruncated(t *testing.T) {
+	pngData := encodePNG(t, testImage(20, 20))
+	gifData := encodeGIF(t)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"png", pngData, len(pngData)},
+		{"png without IEND", pngData[:len(pngData)-12], 0},
+		{"gif", gifData, len(gifData)},
+		{"gif without trailer", gifData[:len(gifData)-1], 0},
+		{"gif header only", gifData[:8], 0},
+		{"avif", append([]byte{0, 0, 0, 0x1c}, "ftypavif"...), 0},
+		{"text", []byte("wall.png\n"), -1},
+	}
+
+	for _, tt := range tests {
+		if got := streamImageLength(tt.data); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package imageio
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Achno/gowall/config"
+	"github.com/spf13/cobra"
+)
+
+// FormatSVG is the output format of SVG inputs that keep their vectors, see SupportsVectorOutput
+const FormatSVG = "svg"
+
+// SupportsVectorOutput reports whether the command can write SVG inputs back as SVG instead of rasterising them
+func SupportsVectorOutput(cmdName string) bool {
+	return cmdName == "convert"
+}
+
+// rasterSVGOutputs saves SVG inputs as png for the commands that only produce raster images,
+// unless svg was explicitly asked for with --format or the extension of --output.
+func rasterSVGOutputs(ops []ImageIO, flags config.GlobalSubCommandFlags, cmd *cobra.Command) ([]ImageIO, error) {
+	if SupportsVectorOutput(cmd.Name()) {
+		return ops, nil
+	}
+
+	for i, op := range ops {
+		if !strings.EqualFold(op.Format, FormatSVG) {
+			continue
+		}
+		if flags.Format != "" || filepath.Ext(flags.OutputDestination) != "" {
+			return nil, fmt.Errorf("%s can't write svg, SVG inputs are only kept as vectors by convert", cmd.Name())
+		}
+		ops[i].Format = "png"
+		if output, ok := op.ImageOutput.(FileWriter); ok {
+			ops[i].ImageOutput = FileWriter{Path: replaceExt(output.Path, "png")}
+		}
+	}
+	return ops, nil
+}
+
+// SaveSVG writes an SVG document processed without rasterising it to the output of the operation
+func (op ImageIO) SaveSVG(data []byte) error {
+	return writeOutput(op.ImageOutput, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
@@ -0,0 +1,189 @@
+package imageio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/logger"
+	"github.com/Achno/gowall/utils"
+)
+
+const (
+	DefaultURLTimeout  = 30 * time.Second
+	DefaultURLMaxSize  = 50 << 20 // 50 MB
+	DefaultURLCacheTTL = 24 * time.Hour
+)
+
+// URLReader downloads an http(s) input. The download is cached in CacheDir so running several commands
+// on the same url only downloads it once, a cached file older than CacheTTL is downloaded again.
+type URLReader struct {
+	URL      string
+	Client   *http.Client  // nil uses http.DefaultClient
+	Timeout  time.Duration // 0 = DefaultURLTimeout
+	MaxSize  int64         // maximum size of the download in bytes, 0 = DefaultURLMaxSize
+	CacheDir string        // "" disables the cache
+	CacheTTL time.Duration // 0 = DefaultURLCacheTTL
+
+	ctx context.Context // cancels the download, see WithContext
+}
+
+// IsURL reports whether the input is an http(s) url instead of a path
+func IsURL(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// newURLReader returns a URLReader with the default limits that caches in the output folder
+func newURLReader(rawURL string) URLReader {
+	return URLReader{
+		URL:      rawURL,
+		CacheDir: filepath.Join(config.GowallConfig.OutputFolder, "cache", "urls"),
+	}
+}
+
+// WithContext returns a copy of ur whose downloads are cancelled with ctx (e.g. Ctrl-C during a batch)
+func (ur URLReader) WithContext(ctx context.Context) URLReader {
+	ur.ctx = ctx
+	return ur
+}
+
+func (ur URLReader) Open() (io.ReadCloser, error) {
+	if cached, ok := ur.cachedPath(); ok {
+		return os.Open(cached)
+	}
+
+	data, err := ur.download()
+	if err != nil {
+		return nil, err
+	}
+	if ur.CacheDir != "" {
+		if err := ur.store(data); err != nil {
+			logger.Warnf("::: Could not cache %s: %v :::", ur.URL, err)
+		}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (ur URLReader) String() string {
+	return ur.URL
+}
+
+// Name returns the file name of the url path (e.g. wall.png), "" if the path has none
+func (ur URLReader) Name() string {
+	parsed, err := url.Parse(ur.URL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+func (ur URLReader) download() ([]byte, error) {
+	timeout := ur.Timeout
+	if timeout <= 0 {
+		timeout = DefaultURLTimeout
+	}
+	maxSize := ur.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultURLMaxSize
+	}
+	client := ur.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	parent := ur.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ur.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", ur.URL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fetchError(parent, ur.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("could not fetch %s: status code %d", ur.URL, resp.StatusCode)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, utils.Transient(err)
+		}
+		return nil, err
+	}
+	tooLarge := fmt.Errorf("%s is larger than the download limit of %d bytes", ur.URL, maxSize)
+	if resp.ContentLength > maxSize {
+		return nil, tooLarge
+	}
+
+	// read one byte more than the limit to know if the body was cut
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fetchError(parent, ur.URL, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// fetchError marks a failed download as transient so it is retried, unless parent got cancelled
+func fetchError(parent context.Context, rawURL string, err error) error {
+	err = fmt.Errorf("could not fetch %s: %w", rawURL, err)
+	if parent.Err() != nil {
+		return err
+	}
+	return utils.Transient(err)
+}
+
+// cachePath returns the file the download of the url is cached in
+func (ur URLReader) cachePath() string {
+	sum := sha256.Sum256([]byte(ur.URL))
+	return filepath.Join(ur.CacheDir, hex.EncodeToString(sum[:16])+filepath.Ext(ur.Name()))
+}
+
+// cachedPath returns the cached download if it is recent enough
+func (ur URLReader) cachedPath() (string, bool) {
+	if ur.CacheDir == "" {
+		return "", false
+	}
+	ttl := ur.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultURLCacheTTL
+	}
+
+	cached := ur.cachePath()
+	info, err := os.Stat(cached)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return "", false
+	}
+	return cached, true
+}
+
+// store writes the download to the cache, concurrent downloads of the same url never leave a partial file
+func (ur URLReader) store(data []byte) error {
+	return writeOutput(FileWriter{Path: ur.cachePath()}, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
@@ -0,0 +1,168 @@
+package imageio
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Achno/gowall/utils"
+)
+
+func readURL(t *testing.T, ur URLReader) (string, error) {
+	t.Helper()
+	reader, err := ur.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+func TestURLReaderSizeLimit(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// flushing before the body is complete makes the response chunked, without a Content-Length
+			io.WriteString(w, body[:50])
+			w.(http.Flusher).Flush()
+			io.WriteString(w, body[50:])
+			return
+		}
+		w.Header().Set("Content-Length", "100")
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/length", "/chunked"} {
+		_, err := readURL(t, URLReader{URL: server.URL + path, MaxSize: 99})
+		if err == nil || !strings.Contains(err.Error(), "larger than the download limit") {
+			t.Errorf("%s: got %v, want the download limit error", path, err)
+		}
+		if utils.IsTransient(err) {
+			t.Errorf("%s: a download over the limit must not be retried", path)
+		}
+
+		got, err := readURL(t, URLReader{URL: server.URL + path, MaxSize: 100})
+		if err != nil || got != body {
+			t.Errorf("%s: got %d bytes, %v, want the whole body", path, len(got), err)
+		}
+	}
+}
+
+func TestURLReaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := readURL(t, URLReader{URL: server.URL, Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Fatal("got no error for a server that never answers")
+	}
+	if !utils.IsTransient(err) {
+		t.Errorf("a timeout should be retried: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the download took %s, the timeout is 50ms", elapsed)
+	}
+}
+
+func TestURLReaderCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := readURL(t, URLReader{URL: server.URL}.WithContext(ctx))
+	if err == nil {
+		t.Fatal("got no error for a cancelled download")
+	}
+	if utils.IsTransient(err) {
+		t.Errorf("a cancelled download must not be retried: %v", err)
+	}
+}
+
+func TestURLReaderStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		transient bool
+	}{
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusNotFound, false},
+		{http.StatusForbidden, false},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		_, err := readURL(t, URLReader{URL: server.URL})
+		server.Close()
+
+		if err == nil {
+			t.Errorf("status %d: got no error", tt.status)
+			continue
+		}
+		if utils.IsTransient(err) != tt.transient {
+			t.Errorf("status %d: transient = %v, want %v", tt.status, utils.IsTransient(err), tt.transient)
+		}
+	}
+}
+
+func TestURLReaderCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, "image data")
+	}))
+	defer server.Close()
+
+	ur := URLReader{URL: server.URL + "/wall.png", CacheDir: t.TempDir(), CacheTTL: time.Hour}
+	for i := range 2 {
+		got, err := readURL(t, ur)
+		if err != nil || got != "image data" {
+			t.Fatalf("read %d: got %q, %v", i+1, got, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, the second read should be a cache hit", n)
+	}
+	if !strings.HasSuffix(ur.cachePath(), ".png") {
+		t.Errorf("cache file %s lost the extension of the url", ur.cachePath())
+	}
+
+	// a cached file older than the TTL is downloaded again
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(ur.cachePath(), expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readURL(t, ur); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests, the expired cache should have been downloaded again", n)
+	}
+	if _, err := readURL(t, ur); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests, the refreshed cache should be a hit", n)
+	}
+}
@@ -1,10 +1,14 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -16,10 +20,30 @@ const (
 type Logger struct {
 	mu        sync.RWMutex
 	quiet     bool // supresses logs to stdout
+	json      bool // emits one json event per line instead of human readable messages
 	outWriter io.Writer
 	errWriter io.Writer
 }
 
+// Event is a single json line emitted for a message in --json mode
+type Event struct {
+	Event   string `json:"event"` // "message" or "error"
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// FileEvent is emitted in --json mode once an image (or the inputs of a multi image command) got processed
+type FileEvent struct {
+	Event      string   `json:"event"` // always "file"
+	Input      string   `json:"input,omitempty"`
+	Inputs     []string `json:"inputs,omitempty"`
+	Output     string   `json:"output"`
+	DurationMs float64  `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 // Default global logger instance
 var defaultLogger = NewLogger(false)
 
@@ -52,10 +76,51 @@ func SetQuiet(quiet bool) {
 	defaultLogger.SetQuiet(quiet)
 }
 
+// SetJSON switches between human readable messages and json events
+func (l *Logger) SetJSON(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = enabled
+}
+
+// SetJSON sets the global logger's json state
+func SetJSON(enabled bool) {
+	defaultLogger.SetJSON(enabled)
+}
+
+// JSON reports whether the global logger emits json events (--json)
+func JSON() bool {
+	defaultLogger.mu.RLock()
+	defer defaultLogger.mu.RUnlock()
+	return defaultLogger.json
+}
+
+// eventWriter returns where json events go, stderr when stdout is used for image data
+func (l *Logger) eventWriter() io.Writer {
+	if l.quiet {
+		return l.errWriter
+	}
+	return l.outWriter
+}
+
+// encode writes v as a single json line
+func encode(w io.Writer, v any) {
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// cleanMessage strips colors and the "::: :::" decorations of human readable messages
+func cleanMessage(message string) string {
+	return strings.Trim(ansiEscape.ReplaceAllString(message, ""), " \n\t:")
+}
+
 func (l *Logger) Print(v ...any) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	if l.json {
+		encode(l.eventWriter(), Event{Event: "message", Level: "info", Message: cleanMessage(fmt.Sprint(v...))})
+		return
+	}
 	if !l.quiet {
 		fmt.Fprintln(l.outWriter, v...)
 	}
@@ -65,6 +130,10 @@ func (l *Logger) Printf(format string, v ...any) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	if l.json {
+		encode(l.eventWriter(), Event{Event: "message", Level: "info", Message: cleanMessage(fmt.Sprintf(format, v...))})
+		return
+	}
 	if !l.quiet {
 		fmt.Fprintf(l.outWriter, format+"\n", v...)
 	}
@@ -74,6 +143,10 @@ func (l *Logger) Warnf(format string, v ...any) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	if l.json {
+		encode(l.eventWriter(), Event{Event: "message", Level: "warn", Message: cleanMessage(fmt.Sprintf(format, v...))})
+		return
+	}
 	if !l.quiet {
 		fmt.Fprintf(l.outWriter, YellowColor+format+"\n"+ResetColor, v...)
 	}
@@ -85,7 +158,7 @@ func (l *Logger) Error(v ...any) {
 	defer l.mu.Unlock()
 
 	message := fmt.Sprint(v...)
-	fmt.Fprintln(l.errWriter, RedColor+message+ResetColor)
+	l.writeError(message)
 }
 
 // Errorf outputs a formatted error message (always logs to stderr)
@@ -94,16 +167,57 @@ func (l *Logger) Errorf(format string, v ...any) {
 	defer l.mu.Unlock()
 
 	message := fmt.Sprintf(format, v...)
+	l.writeError(message)
+}
+
+func (l *Logger) writeError(message string) {
+	if l.json {
+		encode(l.errWriter, Event{Event: "error", Level: "error", Message: cleanMessage(message)})
+		return
+	}
 	fmt.Fprintln(l.errWriter, RedColor+message+ResetColor)
 }
 
+// File emits a FileEvent in --json mode, it does nothing otherwise
+func (l *Logger) File(inputs []string, output string, duration time.Duration, err error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if !l.json {
+		return
+	}
+
+	event := FileEvent{
+		Event:      "file",
+		Output:     output,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+	}
+	if len(inputs) == 1 {
+		event.Input = inputs[0]
+	} else {
+		event.Inputs = inputs
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	encode(l.eventWriter(), event)
+}
+
+// Document writes v as a single json document, used by the commands that print data (extract, list, color, ocr) in --json mode
+func (l *Logger) Document(v any) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	encode(l.eventWriter(), v)
+}
+
 // Fatal logs an error message and exits with status code 1 (always logs to stderr)
 func (l *Logger) Fatal(v ...any) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	message := fmt.Sprint(v...)
-	fmt.Fprintln(l.errWriter, RedColor+message+ResetColor)
+	l.writeError(message)
 	os.Exit(1)
 }
 
@@ -113,7 +227,7 @@ func (l *Logger) Fatalf(format string, v ...any) {
 	defer l.mu.Unlock()
 
 	message := fmt.Sprintf(format, v...)
-	fmt.Fprintln(l.errWriter, RedColor+message+ResetColor)
+	l.writeError(message)
 	os.Exit(1)
 }
 
@@ -133,6 +247,16 @@ func Warnf(format string, v ...any) {
 	defaultLogger.Warnf(format, v...)
 }
 
+// Emits a json line for a processed file in --json mode
+func File(inputs []string, output string, duration time.Duration, err error) {
+	defaultLogger.File(inputs, output, duration, err)
+}
+
+// Writes v as a single json document to stdout, or to stderr when stdout carries image output
+func Document(v any) {
+	defaultLogger.Document(v)
+}
+
 // Logs message to sderr
 func Error(v ...any) {
 	defaultLogger.Error(v...)
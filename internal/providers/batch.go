@@ -55,7 +55,7 @@ func ProcessBatch(ctx context.Context, items []*PipelineItem, ocrFunc ocrFunc, c
 	totalDuration := time.Since(startTime)
 	_, completed, failed, _ := progress.GetCounters()
 	progress.Stop("OCR Processing completed.")
-	logger.Printf(fmt.Sprintf(utils.BlueColor+"\n 🡲 OCR finished in %v. Completed: %d, Failed: %d\n", totalDuration, completed, failed) + utils.ResetColor)
+	logger.Print(fmt.Sprintf(utils.BlueColor+"\n 🡲 OCR finished in %v. Completed: %d, Failed: %d\n", totalDuration, completed, failed) + utils.ResetColor)
 
 	var allErrors []error
 	for _, res := range finalResults {
@@ -7,7 +7,6 @@ import (
 	"os"
 	"strings"
 
-	cf "github.com/Achno/gowall/config"
 	imageio "github.com/Achno/gowall/internal/image_io"
 )
 
@@ -54,7 +53,7 @@ type DoclingProcessPayload struct {
 
 func NewDoclingProvider(config Config) (OCRProvider, error) {
 
-	baseURL := cf.GowallConfig.EnvConfig.DOCLING_BASE_URL
+	baseURL := config.env().DOCLING_BASE_URL
 	if baseURL == "" {
 		baseURL = doclingDefaultBaseURL
 	}
@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	cf "github.com/Achno/gowall/config"
 	imageio "github.com/Achno/gowall/internal/image_io"
-	"github.com/Achno/gowall/internal/logger"
 	"google.golang.org/genai"
 )
 
@@ -17,7 +15,7 @@ type GeminiProvider struct {
 
 func NewGeminiProvider(config Config) (OCRProvider, error) {
 
-	apiKey := cf.GowallConfig.EnvConfig.GEMINI_API_KEY
+	apiKey := config.env().GEMINI_API_KEY
 	if apiKey == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY env is not set,check that your .env file location is correct inside config.yml and you are properly providing the env's")
 	}
@@ -66,8 +64,7 @@ func (g *GeminiProvider) Complete(ctx context.Context, text string) (string, err
 
 	res, err := g.client.Models.GenerateContent(ctx, g.config.OCR.Model, []*genai.Content{{Parts: []*genai.Part{payload}}}, nil)
 	if err != nil {
-		logger.Warnf("Error correcting text falling back to original: %v", err)
-		return text, nil
+		return "", fmt.Errorf("error correcting text: %w", err)
 	}
 
 	return res.Text(), nil
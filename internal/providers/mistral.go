@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"strings"
 
-	cf "github.com/Achno/gowall/config"
 	imageio "github.com/Achno/gowall/internal/image_io"
 )
 
@@ -68,7 +67,7 @@ type MistralOcrDimensions struct {
 
 func NewMistralProvider(config Config) (OCRProvider, error) {
 
-	apiKey := cf.GowallConfig.EnvConfig.MISTRAL_API_KEY
+	apiKey := config.env().MISTRAL_API_KEY
 	if apiKey == "" {
 		return nil, fmt.Errorf("MISTRAL_API_KEY env is not set,check that your .env file location is correct inside config.yml or you are properly providing the env's")
 	}
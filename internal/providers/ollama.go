@@ -8,7 +8,6 @@ import (
 	"io"
 	"net/http"
 
-	cf "github.com/Achno/gowall/config"
 	imageio "github.com/Achno/gowall/internal/image_io"
 )
 
@@ -43,7 +42,7 @@ type OllamaResponse struct {
 }
 
 func NewOllamaProvider(config Config) (OCRProvider, error) {
-	host := cf.GowallConfig.EnvConfig.OLLAMA_HOST
+	host := config.env().OLLAMA_HOST
 	if host == "" {
 		host = "http://127.0.0.1:11434"
 	}
@@ -128,9 +127,6 @@ func (o *OllamaProvider) Complete(ctx context.Context, text string) (string, err
 		return "", fmt.Errorf("failed to apply options: %w", err)
 	}
 
-	fmt.Println("ollamaOptions", ollamaOptions.MaxTokens, ollamaOptions.Temperature)
-	fmt.Println("prompt", prompt)
-	fmt.Println("text", text)
 	req := OllamaRequest{
 		Model: o.config.OCR.Model,
 		Messages: []OllamaMessage{
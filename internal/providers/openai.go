@@ -4,9 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	cf "github.com/Achno/gowall/config"
-	"github.com/Achno/gowall/internal/logger"
-
 	imageio "github.com/Achno/gowall/internal/image_io"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -26,7 +23,7 @@ func NewOpenAIProvider(config Config) (OCRProvider, error) {
 		"vllm":       "http://localhost:8000/v1",
 		"openrouter": "https://openrouter.ai/api/v1",
 		"openai":     "https://api.openai.com/v1",
-		"oc":         cf.GowallConfig.EnvConfig.OPENAI_BASE_URL,
+		"oc":         config.env().OPENAI_BASE_URL,
 	}
 
 	baseURL, ok := urlMap[config.OCR.Provider]
@@ -36,9 +33,9 @@ func NewOpenAIProvider(config Config) (OCRProvider, error) {
 
 	apiMap := map[string]string{
 		"vllm":       "x",
-		"openrouter": cf.GowallConfig.EnvConfig.OPENROUTER_API_KEY,
-		"openai":     cf.GowallConfig.EnvConfig.OPENAI_API_KEY,
-		"oc":         cf.GowallConfig.EnvConfig.OPENAI_API_COMPATIBLE_SERVICE_API_KEY,
+		"openrouter": config.env().OPENROUTER_API_KEY,
+		"openai":     config.env().OPENAI_API_KEY,
+		"oc":         config.env().OPENAI_API_COMPATIBLE_SERVICE_API_KEY,
 	}
 
 	apiKey, ok := apiMap[config.OCR.Provider]
@@ -49,7 +46,7 @@ func NewOpenAIProvider(config Config) (OCRProvider, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("the [OpenAI/OpenRouter or OpenAI Compatible] API key env is not set, check that your .env file location is correct inside config.yml and you are properly providing the env's")
 	}
-	retries := cf.GowallConfig.EnvConfig.OPENAI_MAX_RETRIES
+	retries := config.env().OPENAI_MAX_RETRIES
 
 	model := config.OCR.Model
 
@@ -114,8 +111,7 @@ func (o *OpenAIProvider) Complete(ctx context.Context, text string) (string, err
 		Temperature: openai.Float(openaiOptions.Temperature),
 	})
 	if err != nil {
-		logger.Warnf("Error correcting text falling back to original: %v", err)
-		return text, nil
+		return "", fmt.Errorf("error correcting text: %w", err)
 	}
 
 	return chatCompletion.Choices[0].Message.Content, nil
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"dario.cat/mergo"
+	cf "github.com/Achno/gowall/config"
 	"github.com/Achno/gowall/utils"
 )
 
@@ -46,6 +47,20 @@ type Config struct {
 	DoclingOptions *DoclingOptions `yaml:"docling_options,omitempty"`
 	OpenAIOptions  *OpenAIOptions  `yaml:"openai_options,omitempty"`
 	OllamaOptions  *OllamaOptions  `yaml:"ollama_options,omitempty"`
+
+	// API keys and endpoints of the providers, nil uses the ones loaded from the .env of config.yml
+	Env *cf.EnvConfig `yaml:"-"`
+}
+
+// env returns the API keys and endpoints the providers use, see Config.Env
+func (c Config) env() *cf.EnvConfig {
+	if c.Env != nil {
+		return c.Env
+	}
+	if cf.GowallConfig.EnvConfig != nil {
+		return cf.GowallConfig.EnvConfig
+	}
+	return &cf.EnvConfig{}
 }
 
 //TODO: make a reusable Merge() function with generics that asserts the given interface and merges the structs with mergo.
@@ -17,8 +17,16 @@ import (
 	"github.com/synoptiq/go-fluxus"
 )
 
+// ocrDocument is the result of a single input printed by ocr in --json mode
+type ocrDocument struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Text   string `json:"text"`
+}
+
 // StartOCRPipeline orchestrates the OCR workflow. Accepts the an OCR provider and a list of imageIO operations.
-func StartOCRPipeline(ops []imageio.ImageIO, service *ProviderService) error {
+// Cancelling ctx stops the pipeline and nothing is saved.
+func StartOCRPipeline(ctx context.Context, ops []imageio.ImageIO, service *ProviderService) error {
 	config := service.GetConfig()
 
 	// 1. Load files concurrently from imageIO operations : maintain order and mapping
@@ -40,7 +48,7 @@ func StartOCRPipeline(ops []imageio.ImageIO, service *ProviderService) error {
 	progress := WithPrefixProgress(len(initialItems), "Pre-Processing...")
 	progress.Start()
 
-	processedItems, err := runPreprocessingPipeline(initialItems, service, progress)
+	processedItems, err := runPreprocessingPipeline(ctx, initialItems, service, progress)
 	if err != nil {
 		progress.Stop("Pre-Processing failed.")
 		return fmt.Errorf("pre-processing failed: %w", err)
@@ -49,7 +57,7 @@ func StartOCRPipeline(ops []imageio.ImageIO, service *ProviderService) error {
 
 	// 3. Run OCR Batch Processing
 	ocrProgress := WithPrefixProgress(len(processedItems), "OCR Processing")
-	batchResults, err := ProcessBatch(context.Background(), processedItems, service.OCR, config.Pipeline.OCRConcurrency, ocrProgress)
+	batchResults, err := ProcessBatch(ctx, processedItems, service.OCR, config.Pipeline.OCRConcurrency, ocrProgress)
 	if err != nil {
 		return fmt.Errorf("OCR processing failed: %w", err)
 	}
@@ -61,30 +69,50 @@ func StartOCRPipeline(ops []imageio.ImageIO, service *ProviderService) error {
 	if config.TextCorrection.Enabled {
 		postProcessingProgress := WithPrefixProgress(len(finalResults), "Post-Processing")
 		postProcessingProgress.Start()
-		finalResults, err = runPostprocessingPipeline(finalResults, config, service, postProcessingProgress)
+		finalResults, err = runPostprocessingPipeline(ctx, finalResults, config, service, postProcessingProgress)
 		if err != nil {
 			return fmt.Errorf("post-processing failed: %w", err)
 		}
 		postProcessingProgress.Stop("Post-Processing completed.")
 	}
 
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted, no text was saved: %w", ctx.Err())
+	}
+
 	// 6. Use the mapping to save the results to the correct files
+	var documents []ocrDocument
 	for i, item := range finalResults {
 		if opsIndex, exists := inputToOpsMapping[i]; exists {
 			if item != nil {
-				imageio.SaveText(item.Text, ops[opsIndex].ImageOutput)
-				logger.Printf(fmt.Sprintf(utils.BlueColor+"● Saved to %s\n", ops[opsIndex].ImageOutput.String()+utils.ResetColor))
-
+				if err := imageio.SaveText(item.Text, ops[opsIndex].ImageOutput); err != nil {
+					logger.Error(err)
+					continue
+				}
+				ops[opsIndex].Manifest.Record(ops[opsIndex].ImageOutput)
+				documents = append(documents, ocrDocument{
+					Input:  ops[opsIndex].ImageInput.String(),
+					Output: ops[opsIndex].ImageOutput.String(),
+					Text:   item.Text,
+				})
+				if !logger.JSON() {
+					logger.Print(fmt.Sprintf(utils.BlueColor+"● Saved to %s\n", ops[opsIndex].ImageOutput.String()+utils.ResetColor))
+				}
 			}
 		}
 	}
 
-	return nil
+	if logger.JSON() {
+		logger.Document(struct {
+			Results []ocrDocument `json:"results"`
+		}{documents})
+	}
+
+	return imageio.SaveManifests(ops)
 }
 
 // runPreprocessingPipeline runs the given items through a pipeline of various stages.
-func runPreprocessingPipeline(initialItems []*PipelineItem, service *ProviderService, progress *ProgressTracker) ([]*PipelineItem, error) {
-	ctx := context.Background()
+func runPreprocessingPipeline(ctx context.Context, initialItems []*PipelineItem, service *ProviderService, progress *ProgressTracker) ([]*PipelineItem, error) {
 	config := service.GetConfig()
 
 	pdfExpandStage := NewExpandSinglePdfStage(service)
@@ -126,8 +154,7 @@ func runPreprocessingPipeline(initialItems []*PipelineItem, service *ProviderSer
 }
 
 // runPostprocessingPipeline runs text correction on the stitched OCR results
-func runPostprocessingPipeline(ocrResults []*OCRResult, config Config, service *ProviderService, progress *ProgressTracker) ([]*OCRResult, error) {
-	ctx := context.Background()
+func runPostprocessingPipeline(ctx context.Context, ocrResults []*OCRResult, config Config, service *ProviderService, progress *ProgressTracker) ([]*OCRResult, error) {
 
 	validResults := 0
 	for _, result := range ocrResults {
@@ -5,6 +5,9 @@ import (
 	"strings"
 )
 
+// DefaultOCRPrompt is the prompt of the vision model providers when the schema doesn't set one
+const DefaultOCRPrompt = "Extract all visible text from this image and format the output as markdown. Include only the text content; no explanations or additional text should be included. If the image is empty, return an empty string. Fix any formatting issues or inconsistencies found in the extracted content"
+
 func BuildPrompt(base, appendPrompt, filename, format string) string {
 
 	prompt := base
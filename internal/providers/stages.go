@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Achno/gowall/internal/image"
+	"github.com/Achno/gowall/internal/logger"
 	"github.com/Achno/gowall/internal/pdf"
 	"github.com/synoptiq/go-fluxus"
 )
@@ -94,7 +95,7 @@ func NewTextCorrectionStage(textCorrectionFunc textCorrectionFunc, progress *Pro
 		}
 
 		correctedText, err := textCorrectionFunc(ctx, result.Text)
-		if err != nil {
+		if err != nil && ctx.Err() != nil {
 			progress.IncrementFailed()
 			return nil, fmt.Errorf("text correction stage > %w", err)
 		}
@@ -107,7 +108,14 @@ func NewTextCorrectionStage(textCorrectionFunc textCorrectionFunc, progress *Pro
 		if correctedResult.Metadata == nil {
 			correctedResult.Metadata = make(map[string]string)
 		}
-		correctedResult.Metadata["TextCorrectionApplied"] = "true"
+		if err != nil {
+			// the text of the OCR is still usable, the providers report the error and the fallback happens here
+			logger.Warnf("::: Text correction failed, keeping the original text: %v :::", err)
+			correctedResult.Text = result.Text
+			correctedResult.Metadata["TextCorrectionError"] = err.Error()
+		} else {
+			correctedResult.Metadata["TextCorrectionApplied"] = "true"
+		}
 
 		progress.IncrementCompleted()
 		return correctedResult, nil
@@ -9,4 +9,17 @@ type EncoderFunc func(w io.Writer, img image.Image) error
 
 type ImageMetadata struct {
 	EncoderFunction EncoderFunc
+	Source          *SourceMetadata // metadata of the input written to the output, nil writes none
+}
+
+// SourceMetadata is the metadata embedded in the input image (EXIF, ICC profile)
+type SourceMetadata struct {
+	EXIF        []byte // TIFF structured EXIF payload, without the "Exif\0\0" header of jpeg
+	ICC         []byte // embedded ICC color profile
+	Orientation int    // EXIF orientation of the input (1-8), the image and EXIF are already rotated upright on load
+}
+
+// IsEmpty reports whether there is nothing to write to the output
+func (m *SourceMetadata) IsEmpty() bool {
+	return m == nil || (len(m.EXIF) == 0 && len(m.ICC) == 0)
 }
@@ -2,7 +2,6 @@ package upscaler
 
 import (
 	"fmt"
-	"path/filepath"
 	"runtime"
 
 	"github.com/Achno/gowall/config"
@@ -10,8 +9,8 @@ import (
 	"github.com/Achno/gowall/utils"
 )
 
-func SetupUpscaler() error {
-	destFolder := filepath.Join(config.GowallConfig.OutputFolder, "upscaler")
+// SetupUpscaler downloads the realesrgan-ncnn-vulkan binary and its models to destFolder
+func SetupUpscaler(destFolder string) error {
 
 	// urls of the ESRGAN portable model depending on the operating system
 	urls := map[string]string{
@@ -0,0 +1,28 @@
+package gowall
+
+import (
+	"image"
+	"io"
+
+	imageio "github.com/Achno/gowall/internal/image_io"
+)
+
+// Decode reads an image in any of the formats gowall supports (png, jpeg, webp, avif, tiff, bmp, gif, qoi, pnm, svg...).
+// It is rotated upright according to its EXIF orientation, SVG documents are rasterised at their intrinsic size.
+func Decode(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return imageio.LoadImage(imageio.MemoryReader{Name: "input", Data: data})
+}
+
+// Encode writes the image to w in the format, see Formats
+func Encode(w io.Writer, img image.Image, format string) error {
+	return imageio.Encode(w, img, format)
+}
+
+// Formats returns the formats Encode supports, in alphabetical order
+func Formats() []string {
+	return imageio.SupportedFormats()
+}
@@ -0,0 +1,69 @@
+package gowall
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/Achno/gowall/internal/backends/compression/png"
+	gimage "github.com/Achno/gowall/internal/image"
+)
+
+// CompressOptions configures Compress
+type CompressOptions struct {
+	Format  string // png, jpg, jpeg, webp or avif
+	Quality int    // 1-100, 0 = 80
+	Speed   int    // encoding speed of avif and pngquant, 0 = 4
+	Method  string // pngquant, losslesspng, lossyjpg, lossyjpeg, lossywebp or lossyavif, "" = the default of the format
+}
+
+// Compress encodes the image to w with a smaller size than Encode.
+// Png uses pngquant when it is installed in $PATH and lossless compression otherwise, it is never downloaded.
+func Compress(w io.Writer, img image.Image, opts CompressOptions) error {
+	format := strings.ToLower(opts.Format)
+	if opts.Quality == 0 {
+		opts.Quality = 80
+	}
+	if opts.Speed == 0 {
+		opts.Speed = 4
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100, got %d", opts.Quality)
+	}
+
+	strategy, err := compressionStrategy(format, opts)
+	if err != nil {
+		return err
+	}
+	compressed, metadata, err := strategy.Compress(img)
+	if err != nil {
+		return err
+	}
+	if metadata.EncoderFunction != nil {
+		return metadata.EncoderFunction(w, compressed)
+	}
+	return Encode(w, compressed, format)
+}
+
+func compressionStrategy(format string, opts CompressOptions) (gimage.CompressionStrategy, error) {
+	// the cli offers to download pngquant when it is missing, a library must not
+	if format == "png" && (opts.Method == "" || opts.Method == "pngquant") {
+		binaryPath, err := exec.LookPath("pngquant")
+		if err == nil {
+			return &png.PngquantStrategy{BinaryPath: binaryPath, Quality: opts.Quality, Speed: opts.Speed}, nil
+		}
+		if opts.Method == "pngquant" {
+			return nil, fmt.Errorf("pngquant is not installed in $PATH")
+		}
+		return png.NewLosslessPngStrategy()
+	}
+
+	processor := gimage.NewCompressionProcessor(
+		gimage.WithQuality(opts.Quality),
+		gimage.WithSpeed(opts.Speed),
+		gimage.WithStrategy(opts.Method),
+	)
+	return processor.GetStrategy(format)
+}
@@ -0,0 +1,11 @@
+// Package gowall exposes the image processing of the gowall cli to other Go programs.
+//
+// Every function works on image.Image values or on io.Reader/io.Writer streams and reports failures as errors.
+// Nothing reads config.yml, writes to the output folder, logs, or shows a spinner or prompt.
+// Only the built-in themes are known, custom themes are created with NewTheme.
+//
+//	img, err := gowall.Decode(file)
+//	theme, err := gowall.LookupTheme("nord")
+//	converted, err := gowall.ConvertTheme(img, theme, gowall.ConvertOptions{})
+//	err = gowall.Encode(out, converted, "png")
+package gowall
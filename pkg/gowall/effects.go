@@ -0,0 +1,79 @@
+package gowall
+
+import (
+	"image"
+
+	gimage "github.com/Achno/gowall/internal/image"
+)
+
+// Contrast modes, see Contrast and SigmoidContrast
+const (
+	ContrastModeNormal  = gimage.ContrastModeNormal
+	ContrastModeSigmoid = gimage.ContrastModeSigmoid
+)
+
+// apply runs one of the cli processors on the image, they never use the theme or the output format
+func apply(processor gimage.ImageProcessor, img image.Image) (image.Image, error) {
+	out, _, err := processor.Process(img, "", "")
+	return out, err
+}
+
+// Invert inverts the colors of the image
+func Invert(img image.Image) (image.Image, error) {
+	return apply(&gimage.Inverter{}, img)
+}
+
+// Grayscale removes the colors of the image
+func Grayscale(img image.Image) (image.Image, error) {
+	return apply(&gimage.GrayScaleProcessor{}, img)
+}
+
+// Flip flips the image horizontally
+func Flip(img image.Image) (image.Image, error) {
+	return apply(&gimage.FlipProcessor{}, img)
+}
+
+// Mirror replaces the right half of the image with the mirrored left half
+func Mirror(img image.Image) (image.Image, error) {
+	return apply(&gimage.MirrorProcessor{}, img)
+}
+
+// Brightness multiplies every channel by the factor, 1.2 is 20% brighter and 0.8 20% darker
+func Brightness(img image.Image, factor float64) (image.Image, error) {
+	return apply(&gimage.BrightnessProcessor{Factor: factor}, img)
+}
+
+// Contrast changes the contrast by a percentage in the range [-100, 100]
+func Contrast(img image.Image, percentage float64) (image.Image, error) {
+	return apply(&gimage.ContrastProcessor{Mode: ContrastModeNormal, Factor: percentage}, img)
+}
+
+// SigmoidContrast changes the contrast with a sigmoid curve centered at midpoint (0-1), a positive factor increases it
+func SigmoidContrast(img image.Image, midpoint float64, factor float64) (image.Image, error) {
+	return apply(&gimage.ContrastProcessor{Mode: ContrastModeSigmoid, Midpoint: midpoint, SigmoidFactor: factor}, img)
+}
+
+// Gamma applies gamma correction, values below 1 darken and above 1 lighten the image
+func Gamma(img image.Image, gamma float64) (image.Image, error) {
+	return apply(&gimage.GammaProcessor{Gamma: gamma}, img)
+}
+
+// Saturation changes the saturation by a percentage in the range [-100, 100]
+func Saturation(img image.Image, percentage float64) (image.Image, error) {
+	return apply(&gimage.SaturationProcessor{Percentage: percentage}, img)
+}
+
+// Pixelate pixelates the image, scale is the size in percent the image is sampled at, lower is blockier
+func Pixelate(img image.Image, scale float64) (image.Image, error) {
+	return apply(&gimage.PixelateProcessor{Scale: scale}, img)
+}
+
+// Resize resizes the image with the lanczos or catmullrom method, a width or height of 0 keeps the aspect ratio
+func Resize(img image.Image, width int, height int, method string) (image.Image, error) {
+	if method == "" {
+		method = "lanczos"
+	}
+	processor := &gimage.ResizeProcessor{}
+	processor.SetOptions(gimage.WithWidth(width), gimage.WithHeight(height), gimage.WithMethod(method))
+	return apply(processor, img)
+}
@@ -0,0 +1,50 @@
+package gowall_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+
+	"github.com/Achno/gowall/pkg/gowall"
+)
+
+func ExampleConvertTheme() {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 250, G: 10, B: 10, A: 255})
+	img.Set(1, 0, color.RGBA{R: 10, G: 10, B: 240, A: 255})
+
+	theme, err := gowall.NewTheme("duo", "#BF616A", "#5E81AC")
+	if err != nil {
+		log.Fatal(err)
+	}
+	converted, err := gowall.ConvertTheme(img, theme, gowall.ConvertOptions{Backend: gowall.BackendNearestNeighbour})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(gowall.Hex(converted.At(0, 0)), gowall.Hex(converted.At(1, 0)))
+	// Output: #BF616A #5E81AC
+}
+
+func ExampleOCR() {
+	file, err := os.Open("screenshot.png")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	img, err := gowall.Decode(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	text, err := gowall.OCR(context.Background(), img, gowall.OCROptions{
+		Provider: "ollama",
+		Model:    "llava",
+		BaseURL:  "http://127.0.0.1:11434",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(text)
+}
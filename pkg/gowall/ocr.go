@@ -0,0 +1,79 @@
+package gowall
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/Achno/gowall/config"
+	"github.com/Achno/gowall/internal/providers"
+)
+
+// OCROptions configures OCR
+type OCROptions struct {
+	Provider string // tesseract, ollama, openai, openrouter, oc (openai compatible), vllm, gemini, mistral or docling
+	Model    string // e.g. gpt-4o, for tesseract any non empty value
+	Prompt   string // instructions of the vision model providers, "" = a prompt that extracts the text as markdown
+	Language string // language of the text, e.g. eng for tesseract
+	Format   string // md or txt, "" = md
+
+	APIKey  string // API key of openai, openrouter, oc, gemini and mistral
+	BaseURL string // endpoint of oc, ollama and docling, "" = the default of the provider
+}
+
+// env maps the API key and endpoint to the variables the provider reads
+func (o OCROptions) env() *config.EnvConfig {
+	env := &config.EnvConfig{OPENAI_MAX_RETRIES: 2}
+	switch o.Provider {
+	case "openai":
+		env.OPENAI_API_KEY = o.APIKey
+	case "openrouter":
+		env.OPENROUTER_API_KEY = o.APIKey
+	case "oc":
+		env.OPENAI_API_COMPATIBLE_SERVICE_API_KEY = o.APIKey
+		env.OPENAI_BASE_URL = o.BaseURL
+	case "gemini":
+		env.GEMINI_API_KEY = o.APIKey
+	case "mistral":
+		env.MISTRAL_API_KEY = o.APIKey
+	case "ollama":
+		env.OLLAMA_HOST = o.BaseURL
+	case "docling":
+		env.DOCLING_BASE_URL = o.BaseURL
+	}
+	return env
+}
+
+// OCR extracts the text of the image with the provider
+func OCR(ctx context.Context, img image.Image, opts OCROptions) (string, error) {
+	if opts.Prompt == "" {
+		opts.Prompt = providers.DefaultOCRPrompt
+	}
+	if opts.Format == "" {
+		opts.Format = "md"
+	}
+	if opts.Format != "md" && opts.Format != "txt" {
+		return "", fmt.Errorf("invalid OCR format %q, use md or txt", opts.Format)
+	}
+
+	cfg := providers.Config{
+		OCR: providers.ProviderConfig{
+			Provider: opts.Provider,
+			Model:    opts.Model,
+			Prompt:   opts.Prompt,
+			Language: opts.Language,
+			Format:   opts.Format,
+		},
+		Env: opts.env(),
+	}
+	provider, err := providers.NewOCRProvider(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := provider.OCR(ctx, providers.OCRInput{Type: providers.InputTypeImage, Image: img, Filename: "image"})
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
@@ -0,0 +1,78 @@
+package gowall
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeOllama answers /api/chat with text and remembers the last request
+func fakeOllama(t *testing.T, text string) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":   request["model"],
+			"message": map[string]string{"role": "assistant", "content": text},
+			"done":    true,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &request
+}
+
+func TestOCR(t *testing.T) {
+	server, request := fakeOllama(t, "# Hello")
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	text, err := OCR(context.Background(), img, OCROptions{Provider: "ollama", Model: "llava", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "# Hello" {
+		t.Errorf("got %q, want %q", text, "# Hello")
+	}
+
+	if (*request)["model"] != "llava" {
+		t.Errorf("model %v, want llava", (*request)["model"])
+	}
+	messages, _ := (*request)["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	message := messages[0].(map[string]any)
+	if images, _ := message["images"].([]any); len(images) != 1 {
+		t.Errorf("got %d images, want the image", len(images))
+	}
+	if content, _ := message["content"].(string); !strings.Contains(content, "markdown") {
+		t.Errorf("the default prompt should ask for markdown, got %q", content)
+	}
+}
+
+func TestOCRInvalidOptions(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	tests := []struct {
+		name string
+		opts OCROptions
+	}{
+		{"format", OCROptions{Provider: "ollama", Model: "llava", Format: "pdf"}},
+		{"provider", OCROptions{Provider: "unknown", Model: "x"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := OCR(context.Background(), img, tt.opts); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
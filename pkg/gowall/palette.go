@@ -0,0 +1,24 @@
+package gowall
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+	"github.com/Achno/gowall/internal/backends/colorthief"
+)
+
+// ExtractPalette returns up to numColors dominant colors of the image, the most dominant first
+func ExtractPalette(img image.Image, numColors int) ([]color.Color, error) {
+	if numColors < 1 {
+		return nil, fmt.Errorf("the number of colors must be at least 1, got %d", numColors)
+	}
+	return colorthief.GetPalette(img, numColors)
+}
+
+// Hex formats the color as #RRGGBB, alpha is ignored
+func Hex(c color.Color) string {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return cpkg.RGBtoHex(color.RGBA{R: nrgba.R, G: nrgba.G, B: nrgba.B, A: 255})
+}
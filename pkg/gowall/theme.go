@@ -0,0 +1,161 @@
+package gowall
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+
+	cpkg "github.com/Achno/gowall/internal/backends/color"
+	haldclut "github.com/Achno/gowall/internal/backends/colorthief/haldClut"
+	"github.com/Achno/gowall/internal/backends/dither"
+	gimage "github.com/Achno/gowall/internal/image"
+)
+
+// Theme conversion backends, see ConvertOptions
+const (
+	BackendCLUT             = "clut"                         // smooth mapping through a HaldCLUT built from the palette
+	BackendNearestNeighbour = gimage.BackendNearestNeighbour // every pixel is replaced by the nearest color of the palette
+)
+
+// DefaultCLUTLevel is the HaldCLUT level of BackendCLUT, 64 steps per channel.
+// Levels up to 16 (256 steps) are supported, colors between the steps are interpolated.
+const DefaultCLUTLevel = haldclut.DefaultLevel
+
+// Mappers returns the names of the palette mappers of ConvertOptions, the first one is the default
+func Mappers() []string {
+	return slices.Clone(haldclut.MapperNames)
+}
+
+// Distances returns the names of the color distance metrics of ConvertOptions, the first one is the default
+func Distances() []string {
+	return slices.Clone(cpkg.DistanceNames)
+}
+
+// DitherModes returns the dithering modes of ConvertOptions, the first one is the default
+func DitherModes() []string {
+	return slices.Clone(dither.Modes)
+}
+
+// Theme is a named color palette
+type Theme struct {
+	Name   string
+	Colors []color.Color
+}
+
+// Themes returns the names of the built-in themes in alphabetical order
+func Themes() []string {
+	names := gimage.ListThemes()
+	slices.Sort(names)
+	return names
+}
+
+// LookupTheme returns the built-in theme with the name
+func LookupTheme(name string) (Theme, error) {
+	theme, err := gimage.SelectTheme(name)
+	if err != nil {
+		return Theme{}, fmt.Errorf("%w %s", err, name)
+	}
+	return Theme{Name: theme.Name, Colors: theme.Colors}, nil
+}
+
+// NewTheme creates a theme from hex colors, e.g. "#2E3440"
+func NewTheme(name string, hexColors ...string) (Theme, error) {
+	if len(hexColors) == 0 {
+		return Theme{}, fmt.Errorf("theme %s has no colors", name)
+	}
+	colors, err := cpkg.HexToRGBASlice(hexColors)
+	if err != nil {
+		return Theme{}, err
+	}
+	return Theme{Name: name, Colors: colors}, nil
+}
+
+// ConvertOptions configures a ThemeConverter, the zero value uses BackendCLUT at DefaultCLUTLevel
+type ConvertOptions struct {
+	Backend   string // BackendCLUT or BackendNearestNeighbour
+	CLUTLevel int    // 0 = DefaultCLUTLevel
+	Mapper    string // how BackendCLUT maps colors to the palette, one of Mappers with options e.g. "oklab" or "rbf:sigma=30", "" = rbf
+	Distance  string // how BackendNearestNeighbour compares colors, one of Distances, "" = rgb
+
+	// Dithering of BackendNearestNeighbour, one of DitherModes, "" does not dither.
+	// DitherStrength in (0,1] scales the diffused error or the threshold spread, 0 = full strength.
+	Dither         string
+	DitherStrength float64
+}
+
+// ThemeConverter converts images to the color scheme of a theme.
+// The CLUT is built once, so converting many images with the same theme is cheaper than calling ConvertTheme for each of them.
+// It is safe for concurrent use.
+type ThemeConverter struct {
+	theme    gimage.Theme
+	opts     ConvertOptions
+	clut     *image.RGBA
+	distance cpkg.Metric
+	dither   dither.Options
+}
+
+// NewThemeConverter validates the options and builds the CLUT of the theme
+func NewThemeConverter(theme Theme, opts ConvertOptions) (*ThemeConverter, error) {
+	if len(theme.Colors) == 0 {
+		return nil, fmt.Errorf("theme %s has no colors", theme.Name)
+	}
+	if opts.Backend == "" {
+		opts.Backend = BackendCLUT
+	}
+	if opts.CLUTLevel == 0 {
+		opts.CLUTLevel = DefaultCLUTLevel
+	}
+
+	tc := &ThemeConverter{
+		theme: gimage.Theme{Name: theme.Name, Colors: theme.Colors},
+		opts:  opts,
+	}
+
+	switch opts.Backend {
+	case BackendNearestNeighbour:
+		distance, err := cpkg.ParseMetric(opts.Distance)
+		if err != nil {
+			return nil, err
+		}
+		tc.distance = distance
+		tc.dither = dither.Options{Mode: opts.Dither, Strength: opts.DitherStrength}
+		if err := tc.dither.Validate(); err != nil {
+			return nil, err
+		}
+		return tc, nil
+	case BackendCLUT:
+		if err := haldclut.ValidateLevel(opts.CLUTLevel); err != nil {
+			return nil, err
+		}
+		mapper, err := haldclut.ParseMapper(opts.Mapper)
+		if err != nil {
+			return nil, err
+		}
+		clut, err := gimage.BuildThemeCLUT(tc.theme, opts.CLUTLevel, mapper)
+		if err != nil {
+			return nil, err
+		}
+		tc.clut = clut
+		return tc, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q, use %s or %s", opts.Backend, BackendCLUT, BackendNearestNeighbour)
+	}
+}
+
+// Convert returns the image in the color scheme of the theme, the input is not modified
+func (tc *ThemeConverter) Convert(img image.Image) (image.Image, error) {
+	if tc.opts.Backend == BackendNearestNeighbour {
+		return gimage.NearestNeighbour(img, tc.theme, tc.distance, tc.dither)
+	}
+	return gimage.ApplyThemeCLUT(img, tc.clut, tc.opts.CLUTLevel), nil
+}
+
+// ConvertTheme converts a single image to the color scheme of the theme, see ThemeConverter
+func ConvertTheme(img image.Image, theme Theme, opts ConvertOptions) (image.Image, error) {
+	tc, err := NewThemeConverter(theme, opts)
+	if err != nil {
+		return nil, err
+	}
+	return tc.Convert(img)
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"errors"
+
 	"github.com/Achno/gowall/internal/logger"
 )
 
@@ -12,7 +14,7 @@ func HandleError(err error, msg ...string) {
 		case len(msg) > 0:
 			logger.Fatalf("\n %s: %s", msg[0], err)
 		default:
-			logger.Fatalf("\n" + err.Error())
+			logger.Fatal("\n" + err.Error())
 		}
 	}
 }
@@ -25,3 +27,25 @@ func FormatErrors(errs []error) string {
 	}
 	return result
 }
+
+// transientError marks a failure that may not happen again, e.g. an external binary crashing or a network error
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient marks err as worth retrying (--retries), nil stays nil
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient reports whether err or an error it wraps was marked with Transient
+func IsTransient(err error) bool {
+	var transient *transientError
+	return errors.As(err, &transient)
+}